@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// appReport is an installed application and the Go binaries in it.
+type appReport struct {
+	Name      string       `json:"name"`
+	Version   string       `json:"version,omitempty"`
+	Publisher string       `json:"publisher,omitempty"`
+	Location  string       `json:"location"`
+	Binaries  []scanResult `json:"binaries"`
+}
+
+// label is how the application is named in text output.
+func (a *appReport) label() string {
+	if a.Version == "" {
+		return a.Name
+	}
+	return a.Name + " " + a.Version
+}
+
+// reportApps scans the files below the location of every application in
+// apps that want accepts and reports those with a Go version, labeled
+// with the application, or with jsonOut, prints the applications that
+// have any with their binaries. It returns the exit code.
+func reportApps(r *reporter, apps []*appReport, jsonOut bool, want func(app *appReport, path string, fi os.FileInfo) bool) int {
+	found := []*appReport{}
+	for _, app := range apps {
+		walkFiles(app.Location, func(path string, fi os.FileInfo, err error) {
+			if err != nil || !fi.Mode().IsRegular() || !want(app, path, fi) {
+				return
+			}
+			ver, err := findVersion(path)
+			if isNoVersion(err) {
+				return
+			}
+			if jsonOut {
+				res := newScanResult(path, ver, err)
+				switch {
+				case err != nil:
+					r.exit = worseExit(r.exit, exitError)
+				case res.TooOld:
+					r.exit = worseExit(r.exit, exitViolation)
+				}
+				app.Binaries = append(app.Binaries, res)
+				return
+			}
+			r.report(app.label()+": "+path, ver, err)
+		})
+		if len(app.Binaries) > 0 {
+			found = append(found, app)
+		}
+	}
+	if jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(found); err != nil {
+			slog.Error("writing results failed", "err", err)
+			return exitError
+		}
+	}
+	return r.exit
+}
+
+// applicationDirs returns the directories macOS applications are
+// installed to.
+func applicationDirs() []string {
+	dirs := []string{"/Applications"}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, "Applications"))
+	}
+	return dirs
+}
+
+// appBundles returns the .app bundles in dirs and the folders below them,
+// like /Applications/Utilities, but not those inside other bundles, which
+// are reported as part of the bundle they are in.
+func appBundles(dirs []string) []*appReport {
+	var apps []*appReport
+	for _, dir := range dirs {
+		filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+			if err != nil || !fi.IsDir() {
+				return nil
+			}
+			if !strings.HasSuffix(path, ".app") {
+				return nil
+			}
+			app := &appReport{Name: strings.TrimSuffix(filepath.Base(path), ".app"), Location: path}
+			if data, err := readPlist(filepath.Join(path, "Contents", "Info.plist")); err == nil {
+				info := plistStrings(data)
+				if n := info["CFBundleDisplayName"]; n != "" {
+					app.Name = n
+				} else if n := info["CFBundleName"]; n != "" {
+					app.Name = n
+				}
+				app.Version = info["CFBundleShortVersionString"]
+			} else {
+				slog.Debug("reading bundle info failed", "app", path, "err", err)
+			}
+			apps = append(apps, app)
+			return filepath.SkipDir
+		})
+	}
+	sort.SliceStable(apps, func(i, j int) bool {
+		return strings.ToLower(apps[i].Name) < strings.ToLower(apps[j].Name)
+	})
+	return apps
+}
+
+// isBundleBinary reports whether the file in a bundle can be a Go binary:
+// executables, including the helpers in Contents/Frameworks, and
+// libraries are executable files.
+func isBundleBinary(app *appReport, path string, fi os.FileInfo) bool {
+	return fi.Mode()&0111 != 0
+}
+
+// plistStrings returns the string values of the top-level dictionary of
+// the XML property list data, by key.
+func plistStrings(data []byte) map[string]string {
+	vals := make(map[string]string)
+	d := xml.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	key := ""
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return vals
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			if depth != 3 {
+				continue
+			}
+			var v string
+			if t.Name.Local != "key" && t.Name.Local != "string" {
+				d.Skip()
+				depth--
+				continue
+			}
+			if err := d.DecodeElement(&v, &t); err != nil {
+				return vals
+			}
+			depth--
+			if t.Name.Local == "key" {
+				key = v
+			} else {
+				vals[key] = v
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
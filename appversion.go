@@ -0,0 +1,59 @@
+package main
+
+import "strings"
+
+// defaultAppVersionVars are the variables applications conventionally keep
+// their own version in, stamped with -ldflags -X at build time. {main}
+// stands for the path of the main module.
+var defaultAppVersionVars = []string{
+	"main.version",
+	"main.Version",
+	"{main}/version.Version",
+	"{main}/internal/version.Version",
+	"{main}/pkg/version.Version",
+	"{main}/buildinfo.Version",
+	"{main}/internal/buildinfo.Version",
+}
+
+// appVersionVars are the variables scans look for the version of the
+// application in, in order, as enabled by -app-version or configured; nil
+// if scans don't.
+var appVersionVars []string
+
+// findAppVersion returns the version of the application b, the first of
+// the non-empty appVersionVars it has, or "" if there is none or b is nil.
+// Variables that can't be read, as in stripped binaries, have the value
+// -ldflags -X set them to, if the build info records that.
+func findAppVersion(b *binaryFile) string {
+	if b == nil || len(appVersionVars) == 0 {
+		return ""
+	}
+	mainPath := ""
+	stamped := make(map[string]string)
+	if bi, err := b.BuildInfo(); err == nil {
+		mainPath = bi.Main.Path
+		for _, s := range bi.Settings {
+			if s.Key == "-ldflags" {
+				for _, v := range parseLinkVars(s.Value) {
+					stamped[v.Name] = v.Value
+				}
+			}
+		}
+	}
+	for _, name := range appVersionVars {
+		if strings.Contains(name, "{main}") {
+			if mainPath == "" {
+				continue
+			}
+			name = strings.ReplaceAll(name, "{main}", mainPath)
+		}
+		v, ok := readLinkVar(b, name)
+		if !ok {
+			v = stamped[name]
+		}
+		if v = strings.TrimSpace(v); v != "" && !strings.ContainsAny(v, "\n\x00") {
+			return v
+		}
+	}
+	return ""
+}
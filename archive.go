@@ -0,0 +1,317 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Limits protecting against scanning huge data files and archives that
+// decompress to far more than their own size, or are crafted to take
+// long to traverse.
+var (
+	maxFileSize       int64 = 1 << 30
+	maxExtractedBytes int64 = 4 << 30
+	maxArchiveEntries int64 = 1 << 20
+	maxExpansionRatio int64 = 200
+	maxNestingDepth         = 3
+)
+
+var (
+	errExtractLimit   = errors.New("archive extracts to more than the -max-extracted-bytes limit")
+	errEntryLimit     = errors.New("archive has more than the -max-entries limit of entries")
+	errExpansionRatio = errors.New("decompresses to more than -max-expansion-ratio times its compressed size")
+)
+
+// isExtractAbort reports whether err stops the extraction of an archive
+// altogether, rather than failing one member. Exceeding the expansion
+// ratio only fails the member decompressed, unless it is the archive
+// itself, whose reading then fails anyway.
+func isExtractAbort(err error) bool {
+	return errors.Is(err, errExtractLimit) || errors.Is(err, errEntryLimit)
+}
+
+// addLimitFlags registers the -max-file-size, -max-extracted-bytes,
+// -max-entries, -max-expansion-ratio, -max-nesting-depth and -max-memory
+// flags on fs.
+func addLimitFlags(fs *flag.FlagSet) {
+	fs.Int64Var(&maxFileSize, "max-file-size", maxFileSize, "skip files and archive members larger than `n` bytes")
+	fs.Int64Var(&maxExtractedBytes, "max-extracted-bytes", maxExtractedBytes, "stop extracting an archive after `n` bytes")
+	fs.Int64Var(&maxArchiveEntries, "max-entries", maxArchiveEntries, "stop extracting an archive after `n` entries, counting those of nested archives")
+	fs.Int64Var(&maxExpansionRatio, "max-expansion-ratio", maxExpansionRatio, "skip archive members that decompress to more than `n` times their compressed size, 0 for no limit")
+	fs.IntVar(&maxNestingDepth, "max-nesting-depth", maxNestingDepth, "descend into archives nested up to `n` levels deep in archives")
+	fs.Int64Var(&maxMemory, "max-memory", 0, "limit the memory concurrent scans take to about `n` bytes, running fewer at a time if needed")
+}
+
+// scanReader copies the contents of r into a temporary file and looks for
+// a Go version in it.
+func scanReader(r io.Reader) (string, error) {
+	name, size, err := spillReader(r)
+	if err != nil {
+		return "", err
+	}
+	return scanSpilled(name, size)
+}
+
+// isArchive reports whether scanArchive treats name as an archive rather
+// than a single file.
+func isArchive(name string) bool {
+	lname := strings.ToLower(name)
+	for _, ext := range []string{".tar", ".tgz", ".tar.bz2", ".tbz2", ".zip", ".gz"} {
+		if strings.HasSuffix(lname, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanArchive calls fn with the result for every file contained in the
+// archive read from r, including those in the archives nested in it, in
+// order. The archive format is derived from name; anything not recognized
+// as an archive is scanned as a single file.
+func scanArchive(name string, r io.Reader, fn func(name, ver string, err error)) error {
+	x := newExtraction(fn)
+	defer x.p.wait()
+	return x.archive(name, r, 0)
+}
+
+// scanTar calls fn with the result for every regular file in the tar
+// stream r, in order, like scanArchive.
+func scanTar(r io.Reader, fn func(name, ver string, err error)) error {
+	x := newExtraction(fn)
+	defer x.p.wait()
+	return x.tar(r, "", 0)
+}
+
+// extraction is the scan of an archive and of the archives nested in it,
+// which share its limits. The archives are read sequentially, with the
+// files in them scanned concurrently by p.
+type extraction struct {
+	p *memberPipeline
+	// left is how many bytes may still be extracted. Data extracted
+	// from nested archives counts at every level.
+	left    int64
+	entries int64
+}
+
+func newExtraction(fn func(name, ver string, err error)) *extraction {
+	return &extraction{p: newMemberPipeline(fn), left: maxExtractedBytes}
+}
+
+// archive scans the archive name read from r, nested depth levels deep.
+func (x *extraction) archive(name string, r io.Reader, depth int) error {
+	lname := strings.ToLower(name)
+	prefix := name + "/"
+	switch {
+	case strings.HasSuffix(lname, ".tar"):
+		return x.tar(r, prefix, depth)
+	case strings.HasSuffix(lname, ".tar.gz"), strings.HasSuffix(lname, ".tgz"):
+		cr := &countingReader{r: r}
+		zr, err := getGzipReader(cr)
+		if err != nil {
+			return err
+		}
+		defer putGzipReader(zr)
+		return x.tar(x.decompressed(zr, cr.count), prefix, depth)
+	case strings.HasSuffix(lname, ".tar.bz2"), strings.HasSuffix(lname, ".tbz2"):
+		cr := &countingReader{r: r}
+		return x.tar(x.decompressed(bzip2.NewReader(cr), cr.count), prefix, depth)
+	case strings.HasSuffix(lname, ".zip"):
+		return x.zip(r, prefix, depth)
+	case strings.HasSuffix(lname, ".gz"):
+		cr := &countingReader{r: r}
+		zr, err := getGzipReader(cr)
+		if err != nil {
+			return err
+		}
+		defer putGzipReader(zr)
+		return x.member(name[:len(name)-3], x.decompressed(zr, cr.count), depth)
+	}
+	return x.p.add(name, r)
+}
+
+// member scans the archive member name read from r, in an archive nested
+// depth levels deep, descending into it if it is an archive itself and
+// -max-nesting-depth allows. A nested archive that can't be read is no
+// Go binary, and fails only if it exceeds the limits.
+func (x *extraction) member(name string, r io.Reader, depth int) error {
+	if depth >= maxNestingDepth || !isArchive(name) {
+		return x.p.add(name, r)
+	}
+	err := x.archive(name, r, depth+1)
+	if err != nil && !isExtractAbort(err) {
+		x.p.result(name, "", noVersionError{fmt.Errorf("reading nested archive: %v", err)})
+		return nil
+	}
+	return err
+}
+
+// entry counts an entry read from an archive against -max-entries.
+func (x *extraction) entry() error {
+	x.entries++
+	if maxArchiveEntries > 0 && x.entries > maxArchiveEntries {
+		return errEntryLimit
+	}
+	return nil
+}
+
+// tar scans the regular files in the tar stream r, nested depth levels
+// deep, named with prefix.
+func (x *extraction) tar(r io.Reader, prefix string, depth int) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := x.entry(); err != nil {
+			return err
+		}
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		if hdr.Size > maxFileSize {
+			x.p.result(prefix+hdr.Name, "", errTooLarge)
+			continue
+		}
+		if err := x.member(prefix+hdr.Name, tr, depth); err != nil {
+			return err
+		}
+	}
+}
+
+// zip scans the regular files in the zip archive read from r, like tar.
+// Zip needs random access, so r is spooled to a temporary file first.
+func (x *extraction) zip(r io.Reader, prefix string, depth int) error {
+	f, err := ioutil.TempFile("", "gover")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	size, err := io.Copy(f, r)
+	if err != nil {
+		return err
+	}
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		return err
+	}
+	for _, zf := range zr.File {
+		if err := x.entry(); err != nil {
+			return err
+		}
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+		if zf.UncompressedSize64 > uint64(maxFileSize) {
+			x.p.result(prefix+zf.Name, "", errTooLarge)
+			continue
+		}
+		rc, err := zf.Open()
+		if err != nil {
+			x.p.result(prefix+zf.Name, "", err)
+			continue
+		}
+		// The sizes in the directory can lie, so count what is
+		// actually extracted.
+		compressed := int64(zf.CompressedSize64)
+		err = x.member(prefix+zf.Name, x.decompressed(rc, func() int64 { return compressed }), depth)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decompressed returns the data zr decompresses, counted against the
+// bytes left to extract and, if in is not nil, checked against
+// -max-expansion-ratio, relative to the in() compressed bytes it was
+// decompressed from so far.
+func (x *extraction) decompressed(zr io.Reader, in func() int64) io.Reader {
+	return &extractReader{r: zr, x: x, in: in}
+}
+
+// expansionSlack is how much data may be decompressed before the
+// -max-expansion-ratio applies, as small files can compress very well.
+const expansionSlack = 1 << 20
+
+// extractReader reads data decompressed in an extraction.
+type extractReader struct {
+	r   io.Reader
+	x   *extraction
+	in  func() int64
+	out int64
+}
+
+func (e *extractReader) Read(p []byte) (int, error) {
+	if e.x.left < 0 {
+		return 0, errExtractLimit
+	}
+	if int64(len(p)) > e.x.left+1 {
+		p = p[:e.x.left+1]
+	}
+	n, err := e.r.Read(p)
+	e.out += int64(n)
+	e.x.left -= int64(n)
+	if e.x.left < 0 {
+		return n, errExtractLimit
+	}
+	if e.in != nil && maxExpansionRatio > 0 && e.out > expansionSlack && e.out > maxExpansionRatio*max(e.in(), 1) {
+		return n, errExpansionRatio
+	}
+	return n, err
+}
+
+// gzipReaders are the gzip readers of archives that were scanned, which
+// keep sizable state, for reuse by the scans that follow.
+var gzipReaders sync.Pool
+
+// getGzipReader returns a gzip reader of r, reusing one from a previous
+// scan if there is one. putGzipReader returns it.
+func getGzipReader(r io.Reader) (*gzip.Reader, error) {
+	zr, ok := gzipReaders.Get().(*gzip.Reader)
+	if !ok {
+		return gzip.NewReader(r)
+	}
+	if err := zr.Reset(r); err != nil {
+		gzipReaders.Put(zr)
+		return nil, err
+	}
+	return zr, nil
+}
+
+func putGzipReader(zr *gzip.Reader) {
+	zr.Close()
+	gzipReaders.Put(zr)
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) count() int64 {
+	return c.n
+}
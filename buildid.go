@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/hex"
+	"io"
+	"strconv"
+)
+
+// goBuildIDPrefix starts the Go build ID the linker writes at the start
+// of the text of binaries that have no note section for it.
+var goBuildIDPrefix = []byte("\xff Go build ID: \"")
+
+// elfNote returns the description of the first note named name of type
+// typ in the ELF section sec, or nil.
+func elfNote(f *elf.File, sec, name string, typ uint32) []byte {
+	s := f.Section(sec)
+	if s == nil || s.Type != elf.SHT_NOTE {
+		return nil
+	}
+	data, err := s.Data()
+	if err != nil {
+		return nil
+	}
+	// In 64 bits, so that sizes close to 4 GiB can't wrap around.
+	align := func(n uint32) uint64 { return (uint64(n) + 3) &^ 3 }
+	for len(data) >= 12 {
+		namesz := f.ByteOrder.Uint32(data)
+		descsz := f.ByteOrder.Uint32(data[4:])
+		ntype := f.ByteOrder.Uint32(data[8:])
+		if 12+align(namesz)+align(descsz) > uint64(len(data)) {
+			return nil
+		}
+		data = data[12:]
+		n := string(bytes.TrimRight(data[:namesz], "\x00"))
+		desc := data[align(namesz) : align(namesz)+uint64(descsz)]
+		if n == name && ntype == typ {
+			return desc
+		}
+		data = data[align(namesz)+align(descsz):]
+	}
+	return nil
+}
+
+// findGoBuildID returns the Go build ID of the binary, which the go
+// command uses to identify builds, or "" if it has none. ELF binaries
+// keep it in the .note.go.buildid section; otherwise it is at the start
+// of the text, which is looked for in the first 32 KiB of the text
+// section and of the file.
+func findGoBuildID(bf *binaryFile) string {
+	var text io.ReaderAt
+	switch b := bf.Binary.(type) {
+	case *elfBinary:
+		// The note type is 4, for "GO BUILDID".
+		if id := elfNote(b.File, ".note.go.buildid", "Go", 4); id != nil {
+			return string(id)
+		}
+		if s := b.Section(".text"); s != nil {
+			text = s
+		}
+	case *peBinary:
+		if s := b.Section(".text"); s != nil {
+			text = s
+		}
+	case *machoBinary:
+		if s := b.Section("__text"); s != nil {
+			text = s
+		}
+	}
+	if text != nil {
+		if id := scanGoBuildID(text); id != "" {
+			return id
+		}
+	}
+	return scanGoBuildID(bf.r)
+}
+
+// scanGoBuildID looks for the Go build ID in the first 32 KiB of r.
+func scanGoBuildID(r io.ReaderAt) string {
+	buf := make([]byte, 32<<10)
+	n, _ := r.ReadAt(buf, 0)
+	buf = buf[:n]
+	i := bytes.Index(buf, goBuildIDPrefix)
+	if i < 0 {
+		return ""
+	}
+	// The ID is quoted like a Go string and followed by "\n \xff".
+	rest := buf[i+len(goBuildIDPrefix)-1:]
+	end := bytes.Index(rest, []byte("\"\n \xff"))
+	if end < 0 {
+		return ""
+	}
+	id, err := strconv.Unquote(string(rest[:end+1]))
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+// findGNUBuildID returns the GNU build ID of an ELF binary in hex, as used
+// by debuginfod and symbol servers, or "" if it has none. Recent Go
+// releases write one by default, older ones only when linking externally
+// or with -B.
+func findGNUBuildID(bf *binaryFile) string {
+	b, ok := bf.Binary.(*elfBinary)
+	if !ok {
+		return ""
+	}
+	// The note type is 3, for NT_GNU_BUILD_ID.
+	return hex.EncodeToString(elfNote(b.File, ".note.gnu.build-id", "GNU", 3))
+}
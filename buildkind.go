@@ -0,0 +1,54 @@
+package main
+
+import "strings"
+
+// Build kinds of binaries that are not regular builds, as reported in the
+// buildKind field of scan results.
+const (
+	kindTest  = "test"  // built by go test -c
+	kindCover = "cover" // instrumented for coverage with -cover
+	kindFuzz  = "fuzz"  // instrumented for fuzzing with -d=libfuzzer
+)
+
+// findBuildKind returns the kinds of the binary b that are not a regular
+// build, joined by "+" as in "test+cover", or "" for regular builds or if
+// b is nil. Test binaries accidentally shipped in images are flagged this
+// way.
+//
+// The build info tells them: the main package of a test binary is the
+// package under test with a .test suffix, and -cover and -gcflags are
+// recorded as build settings. Without build info, the functions only
+// test mains, coverage instrumentation and fuzzing instrumentation link
+// are looked up in the pclntab instead.
+func findBuildKind(b *binaryFile) string {
+	if b == nil {
+		return ""
+	}
+	var test, cover, fuzz bool
+	if bi, err := b.BuildInfo(); err == nil {
+		test = strings.HasSuffix(bi.Path, ".test")
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "-cover":
+				cover = s.Value == "true"
+			case "-gcflags":
+				fuzz = strings.Contains(s.Value, "-d=libfuzzer")
+			}
+		}
+	} else if tab, err := b.Pclntab(); err == nil {
+		test = tab.LookupFunc("testing.MainStart") != nil
+		cover = tab.LookupFunc("internal/coverage/cfile.InitHook") != nil || tab.LookupFunc("runtime/coverage.initHook") != nil
+		fuzz = tab.LookupFunc("runtime.libfuzzerTraceCmp1") != nil
+	}
+	var kinds []string
+	if test {
+		kinds = append(kinds, kindTest)
+	}
+	if cover {
+		kinds = append(kinds, kindCover)
+	}
+	if fuzz {
+		kinds = append(kinds, kindFuzz)
+	}
+	return strings.Join(kinds, "+")
+}
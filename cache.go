@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// scannerVersion is part of every cache key. It has to be incremented
+// whenever findVersion starts to report different results for the same
+// file, so that stale cache entries are ignored.
+const scannerVersion = "5"
+
+// scanConfigKey identifies the scanner and the options that change the
+// results it reports for a file.
+func scanConfigKey() string {
+	key := scannerVersion
+	if len(plugins) > 0 {
+		// Results depend on the detectors used.
+		key += "+" + strings.Join(plugins, ",")
+	}
+	if strictMode {
+		// So do they on whether inferred versions are rejected.
+		key = "strict+" + key
+	}
+	if maxMemory > 0 {
+		// And on the memory budget, as DWARF info larger than it is
+		// not read.
+		key += "+mem" + strconv.FormatInt(maxMemory, 10)
+	}
+	return key
+}
+
+// cacheEntry is the cached scan result of one file content.
+type cacheEntry struct {
+	Key       string           `json:"key"`
+	Version   string           `json:"version,omitempty"`
+	Method    string           `json:"method,omitempty"`
+	Estimate  *versionEstimate `json:"estimate,omitempty"`
+	NoVersion string           `json:"noVersion,omitempty"`
+
+	Diagnosis []diagnosisStep `json:"diagnosis,omitempty"`
+	// Empty is set for files without data.
+	Empty *emptyFileError `json:"empty,omitempty"`
+}
+
+// scanCache remembers scan results by the SHA-256 of the file contents,
+// so that identical binaries, such as those duplicated across container
+// layers, are only scanned once. Like the result store it is kept as one
+// JSON record per line. A nil *scanCache scans every file.
+type scanCache struct {
+	mu      sync.Mutex
+	f       *os.File
+	entries map[string]cacheEntry
+}
+
+// defaultCachePath returns the location of the scan cache in the user's
+// cache directory.
+func defaultCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gover", "scan-cache"), nil
+}
+
+func openScanCache(name string) (*scanCache, error) {
+	if err := os.MkdirAll(filepath.Dir(name), 0777); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	c := &scanCache{f: f, entries: make(map[string]cacheEntry)}
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		var e cacheEntry
+		// Skip lines that are corrupt, e.g. cut short by a crash.
+		if json.Unmarshal(s.Bytes(), &e) == nil {
+			c.entries[e.Key] = e
+		}
+	}
+	if err := s.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *scanCache) Close() error {
+	if c == nil {
+		return nil
+	}
+	return c.f.Close()
+}
+
+// findVersion is like the package level findVersion, but returns the
+// cached result if a file with the same contents was scanned before.
+// Only versions and the absence of one are cached; errors reading the
+// file are not.
+func (c *scanCache) findVersion(file string) (string, error) {
+	return c.findVersionTimed(file, nil)
+}
+
+// hashed adds hashing file for the cache key to t, for a scan that
+// started at start.
+func hashed(t *scanTiming, file string, start time.Time) {
+	t.Seconds = time.Since(start).Seconds()
+	if fi, err := os.Stat(longPath(file)); err == nil {
+		t.BytesRead += fi.Size()
+	}
+}
+
+// findVersionTimed wraps the package level findVersionTimed with the
+// cache: it is c.findVersion, recording the time taken in t unless t is
+// nil.
+func (c *scanCache) findVersionTimed(file string, t *scanTiming) (string, error) {
+	ver, b, _, err := c.openVersion(file, t)
+	if b != nil {
+		b.Close()
+	}
+	return ver, err
+}
+
+// openVersion is c.findVersionTimed, but like the package level
+// openVersion also returns the binary the scan parsed, which is nil for
+// cached results, and the SHA-256 of the file if it was hashed for the
+// cache key.
+func (c *scanCache) openVersion(file string, t *scanTiming) (ver string, b *binaryFile, sum string, err error) {
+	if c == nil {
+		ver, b, err = openVersion(file, t)
+		return ver, b, "", err
+	}
+	start := time.Now()
+	sum, err = hashFile(file)
+	if err != nil {
+		ver, b, err = openVersion(file, t)
+		return ver, b, "", err
+	}
+	key := scanConfigKey() + ":" + sum
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	observeCache(ok)
+	if ok {
+		slog.Debug("cache hit", "file", file, "sha256", sum)
+		if t != nil {
+			t.Cached = true
+			hashed(t, file, start)
+		}
+		if e.Empty != nil {
+			return "", nil, sum, noVersionError{e.Empty}
+		}
+		if e.Diagnosis != nil {
+			return "", nil, sum, noVersionError{&diagnosisError{steps: e.Diagnosis}}
+		}
+		if e.NoVersion != "" {
+			return "", nil, sum, noVersionError{errors.New(e.NoVersion)}
+		}
+		if t != nil {
+			t.Method, t.Estimate = e.Method, e.Estimate
+		}
+		return e.Version, nil, sum, nil
+	}
+
+	ver, b, err = openVersion(file, t)
+	if t != nil {
+		hashed(t, file, start)
+	}
+	e = cacheEntry{Key: key, Version: ver}
+	if t != nil && inferredMethods[t.Method] {
+		e.Method, e.Estimate = t.Method, t.Estimate
+	}
+	if err != nil {
+		if !isNoVersion(err) {
+			return ver, b, sum, err
+		}
+		e.NoVersion = err.Error()
+		e.Diagnosis = scanDiagnosis(err)
+		errors.As(err, &e.Empty)
+	}
+	line, _ := json.Marshal(e)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = e
+	if _, werr := c.f.Write(append(line, '\n')); werr != nil {
+		slog.Warn("writing scan cache failed", "err", werr)
+	}
+	return ver, b, sum, err
+}
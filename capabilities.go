@@ -0,0 +1,55 @@
+package main
+
+import (
+	"debug/gosym"
+	"strings"
+)
+
+// capability is a notable use of the standard library, detected by any
+// of the packages or functions listed being linked into a binary.
+// Package paths ending in "/" match all packages below them.
+type capability struct {
+	name  string
+	pkgs  []string
+	funcs []string
+}
+
+// capabilities are the hints "gover info" gives to classify unknown
+// binaries. As they are inferred from the pclntab, only code the linker
+// kept counts, and packages without functions of their own are seen
+// through their effects: unsafe through cgo and golang.org/x/sys, which
+// are built on it, and reflect through the method calls by name that
+// make the linker keep all exported methods, as plain uses of reflect
+// are in nearly every binary by way of fmt.
+var capabilities = []capability{
+	{name: "http-server", funcs: []string{"net/http.(*Server).Serve", "net/http.(*conn).serve"}},
+	{name: "tls", pkgs: []string{"crypto/tls"}},
+	{name: "exec", pkgs: []string{"os/exec"}},
+	{name: "unsafe", pkgs: []string{"runtime/cgo", "golang.org/x/sys/"}},
+	{name: "reflect", funcs: []string{"reflect.Value.Call", "reflect.Value.MethodByName", "reflect.(*rtype).MethodByName"}},
+	{name: "plugin", pkgs: []string{"plugin"}},
+}
+
+// findCapabilities returns the names of the capabilities of the binary
+// with the pclntab tab.
+func findCapabilities(tab *gosym.Table) []string {
+	pkgs := linkedPackages(tab)
+	var caps []string
+	for _, c := range capabilities {
+		found := false
+		for _, want := range c.pkgs {
+			for _, pkg := range pkgs {
+				if pkg == want || strings.HasSuffix(want, "/") && strings.HasPrefix(pkg, want) {
+					found = true
+				}
+			}
+		}
+		for _, fn := range c.funcs {
+			found = found || tab.LookupFunc(fn) != nil
+		}
+		if found {
+			caps = append(caps, c.name)
+		}
+	}
+	return caps
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"crypto/sha256"
+	"debug/buildinfo"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// catalogFormats are the -compat formats that write one document for all
+// results, in the shape the Go binary catalogers of other tools produce,
+// so that pipelines consuming those can take gover's results instead.
+var catalogFormats = map[string]func(results []scanResult) interface{}{
+	"syft":  syftDocumentOf,
+	"trivy": trivyReportOf,
+}
+
+// catalogModule is a Go module compiled into a binary, or the standard
+// library, as listed by catalogs.
+type catalogModule struct {
+	path, version, sum string
+	main, std          bool
+}
+
+// catalogModules returns the modules of the binary res describes, main
+// module first, then the standard library and the dependencies. Binaries
+// without build info only list the standard library.
+func catalogModules(res scanResult) []catalogModule {
+	var mods []catalogModule
+	bi := res.buildInfo
+	if bi != nil && bi.Main.Path != "" {
+		mods = append(mods, catalogModule{path: bi.Main.Path, version: bi.Main.Version, sum: bi.Main.Sum, main: true})
+	}
+	mods = append(mods, catalogModule{path: "stdlib", version: res.Version, std: true})
+	if bi != nil {
+		for _, m := range bi.Deps {
+			path, sum := m.Path, m.Sum
+			if m.Replace != nil && m.Replace.Version != "" {
+				path, sum = m.Replace.Path, m.Replace.Sum
+			}
+			mods = append(mods, catalogModule{path: path, version: depVersion(m), sum: sum})
+		}
+	}
+	return mods
+}
+
+// stdlibVersion returns the Go version ver without its "go" prefix and
+// trailing annotations, as package URLs of the standard library have it.
+func stdlibVersion(ver string) string {
+	ver = strings.TrimPrefix(ver, "go")
+	if i := strings.IndexAny(ver, " "); i >= 0 {
+		ver = ver[:i]
+	}
+	return ver
+}
+
+// catalogName returns what results of the files given were found in: the
+// file itself if there is one, or the directory all are in.
+func catalogName(results []scanResult) string {
+	if len(results) == 1 {
+		return results[0].File
+	}
+	dir := ""
+	for i, res := range results {
+		d := filepath.Dir(res.File)
+		if i == 0 {
+			dir = d
+			continue
+		}
+		for dir != d && !strings.HasPrefix(d, strings.TrimSuffix(dir, string(filepath.Separator))+string(filepath.Separator)) {
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+	if dir == "" {
+		return "."
+	}
+	return dir
+}
+
+// syftDocument is the subset of the Syft JSON format, schema 16, that the
+// Syft Go module binary cataloger fills in.
+type syftDocument struct {
+	Artifacts             []syftArtifact     `json:"artifacts"`
+	ArtifactRelationships []syftRelationship `json:"artifactRelationships"`
+	Source                syftSource         `json:"source"`
+	Distro                struct{}           `json:"distro"`
+	Descriptor            struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	} `json:"descriptor"`
+	Schema struct {
+		Version string `json:"version"`
+		URL     string `json:"url"`
+	} `json:"schema"`
+}
+
+type syftArtifact struct {
+	ID           string         `json:"id"`
+	Name         string         `json:"name"`
+	Version      string         `json:"version"`
+	Type         string         `json:"type"`
+	FoundBy      string         `json:"foundBy"`
+	Locations    []syftLocation `json:"locations"`
+	Licenses     []string       `json:"licenses"`
+	Language     string         `json:"language"`
+	CPEs         []syftCPE      `json:"cpes"`
+	PURL         string         `json:"purl"`
+	MetadataType string         `json:"metadataType"`
+	Metadata     syftGoMetadata `json:"metadata"`
+}
+
+type syftLocation struct {
+	Path       string `json:"path"`
+	AccessPath string `json:"accessPath"`
+}
+
+type syftCPE struct {
+	CPE    string `json:"cpe"`
+	Source string `json:"source"`
+}
+
+type syftGoMetadata struct {
+	GoBuildSettings   []syftKeyValue `json:"goBuildSettings,omitempty"`
+	GoCompiledVersion string         `json:"goCompiledVersion"`
+	Architecture      string         `json:"architecture"`
+	H1Digest          string         `json:"h1Digest,omitempty"`
+	MainModule        string         `json:"mainModule,omitempty"`
+}
+
+type syftKeyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type syftRelationship struct {
+	Parent string `json:"parent"`
+	Child  string `json:"child"`
+	Type   string `json:"type"`
+}
+
+type syftSource struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Type     string `json:"type"`
+	Metadata struct {
+		Path string `json:"path"`
+	} `json:"metadata"`
+}
+
+// syftID derives the ID of an artifact from what identifies it, as Syft
+// does, so that the same binary gets the same IDs on every scan.
+func syftID(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// syftDocumentOf returns the Syft document of results. Every module is an
+// artifact located in the binary it was found in; the dependencies of a
+// binary are related to its main module by dependency-of relationships.
+func syftDocumentOf(results []scanResult) interface{} {
+	doc := &syftDocument{Artifacts: []syftArtifact{}, ArtifactRelationships: []syftRelationship{}}
+	for _, res := range results {
+		var settings []syftKeyValue
+		mainPath := ""
+		if bi := res.buildInfo; bi != nil {
+			for _, s := range bi.Settings {
+				settings = append(settings, syftKeyValue{s.Key, s.Value})
+			}
+			mainPath = bi.Main.Path
+		}
+		mainID := ""
+		for _, m := range catalogModules(res) {
+			a := syftArtifact{
+				ID:           syftID(res.File, m.path, m.version),
+				Name:         m.path,
+				Version:      m.version,
+				Type:         "go-module",
+				FoundBy:      "go-module-binary-cataloger",
+				Locations:    []syftLocation{{Path: res.File, AccessPath: res.File}},
+				Licenses:     []string{},
+				Language:     "go",
+				CPEs:         []syftCPE{},
+				MetadataType: "go-module-buildinfo-entry",
+				Metadata: syftGoMetadata{
+					GoCompiledVersion: res.Version,
+					Architecture:      res.Arch,
+					H1Digest:          m.sum,
+					MainModule:        mainPath,
+				},
+			}
+			switch {
+			case m.std:
+				std := stdlibVersion(m.version)
+				a.PURL = golangPURL("stdlib", std)
+				a.CPEs = append(a.CPEs, syftCPE{CPE: "cpe:2.3:a:golang:go:" + std + ":-:*:*:*:*:*:*", Source: "syft-generated"})
+			case m.main:
+				a.Metadata.GoBuildSettings = settings
+				mainID = a.ID
+				fallthrough
+			default:
+				a.PURL = golangPURL(m.path, m.version)
+			}
+			doc.Artifacts = append(doc.Artifacts, a)
+			if mainID != "" && a.ID != mainID {
+				doc.ArtifactRelationships = append(doc.ArtifactRelationships, syftRelationship{Parent: a.ID, Child: mainID, Type: "dependency-of"})
+			}
+		}
+	}
+	name := catalogName(results)
+	doc.Source.ID = syftID(name)
+	doc.Source.Name = name
+	doc.Source.Type = "directory"
+	if len(results) == 1 {
+		doc.Source.Type = "file"
+	}
+	doc.Source.Metadata.Path = name
+	doc.Descriptor.Name = "gover"
+	doc.Descriptor.Version = selfVersion()
+	doc.Schema.Version = "16.0.0"
+	doc.Schema.URL = "https://raw.githubusercontent.com/anchore/syft/main/schema/json/schema-16.0.0.json"
+	return doc
+}
+
+// trivyReport is the subset of the Trivy JSON report, schema version 2,
+// that Trivy fills in for the Go binaries it scans.
+type trivyReport struct {
+	SchemaVersion int           `json:"SchemaVersion"`
+	CreatedAt     string        `json:"CreatedAt"`
+	ArtifactName  string        `json:"ArtifactName"`
+	ArtifactType  string        `json:"ArtifactType"`
+	Results       []trivyResult `json:"Results"`
+}
+
+type trivyResult struct {
+	Target   string         `json:"Target"`
+	Class    string         `json:"Class"`
+	Type     string         `json:"Type"`
+	Packages []trivyPackage `json:"Packages"`
+}
+
+type trivyPackage struct {
+	ID         string `json:"ID"`
+	Name       string `json:"Name"`
+	Identifier struct {
+		PURL string `json:"PURL"`
+	} `json:"Identifier"`
+	Version      string   `json:"Version"`
+	Relationship string   `json:"Relationship"`
+	DependsOn    []string `json:"DependsOn,omitempty"`
+}
+
+// trivyReportOf returns the Trivy report of results, with one result of
+// class lang-pkgs per binary. Like Trivy, it lists the main module as the
+// root that all others are dependencies of, with an unknown relationship
+// as binaries don't tell direct dependencies from indirect ones, and the
+// standard library with a "v" version.
+func trivyReportOf(results []scanResult) interface{} {
+	rep := &trivyReport{
+		SchemaVersion: 2,
+		CreatedAt:     time.Now().UTC().Format(time.RFC3339Nano),
+		ArtifactName:  catalogName(results),
+		ArtifactType:  "filesystem",
+		Results:       []trivyResult{},
+	}
+	if len(results) == 1 {
+		rep.ArtifactType = "file"
+	}
+	for _, res := range results {
+		tr := trivyResult{Target: res.File, Class: "lang-pkgs", Type: "gobinary", Packages: []trivyPackage{}}
+		for _, m := range catalogModules(res) {
+			p := trivyPackage{Name: m.path, Version: m.version, Relationship: "unknown"}
+			switch {
+			case m.std:
+				p.Version = "v" + stdlibVersion(m.version)
+			case m.main:
+				p.Relationship = "root"
+				if p.Version == "(devel)" {
+					p.Version = ""
+				}
+			}
+			p.ID = p.Name
+			switch {
+			case m.std:
+				p.ID += "@" + p.Version
+				p.Identifier.PURL = golangPURL("stdlib", stdlibVersion(m.version))
+			case p.Version != "":
+				p.ID += "@" + p.Version
+				p.Identifier.PURL = golangPURL(p.Name, p.Version)
+			}
+			tr.Packages = append(tr.Packages, p)
+		}
+		if root := &tr.Packages[0]; root.Relationship == "root" {
+			for _, p := range tr.Packages[1:] {
+				root.DependsOn = append(root.DependsOn, p.ID)
+			}
+		}
+		rep.Results = append(rep.Results, tr)
+	}
+	return rep
+}
+
+// writeCatalog writes the results in the -compat format of r as one
+// document.
+func (r *reporter) writeCatalog() {
+	b, err := json.MarshalIndent(catalogFormats[r.compat](r.results), "", "  ")
+	if err != nil {
+		slog.Error("encoding results failed", "err", err)
+		r.exit = worseExit(r.exit, exitError)
+		return
+	}
+	r.stdout().Write(append(b, '\n'))
+}
+
+// catalogBuildInfo returns the build info of b for catalogs, or nil.
+func catalogBuildInfo(b *binaryFile) *buildinfo.BuildInfo {
+	if b == nil {
+		return nil
+	}
+	bi, err := b.BuildInfo()
+	if err != nil {
+		return nil
+	}
+	return bi
+}
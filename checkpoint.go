@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// checkpointEntry is the result of scanning one file in a checkpoint.
+// Key is the scanConfigKey of the scan that produced it.
+type checkpointEntry struct {
+	Key       string           `json:"key"`
+	Path      string           `json:"path"`
+	Size      int64            `json:"size"`
+	ModTime   time.Time        `json:"modTime"`
+	Version   string           `json:"version,omitempty"`
+	Method    string           `json:"method,omitempty"`
+	Estimate  *versionEstimate `json:"estimate,omitempty"`
+	NoVersion string           `json:"noVersion,omitempty"`
+	Diagnosis []diagnosisStep  `json:"diagnosis,omitempty"`
+	Empty     *emptyFileError  `json:"empty,omitempty"`
+}
+
+// scanCheckpoint records the progress of a scan, so that a scan that was
+// interrupted can be resumed with the files scanned before taken from it
+// rather than scanned again. Unlike the scan cache, files are recognized
+// by path, size and modification time, without reading them, which is
+// what takes the time in large trees. It is kept as one JSON record per
+// line, appended to as files are scanned, and removed when the scan
+// completes. A nil *scanCheckpoint records nothing.
+type scanCheckpoint struct {
+	mu   sync.Mutex
+	name string
+	f    *os.File
+	done map[string]checkpointEntry
+}
+
+// openCheckpoint opens the checkpoint name, creating it if it doesn't
+// exist. Results of scans with other options are ignored.
+func openCheckpoint(name string) (*scanCheckpoint, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	c := &scanCheckpoint{name: name, f: f, done: make(map[string]checkpointEntry)}
+	key := scanConfigKey()
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		var e checkpointEntry
+		// The last line may have been cut short by the interruption.
+		if json.Unmarshal(s.Bytes(), &e) == nil && e.Key == key {
+			c.done[e.Path] = e
+		}
+	}
+	if err := s.Err(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if len(c.done) > 0 {
+		slog.Info("resuming from checkpoint", "file", name, "scanned", len(c.done))
+	}
+	return c, nil
+}
+
+// lookup returns the result recorded for file, with the info fi, if it is
+// unchanged since.
+func (c *scanCheckpoint) lookup(file string, fi os.FileInfo) (checkpointEntry, bool) {
+	if c == nil || fi == nil {
+		return checkpointEntry{}, false
+	}
+	c.mu.Lock()
+	e, ok := c.done[absPath(file)]
+	c.mu.Unlock()
+	if !ok || e.Size != fi.Size() || !e.ModTime.Equal(fi.ModTime()) {
+		return checkpointEntry{}, false
+	}
+	return e, true
+}
+
+// result returns the version and error of the scan e records, with the
+// method of inferred versions in t.
+func (e checkpointEntry) result(t *scanTiming) (string, error) {
+	t.Cached = true
+	switch {
+	case e.Empty != nil:
+		return "", noVersionError{e.Empty}
+	case e.Diagnosis != nil:
+		return "", noVersionError{&diagnosisError{steps: e.Diagnosis}}
+	case e.NoVersion != "":
+		return "", noVersionError{errors.New(e.NoVersion)}
+	}
+	t.Method, t.Estimate = e.Method, e.Estimate
+	return e.Version, nil
+}
+
+// record appends the result of scanning file, with the info fi. Only
+// versions and the absence of one are recorded, like in the scan cache;
+// files that failed are scanned again when the scan is resumed.
+func (c *scanCheckpoint) record(file string, fi os.FileInfo, ver string, err error, t *scanTiming) {
+	if c == nil || fi == nil || err != nil && !isNoVersion(err) {
+		return
+	}
+	e := checkpointEntry{Key: scanConfigKey(), Path: absPath(file), Size: fi.Size(), ModTime: fi.ModTime().UTC(), Version: ver}
+	if t != nil && inferredMethods[t.Method] {
+		e.Method, e.Estimate = t.Method, t.Estimate
+	}
+	if err != nil {
+		e.NoVersion = err.Error()
+		e.Diagnosis = scanDiagnosis(err)
+		errors.As(err, &e.Empty)
+	}
+	b, _ := json.Marshal(e)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.done[e.Path] = e
+	if _, werr := c.f.Write(append(b, '\n')); werr != nil {
+		slog.Warn("writing checkpoint failed", "err", werr)
+	}
+}
+
+// finish removes the checkpoint of a scan that completed.
+func (c *scanCheckpoint) finish() {
+	if c == nil {
+		return
+	}
+	c.f.Close()
+	if err := os.Remove(c.name); err != nil {
+		slog.Warn("removing checkpoint failed", "err", err)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+	"runtime"
+)
+
+const (
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorReset = "\x1b[0m"
+)
+
+func addColorFlag(fs *flag.FlagSet) *string {
+	return fs.String("color", "auto", "colorize versions by policy status: `auto`, always or never")
+}
+
+// useColor decides whether output to w is colorized. In auto mode that is
+// the case if w is a terminal and NO_COLOR is not set.
+func useColor(mode string, w io.Writer) bool {
+	checkFlagValue("color", mode)
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	// The Windows console only interprets escape sequences when asked
+	// to, which needs more than the standard library offers.
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" || runtime.GOOS == "windows" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// colorize wraps a version in red if it is end of life and in green
+// otherwise.
+func colorize(ver string, eol bool) string {
+	if eol {
+		return colorRed + ver + colorReset
+	}
+	return colorGreen + ver + colorReset
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sort"
+)
+
+// buildFacts flattens what is known about how a binary was built into
+// named facts: the toolchain version, main package and module, module
+// dependencies and build settings (including the VCS revision).
+func buildFacts(file string) (map[string]string, error) {
+	facts := make(map[string]string)
+	bi, err := readBuildInfo(file)
+	if err != nil {
+		// Binaries before Go 1.13 carry no module information, but
+		// the toolchain version can still be compared.
+		ver, verr := findVersion(file)
+		if verr != nil {
+			return nil, verr
+		}
+		facts["go"] = ver
+		return facts, nil
+	}
+	facts["go"] = bi.GoVersion
+	facts["path"] = bi.Path
+	if bi.Main.Path != "" {
+		facts["mod"] = bi.Main.Path + " " + moduleString(&bi.Main)
+	}
+	for _, m := range bi.Deps {
+		facts["dep "+m.Path] = moduleString(m)
+	}
+	for _, s := range bi.Settings {
+		facts["build "+s.Key] = s.Value
+	}
+	return facts, nil
+}
+
+// moduleString formats m as version, following a replacement if any.
+func moduleString(m *debug.Module) string {
+	s := m.Version
+	if m.Replace != nil {
+		s += " => " + m.Replace.Path
+		if m.Replace.Version != "" {
+			s += " " + m.Replace.Version
+		}
+	}
+	if m.Sum != "" && m.Replace == nil {
+		s += " " + m.Sum
+	}
+	return s
+}
+
+// compareMain implements "gover compare", which contrasts how two
+// binaries were built. It exits 0 if they match and 3 if they differ,
+// as for a policy that they must match.
+func compareMain(args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	fs.Usage = usage
+	all := fs.Bool("a", false, "also print facts that are the same in both binaries")
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	files := parseArgs(fs, args)
+	setup()
+	if len(files) != 2 {
+		usage()
+	}
+
+	a, err := buildFacts(files[0])
+	if err != nil {
+		slog.Error("scan failed", "file", files[0], "err", err)
+		return readExit(err)
+	}
+	b, err := buildFacts(files[1])
+	if err != nil {
+		slog.Error("scan failed", "file", files[1], "err", err)
+		return readExit(err)
+	}
+
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return factOrder(keys[i]) < factOrder(keys[j]) ||
+			factOrder(keys[i]) == factOrder(keys[j]) && keys[i] < keys[j]
+	})
+
+	fmt.Printf("--- %s\n+++ %s\n", files[0], files[1])
+	exit := 0
+	for _, k := range keys {
+		va, oka := a[k]
+		vb, okb := b[k]
+		switch {
+		case !okb:
+			fmt.Printf("- %s %s\n", k, va)
+		case !oka:
+			fmt.Printf("+ %s %s\n", k, vb)
+		case va != vb:
+			fmt.Printf("~ %s %s -> %s\n", k, va, vb)
+		default:
+			if *all {
+				fmt.Printf("  %s %s\n", k, va)
+			}
+			continue
+		}
+		exit = exitViolation
+	}
+	return exit
+}
+
+// factOrder sorts the toolchain first, then the main package, settings
+// and dependencies.
+func factOrder(key string) int {
+	switch {
+	case key == "go":
+		return 0
+	case key == "path" || key == "mod":
+		return 1
+	case len(key) > 6 && key[:6] == "build ":
+		return 2
+	}
+	return 3
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+)
+
+// flagValues lists the values of the flags that only accept a fixed set,
+// by flag name. The flags are checked against it with checkFlagValue, and
+// it is what their values are completed from. -preset takes a list of
+// its values.
+var flagValues = map[string][]string{
+	"color":      {"auto", "always", "never"},
+	"digest":     {"sha256", "sha512"},
+	"compat":     {"go-version", "syft", "trivy"},
+	"on-error":   {"skip", "fail"},
+	"preset":     presetNames(),
+	"progress":   {"text", "json"},
+	"log-format": {"text", "json"},
+	"log-level":  {"debug", "info", "warn", "error"},
+	"sort":       {"version", "path"},
+	"group-by":   {"version", "arch", "dir"},
+}
+
+// isFlagValue reports whether v is one of the flagValues of the flag name.
+func isFlagValue(name, v string) bool {
+	for _, fv := range flagValues[name] {
+		if v == fv {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFlagValue exits with the usage message unless the value v of the
+// flag name is one of its flagValues, or is empty, which leaves the flag
+// unset.
+func checkFlagValue(name, v string) {
+	if v != "" && !isFlagValue(name, v) {
+		fmt.Fprintf(os.Stderr, "gover: invalid -%s %q, want one of %s\n", name, v, strings.Join(flagValues[name], ", "))
+		usage()
+	}
+}
+
+// commandArgs lists the positional arguments of the subcommands that
+// don't take files.
+var commandArgs = map[string][]string{
+	"completion": {"bash", "zsh", "fish", "powershell"},
+}
+
+// completionFlag describes a flag of a subcommand. Boolean flags with
+// values, like -progress, only take them as in -progress=json.
+type completionFlag struct {
+	name   string
+	usage  string
+	arg    string // name of the value, or "" for boolean flags
+	values []string
+}
+
+// words returns the words completing to f: the flag, and for boolean
+// flags with values, the flag with each of them.
+func (f completionFlag) words() []string {
+	words := []string{"-" + f.name}
+	if f.arg == "" {
+		for _, v := range f.values {
+			words = append(words, "-"+f.name+"="+v)
+		}
+	}
+	return words
+}
+
+// completionCommand describes a subcommand and its flags.
+type completionCommand struct {
+	name  string
+	flags []completionFlag
+	args  []string
+}
+
+// collectFlags, if set, is passed the flag set of a subcommand by parseArgs,
+// which then stops the command instead of parsing its arguments.
+var collectFlags func(fs *flag.FlagSet)
+
+// commandFlags returns the flag set defined by the subcommand cmd. The
+// command is run in a goroutine that exits once it has defined its flags.
+func commandFlags(cmd func(args []string) int) *flag.FlagSet {
+	var fs *flag.FlagSet
+	collectFlags = func(f *flag.FlagSet) { fs = f }
+	defer func() { collectFlags = nil }()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cmd(nil)
+	}()
+	<-done
+	return fs
+}
+
+// completionCommands describes all subcommands, sorted by name.
+func completionCommands() []completionCommand {
+	var cmds []completionCommand
+	for name, cmd := range commands {
+		c := completionCommand{name: name, args: commandArgs[name]}
+		if fs := commandFlags(cmd); fs != nil {
+			fs.VisitAll(func(f *flag.Flag) {
+				arg, usage := flag.UnquoteUsage(f)
+				if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+					arg = ""
+				}
+				c.flags = append(c.flags, completionFlag{f.Name, usage, arg, flagValues[f.Name]})
+			})
+		}
+		cmds = append(cmds, c)
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].name < cmds[j].name })
+	return cmds
+}
+
+// completionMain implements "gover completion": it prints a completion
+// script for the given shell.
+func completionMain(args []string) int {
+	fs := flag.NewFlagSet("completion", flag.ExitOnError)
+	fs.Usage = usage
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	shells := parseArgs(fs, args)
+	setup()
+	if len(shells) != 1 {
+		usage()
+	}
+
+	cmds := completionCommands()
+	switch shells[0] {
+	case "bash":
+		bashCompletion(os.Stdout, cmds)
+	case "zsh":
+		zshCompletion(os.Stdout, cmds)
+	case "fish":
+		fishCompletion(os.Stdout, cmds)
+	case "powershell":
+		powershellCompletion(os.Stdout, cmds)
+	default:
+		fmt.Fprintf(os.Stderr, "gover: unsupported shell %q\n", shells[0])
+		return exitUsage
+	}
+	return 0
+}
+
+func init() {
+	// Registered here as completionMain refers to commands.
+	commands["completion"] = completionMain
+}
+
+func commandNames(cmds []completionCommand) string {
+	var names []string
+	for _, c := range cmds {
+		names = append(names, c.name)
+	}
+	return strings.Join(names, " ")
+}
+
+func bashCompletion(w io.Writer, cmds []completionCommand) {
+	names := commandNames(cmds)
+	fmt.Fprintf(w, "# bash completion for gover, generated by \"gover completion bash\".\n")
+	fmt.Fprintf(w, "_gover() {\n")
+	fmt.Fprintf(w, "\tlocal cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}\n")
+	fmt.Fprintf(w, "\tlocal cmd=scan flags words=\n")
+	fmt.Fprintf(w, "\tcase ${COMP_WORDS[1]} in\n")
+	fmt.Fprintf(w, "\t%s) ((COMP_CWORD > 1)) && cmd=${COMP_WORDS[1]} ;;\n", strings.Replace(names, " ", "|", -1))
+	fmt.Fprintf(w, "\tesac\n")
+	fmt.Fprintf(w, "\tcase $cmd in\n")
+	for _, c := range cmds {
+		var flags []string
+		fmt.Fprintf(w, "\t%s)\n", c.name)
+		fmt.Fprintf(w, "\t\tcase $prev in\n")
+		for _, f := range c.flags {
+			flags = append(flags, f.words()...)
+			switch {
+			case f.arg == "":
+			case f.values != nil:
+				fmt.Fprintf(w, "\t\t-%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", f.name, strings.Join(f.values, " "))
+			case f.arg == "file":
+				fmt.Fprintf(w, "\t\t-%s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", f.name)
+			default:
+				fmt.Fprintf(w, "\t\t-%s) return ;;\n", f.name)
+			}
+		}
+		fmt.Fprintf(w, "\t\tesac\n")
+		fmt.Fprintf(w, "\t\tflags=%q", strings.Join(flags, " "))
+		if c.args != nil {
+			fmt.Fprintf(w, " words=%q", strings.Join(c.args, " "))
+		}
+		fmt.Fprintf(w, " ;;\n")
+	}
+	fmt.Fprintf(w, "\tesac\n")
+	fmt.Fprintf(w, "\tif [[ $cur == -* ]]; then\n")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W \"$flags\" -- \"$cur\"))\n")
+	fmt.Fprintf(w, "\telif ((COMP_CWORD == 1)); then\n")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\") $(compgen -f -- \"$cur\"))\n", names)
+	fmt.Fprintf(w, "\telif [[ -n $words ]]; then\n")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	fmt.Fprintf(w, "\telse\n")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -f -- \"$cur\"))\n")
+	fmt.Fprintf(w, "\tfi\n")
+	fmt.Fprintf(w, "}\n")
+	fmt.Fprintf(w, "complete -o filenames -F _gover gover\n")
+}
+
+// zshQuote quotes s for the shell.
+func zshQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func zshCompletion(w io.Writer, cmds []completionCommand) {
+	names := commandNames(cmds)
+	fmt.Fprintf(w, "#compdef gover\n")
+	fmt.Fprintf(w, "# zsh completion for gover, generated by \"gover completion zsh\".\n")
+	fmt.Fprintf(w, "_gover() {\n")
+	fmt.Fprintf(w, "\tlocal -a cmds=(%s)\n", names)
+	fmt.Fprintf(w, "\tlocal cmd=scan\n")
+	fmt.Fprintf(w, "\tif ((CURRENT > 2)) && ((${cmds[(Ie)$words[2]]})); then\n")
+	fmt.Fprintf(w, "\t\tcmd=$words[2]\n")
+	fmt.Fprintf(w, "\t\tshift words\n")
+	fmt.Fprintf(w, "\t\t((CURRENT--))\n")
+	fmt.Fprintf(w, "\telif ((CURRENT == 2)) && [[ $words[2] != -* ]]; then\n")
+	fmt.Fprintf(w, "\t\t_alternative 'commands:command:(%s)' 'files:file:_files'\n", names)
+	fmt.Fprintf(w, "\t\treturn\n")
+	fmt.Fprintf(w, "\tfi\n")
+	fmt.Fprintf(w, "\tcase $cmd in\n")
+	for _, c := range cmds {
+		fmt.Fprintf(w, "\t%s)\n", c.name)
+		fmt.Fprintf(w, "\t\t_arguments")
+		for _, f := range c.flags {
+			// Brackets and colons in descriptions have to be escaped.
+			desc := strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(f.usage)
+			spec := "-" + f.name + "[" + desc + "]"
+			switch {
+			case f.arg == "" && f.values != nil:
+				spec = "-" + f.name + "=-[" + desc + "]::value:(" + strings.Join(f.values, " ") + ")"
+			case f.arg == "":
+			case f.values != nil:
+				spec += ":" + f.arg + ":(" + strings.Join(f.values, " ") + ")"
+			case f.arg == "file":
+				spec += ":file:_files"
+			default:
+				spec += ":" + f.arg + ": "
+			}
+			fmt.Fprintf(w, " \\\n\t\t\t%s", zshQuote(spec))
+		}
+		if c.args != nil {
+			fmt.Fprintf(w, " \\\n\t\t\t'*:arg:(%s)'", strings.Join(c.args, " "))
+		} else {
+			fmt.Fprintf(w, " \\\n\t\t\t'*:file:_files'")
+		}
+		fmt.Fprintf(w, " ;;\n")
+	}
+	fmt.Fprintf(w, "\tesac\n")
+	fmt.Fprintf(w, "}\n")
+	fmt.Fprintf(w, "if [[ $funcstack[1] == _gover ]]; then\n")
+	fmt.Fprintf(w, "\t_gover \"$@\"\n")
+	fmt.Fprintf(w, "else\n")
+	fmt.Fprintf(w, "\tcompdef _gover gover\n")
+	fmt.Fprintf(w, "fi\n")
+}
+
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+func fishCompletion(w io.Writer, cmds []completionCommand) {
+	names := commandNames(cmds)
+	fmt.Fprintf(w, "# fish completion for gover, generated by \"gover completion fish\".\n")
+	fmt.Fprintf(w, "complete -c gover -n __fish_use_subcommand -a %s\n", fishQuote(names))
+	for _, c := range cmds {
+		cond := "__fish_seen_subcommand_from " + c.name
+		if c.name == "scan" {
+			// Without a subcommand, the scan flags apply.
+			cond += "; or not __fish_seen_subcommand_from " + names
+		}
+		for _, f := range c.flags {
+			fmt.Fprintf(w, "complete -c gover -n %s -o %s -d %s", fishQuote(cond), f.name, fishQuote(f.usage))
+			switch {
+			case f.arg == "" && f.values != nil:
+				fmt.Fprintf(w, "\ncomplete -c gover -n %s -f -a %s", fishQuote(cond), fishQuote(strings.Join(f.words()[1:], " ")))
+			case f.arg == "":
+			case f.values != nil:
+				fmt.Fprintf(w, " -x -a %s", fishQuote(strings.Join(f.values, " ")))
+			case f.arg == "file":
+				fmt.Fprintf(w, " -r -F")
+			default:
+				fmt.Fprintf(w, " -x")
+			}
+			fmt.Fprintf(w, "\n")
+		}
+		if c.args != nil {
+			fmt.Fprintf(w, "complete -c gover -n %s -f -a %s\n", fishQuote(cond), fishQuote(strings.Join(c.args, " ")))
+		}
+	}
+}
+
+func powershellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
+
+func powershellCompletion(w io.Writer, cmds []completionCommand) {
+	fmt.Fprintf(w, "# PowerShell completion for gover, generated by \"gover completion powershell\".\n")
+	fmt.Fprintf(w, "Register-ArgumentCompleter -Native -CommandName gover -ScriptBlock {\n")
+	fmt.Fprintf(w, "\tparam($wordToComplete, $commandAst, $cursorPosition)\n")
+	fmt.Fprintf(w, "\t$commands = @{\n")
+	for _, c := range cmds {
+		fmt.Fprintf(w, "\t\t%s = @{\n", powershellQuote(c.name))
+		fmt.Fprintf(w, "\t\t\tflags = @{\n")
+		for _, f := range c.flags {
+			for _, word := range f.words() {
+				fmt.Fprintf(w, "\t\t\t\t%s = %s\n", powershellQuote(word), powershellQuote(f.usage))
+			}
+		}
+		fmt.Fprintf(w, "\t\t\t}\n")
+		fmt.Fprintf(w, "\t\t\tvalues = @{\n")
+		for _, f := range c.flags {
+			if f.arg == "" {
+				continue
+			}
+			var values []string
+			for _, v := range f.values {
+				values = append(values, powershellQuote(v))
+			}
+			fmt.Fprintf(w, "\t\t\t\t%s = @(%s)\n", powershellQuote("-"+f.name), strings.Join(values, ", "))
+		}
+		fmt.Fprintf(w, "\t\t\t}\n")
+		var args []string
+		for _, a := range c.args {
+			args = append(args, powershellQuote(a))
+		}
+		fmt.Fprintf(w, "\t\t\targs = @(%s)\n", strings.Join(args, ", "))
+		fmt.Fprintf(w, "\t\t}\n")
+	}
+	fmt.Fprintf(w, "\t}\n")
+	fmt.Fprintf(w, "\t$words = @($commandAst.CommandElements | Where-Object { $_.Extent.EndOffset -lt $cursorPosition } | ForEach-Object { $_.ToString() })\n")
+	fmt.Fprintf(w, "\t$cmd = 'scan'\n")
+	fmt.Fprintf(w, "\tif ($words.Count -gt 1 -and $commands.ContainsKey($words[1])) { $cmd = $words[1] }\n")
+	fmt.Fprintf(w, "\t$spec = $commands[$cmd]\n")
+	fmt.Fprintf(w, "\t$prev = $words[-1]\n")
+	fmt.Fprintf(w, "\tif ($words.Count -gt 1 -and $spec.values.ContainsKey($prev)) {\n")
+	fmt.Fprintf(w, "\t\t$candidates = $spec.values[$prev]\n")
+	fmt.Fprintf(w, "\t} elseif ($wordToComplete.StartsWith('-')) {\n")
+	fmt.Fprintf(w, "\t\t$spec.flags.Keys | Where-Object { $_ -like \"$wordToComplete*\" } | Sort-Object | ForEach-Object {\n")
+	fmt.Fprintf(w, "\t\t\t[System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterName', $spec.flags[$_])\n")
+	fmt.Fprintf(w, "\t\t}\n")
+	fmt.Fprintf(w, "\t\treturn\n")
+	fmt.Fprintf(w, "\t} elseif ($words.Count -eq 1) {\n")
+	fmt.Fprintf(w, "\t\t$candidates = $commands.Keys\n")
+	fmt.Fprintf(w, "\t} else {\n")
+	fmt.Fprintf(w, "\t\t$candidates = $spec.args\n")
+	fmt.Fprintf(w, "\t}\n")
+	fmt.Fprintf(w, "\t# Without candidates, PowerShell falls back to completing file names.\n")
+	fmt.Fprintf(w, "\t$candidates | Where-Object { $_ -like \"$wordToComplete*\" } | Sort-Object | ForEach-Object {\n")
+	fmt.Fprintf(w, "\t\t[System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)\n")
+	fmt.Fprintf(w, "\t}\n")
+	fmt.Fprintf(w, "}\n")
+}
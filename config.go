@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// config is the configuration file read with -config, or from the user's
+// configuration directory if it exists there.
+//
+//	{
+//		"vars": [
+//			{"name": "main.gitCommit", "type": "string", "field": "commit"}
+//		],
+//		"appVersion": {"enabled": true, "vars": ["main.release"]}
+//	}
+type config struct {
+	Vars       []varRule         `json:"vars"`
+	AppVersion *appVersionConfig `json:"appVersion"`
+}
+
+// appVersionConfig configures the search for the version of applications,
+// as -app-version enables it: Vars replace the defaultAppVersionVars if
+// given.
+type appVersionConfig struct {
+	Enabled bool     `json:"enabled"`
+	Vars    []string `json:"vars,omitempty"`
+}
+
+// varRule declares a global variable to be read from every binary
+// scanned, in addition to the Go version. Type is the expected kind of
+// value, as returned by valueKind, or "" to accept any. The value is
+// reported as Field, which defaults to Name.
+type varRule struct {
+	Name  string `json:"name"`
+	Type  string `json:"type,omitempty"`
+	Field string `json:"field,omitempty"`
+
+	// required is set for variables given with -var, which are
+	// reported if missing.
+	required bool
+}
+
+// extraVars are the variables declared in the configuration file and
+// given with -var.
+var extraVars []varRule
+
+// varsFlag is the value of the repeatable -var flag.
+type varsFlag []varRule
+
+func (f *varsFlag) String() string {
+	var names []string
+	for _, v := range *f {
+		names = append(names, v.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func (f *varsFlag) Set(s string) error {
+	if s == "" {
+		return fmt.Errorf("missing variable name")
+	}
+	*f = append(*f, varRule{Name: s, Field: s, required: true})
+	return nil
+}
+
+// defaultConfigPath returns the location of the configuration file in the
+// user's configuration directory.
+func defaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gover", "config.json"), nil
+}
+
+// addConfigFlag registers the -config flag on fs. The returned function
+// loads the configuration and has to be called once the flags are parsed.
+func addConfigFlag(fs *flag.FlagSet) func() {
+	name := fs.String("config", "", "read the configuration from `file` instead of the user's configuration directory")
+	return func() {
+		file, explicit := *name, *name != ""
+		if !explicit {
+			var err error
+			if file, err = defaultConfigPath(); err != nil {
+				return
+			}
+		}
+		c, err := loadConfig(file)
+		if os.IsNotExist(err) && !explicit {
+			return
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gover: reading configuration: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		extraVars = append(c.Vars, extraVars...)
+		if a := c.AppVersion; a != nil && a.Enabled {
+			appVersionVars = defaultAppVersionVars
+			if len(a.Vars) > 0 {
+				appVersionVars = a.Vars
+			}
+		}
+	}
+}
+
+func loadConfig(name string) (*config, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var c config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	for i, v := range c.Vars {
+		if v.Name == "" {
+			return nil, fmt.Errorf("%s: variable without a name", name)
+		}
+		switch v.Type {
+		case "", "string", "int", "uint", "bool", "float", "slice", "array", "struct":
+		default:
+			return nil, fmt.Errorf("%s: %s: unsupported type %q", name, v.Name, v.Type)
+		}
+		if v.Field == "" {
+			c.Vars[i].Field = v.Name
+		}
+	}
+	return &c, nil
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// dbRecord is one scan result in the result store.
+type dbRecord struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	SHA256  string    `json:"sha256,omitempty"`
+	Version string    `json:"version,omitempty"`
+	Deps    []string  `json:"deps,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	Time    time.Time `json:"time"`
+}
+
+// resultDB is an append-only store of scan results, kept as one JSON
+// record per line so it can be inspected and processed with standard
+// tools.
+type resultDB struct {
+	mu     sync.Mutex
+	f      *os.File
+	latest map[string]*dbRecord
+}
+
+func openResultDB(name string) (*resultDB, error) {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	return &resultDB{f: f}, nil
+}
+
+func (db *resultDB) Close() error {
+	return db.f.Close()
+}
+
+// unchanged reports whether file is unchanged since it was last recorded:
+// either its size and modification time match, or its contents do.
+func (db *resultDB) unchanged(file string) (bool, error) {
+	db.mu.Lock()
+	if db.latest == nil {
+		db.latest = make(map[string]*dbRecord)
+		err := db.each(func(rec *dbRecord) {
+			db.latest[rec.Path] = rec
+		})
+		if err != nil {
+			db.latest = nil
+			db.mu.Unlock()
+			return false, err
+		}
+	}
+	prev := db.latest[absPath(file)]
+	db.mu.Unlock()
+	if prev == nil {
+		return false, nil
+	}
+	fi, err := os.Stat(longPath(file))
+	if err != nil || fi.Size() != prev.Size {
+		return false, nil
+	}
+	if fi.ModTime().Equal(prev.ModTime) {
+		return true, nil
+	}
+	if sum, err := hashFile(file); err != nil || sum != prev.SHA256 {
+		return false, nil
+	}
+	// Only touched: remember the new modification time so the next
+	// run does not need to hash it again.
+	rec := *prev
+	rec.ModTime = fi.ModTime().UTC()
+	rec.Time = time.Now().UTC()
+	db.write(&rec)
+	return true, nil
+}
+
+// record appends the result of scanning file, with its SHA-256 sum, which
+// is computed if "", and the module dependencies deps.
+func (db *resultDB) record(file, ver string, err error, sum string, deps []string) {
+	rec := dbRecord{Path: absPath(file), Version: ver, Time: time.Now().UTC()}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	if fi, err := os.Stat(longPath(file)); err == nil {
+		rec.Size = fi.Size()
+		rec.ModTime = fi.ModTime().UTC()
+	}
+	if sum == "" {
+		sum, _ = hashFile(file)
+	}
+	rec.SHA256, rec.Deps = sum, deps
+
+	db.write(&rec)
+}
+
+func (db *resultDB) write(rec *dbRecord) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.latest != nil {
+		db.latest[rec.Path] = rec
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return
+	}
+	if _, err := db.f.Write(append(b, '\n')); err != nil {
+		slog.Warn("result store write failed", "file", db.f.Name(), "err", err)
+	}
+}
+
+func absPath(file string) string {
+	if abs, err := filepath.Abs(file); err == nil {
+		return abs
+	}
+	return file
+}
+
+// each calls fn for every record, oldest first.
+func (db *resultDB) each(fn func(*dbRecord)) error {
+	if _, err := db.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	s := bufio.NewScanner(db.f)
+	s.Buffer(nil, 16<<20)
+	for s.Scan() {
+		var rec dbRecord
+		if err := json.Unmarshal(s.Bytes(), &rec); err != nil {
+			return fmt.Errorf("%s: %s", db.f.Name(), err)
+		}
+		fn(&rec)
+	}
+	return s.Err()
+}
+
+// moduleDeps returns the module dependencies recorded in the build
+// information of the binary b as path@version, or nil if b is nil.
+func moduleDeps(b *binaryFile) []string {
+	if b == nil {
+		return nil
+	}
+	bi, err := b.BuildInfo()
+	if err != nil {
+		return nil
+	}
+	var deps []string
+	for _, m := range bi.Deps {
+		deps = append(deps, m.Path+"@"+depVersion(m))
+	}
+	return deps
+}
+
+// depVersion returns the version of m that was built, which is the
+// version of its replacement if it was replaced by another module version.
+func depVersion(m *debug.Module) string {
+	if m.Replace != nil && m.Replace.Version != "" {
+		return m.Replace.Version
+	}
+	return m.Version
+}
+
+func hashFile(name string) (string, error) {
+	return digestFile(name, sha256.New())
+}
+
+// digestFile returns the hex encoded digest of the contents of the file
+// name computed with h.
+func digestFile(name string, h hash.Hash) (string, error) {
+	f, err := os.Open(longPath(name))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// historyMain implements "gover history", which prints the recorded
+// results for the given files, or all Go binaries in the store.
+func historyMain(args []string) int {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	fs.Usage = usage
+	dbName := fs.String("db", "gover.db", "result store `file`")
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	files := parseArgs(fs, args)
+	setup()
+
+	db, err := os.Open(*dbName)
+	if err != nil {
+		slog.Error("opening result store failed", "err", err)
+		return exitError
+	}
+	defer db.Close()
+
+	want := make(map[string]bool)
+	for _, f := range files {
+		want[absPath(f)] = true
+	}
+	err = (&resultDB{f: db}).each(func(rec *dbRecord) {
+		if len(want) > 0 && !want[rec.Path] || rec.Version == "" {
+			return
+		}
+		fmt.Printf("%s %s: %s\n", rec.Time.Format(time.RFC3339), rec.Path, rec.Version)
+	})
+	if err != nil {
+		slog.Error("reading result store failed", "err", err)
+		return exitError
+	}
+	return 0
+}
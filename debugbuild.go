@@ -0,0 +1,117 @@
+package main
+
+import (
+	"debug/dwarf"
+	"sort"
+	"strings"
+)
+
+// debugBuild lists the packages of a binary compiled with optimizations
+// (-N) or inlining (-l) disabled, as for debugging with
+// -gcflags=all=-N -l. Source is where that was found: "producer" for the
+// compiler flags the DWARF compilation units record, "inlining" if the
+// DWARF info has no inlined calls at all, or "gcflags" for the build
+// settings of binaries without DWARF info.
+type debugBuild struct {
+	NoOptimization []string `json:"noOptimization,omitempty"`
+	NoInlining     []string `json:"noInlining,omitempty"`
+	Source         string   `json:"source"`
+}
+
+// findDebugBuild reports whether the binary was compiled without
+// optimizations or inlining, given the -gcflags build setting. It returns
+// nil for optimized builds.
+func findDebugBuild(b *binaryFile, gcflags string) *debugBuild {
+	db := &debugBuild{}
+	if d, err := b.DWARF(); err == nil {
+		db = dwarfDebugBuild(d)
+	}
+	if db.Source == "" && gcflags != "" {
+		// -gcflags=-N applies to the main package, all=-N to all packages.
+		for _, arg := range strings.Fields(strings.Trim(gcflags, `"`)) {
+			pkg, flag, ok := strings.Cut(arg, "=")
+			if !ok {
+				pkg, flag = "main", arg
+			}
+			switch flag {
+			case "-N":
+				db.NoOptimization = append(db.NoOptimization, pkg)
+			case "-l":
+				db.NoInlining = append(db.NoInlining, pkg)
+			}
+		}
+		db.Source = "gcflags"
+	}
+	if db.NoOptimization == nil && db.NoInlining == nil {
+		return nil
+	}
+	return db
+}
+
+// dwarfDebugBuild finds the Go packages compiled with -N or -l in d. The
+// Go compiler records its flags in the DW_AT_producer attribute of the
+// compilation units, as in "Go cmd/compile go1.21.5; -N -l regabi". For
+// releases that don't, a build without a single inlined call is taken to
+// be compiled with inlining disabled.
+func dwarfDebugBuild(d *dwarf.Data) *debugBuild {
+	db := &debugBuild{}
+	flagged := false
+	units, inlined := 0, 0
+	r := d.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil || e == nil {
+			break
+		}
+		switch e.Tag {
+		case dwarf.TagCompileUnit:
+			if lang, _ := e.Val(dwarf.AttrLanguage).(int64); lang != dwLangGo {
+				r.SkipChildren()
+				continue
+			}
+			units++
+			name, _ := e.Val(dwarf.AttrName).(string)
+			producer, _ := e.Val(dwarf.AttrProducer).(string)
+			_, flags, ok := strings.Cut(producer, "; ")
+			if !ok {
+				continue
+			}
+			flagged = true
+			for _, f := range strings.Fields(flags) {
+				switch f {
+				case "-N":
+					db.NoOptimization = append(db.NoOptimization, name)
+				case "-l":
+					db.NoInlining = append(db.NoInlining, name)
+				}
+			}
+		case dwarf.TagInlinedSubroutine:
+			inlined++
+		}
+	}
+	switch {
+	case flagged:
+		db.Source = "producer"
+		// Packages can span several units.
+		db.NoOptimization = sortedUnique(db.NoOptimization)
+		db.NoInlining = sortedUnique(db.NoInlining)
+	case units > 0 && inlined == 0:
+		db.NoInlining = []string{"all"}
+		db.Source = "inlining"
+	}
+	return db
+}
+
+func sortedUnique(list []string) []string {
+	sort.Strings(list)
+	out := list[:0]
+	for i, s := range list {
+		if i == 0 || s != list[i-1] {
+			out = append(out, s)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime/debug"
+)
+
+// depModule is a module compiled into a binary, as printed by deps -json.
+type depModule struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Replace string `json:"replace,omitempty"`
+	Sum     string `json:"sum,omitempty"`
+}
+
+// depsResult is the deps -json output for one binary.
+type depsResult struct {
+	File string      `json:"file"`
+	Main *depModule  `json:"main,omitempty"`
+	Deps []depModule `json:"deps"`
+}
+
+func newDepModule(m *debug.Module) depModule {
+	dm := depModule{Path: m.Path, Version: m.Version, Sum: m.Sum}
+	if m.Replace != nil {
+		dm.Replace = m.Replace.Path
+		if m.Replace.Version != "" {
+			dm.Replace += "@" + m.Replace.Version
+		}
+		dm.Sum = m.Replace.Sum
+	}
+	return dm
+}
+
+// depsMain implements "gover deps": it lists the modules compiled into
+// binaries.
+func depsMain(args []string) int {
+	fs := flag.NewFlagSet("deps", flag.ExitOnError)
+	fs.Usage = usage
+	jsonOut := fs.Bool("json", false, "print the modules as JSON")
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	files := parseArgs(fs, args)
+	setup()
+	if len(files) < 1 {
+		usage()
+	}
+
+	exit := 0
+	results := []interface{}{}
+	for i, file := range files {
+		bi, err := readBuildInfo(file)
+		if err != nil {
+			slog.Error("reading build info failed", "file", file, "err", err)
+			exit = worseExit(exit, readExit(err))
+			if *jsonOut {
+				results = append(results, newFileFailure(file, err))
+			}
+			continue
+		}
+		res := depsResult{File: file, Deps: []depModule{}}
+		if bi.Main.Path != "" {
+			m := newDepModule(&bi.Main)
+			res.Main = &m
+		}
+		for _, m := range bi.Deps {
+			res.Deps = append(res.Deps, newDepModule(m))
+		}
+		if *jsonOut {
+			results = append(results, res)
+			continue
+		}
+
+		if len(files) > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s:\n", file)
+		}
+		for _, m := range res.Deps {
+			if m.Replace != "" {
+				fmt.Printf("%s %s => %s\n", m.Path, m.Version, m.Replace)
+			} else {
+				fmt.Printf("%s %s\n", m.Path, m.Version)
+			}
+		}
+	}
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(results)
+	}
+	return exit
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// runEntry is a Go binary as recorded by a previous run.
+type runEntry struct {
+	Version string
+	Deps    map[string]string
+}
+
+// loadRun reads the Go binaries recorded in a -json report, a serve
+// response or a result store. Later records for a path replace earlier
+// ones, so a result store yields its most recent state.
+func loadRun(name string) (map[string]*runEntry, error) {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	type record struct {
+		File    string   `json:"file"`
+		Path    string   `json:"path"`
+		Version string   `json:"version"`
+		Deps    []string `json:"deps"`
+	}
+	var recs []record
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		if err := json.Unmarshal(data, &recs); err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+	} else {
+		s := bufio.NewScanner(bytes.NewReader(data))
+		s.Buffer(nil, 16<<20)
+		for s.Scan() {
+			var rec record
+			if err := json.Unmarshal(s.Bytes(), &rec); err != nil {
+				return nil, fmt.Errorf("%s: %s", name, err)
+			}
+			recs = append(recs, rec)
+		}
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	run := make(map[string]*runEntry)
+	for _, rec := range recs {
+		path := rec.Path
+		if path == "" {
+			path = rec.File
+		}
+		if rec.Version == "" {
+			delete(run, path)
+			continue
+		}
+		e := &runEntry{Version: rec.Version, Deps: make(map[string]string)}
+		for _, d := range rec.Deps {
+			mod, ver := d, ""
+			if i := strings.LastIndex(d, "@"); i > 0 {
+				mod, ver = d[:i], d[i+1:]
+			}
+			e.Deps[mod] = ver
+		}
+		run[path] = e
+	}
+	return run, nil
+}
+
+// diffMain implements "gover diff", which reports Go binaries that
+// appeared, disappeared, or changed version or dependencies between two
+// runs. It exits 0 if there are no differences and 3 if there are, as
+// for a policy that nothing changes.
+func diffMain(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	fs.Usage = usage
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	files := parseArgs(fs, args)
+	setup()
+	if len(files) != 2 {
+		usage()
+	}
+
+	a, err := loadRun(files[0])
+	if err != nil {
+		slog.Error("reading run failed", "err", err)
+		return exitError
+	}
+	b, err := loadRun(files[1])
+	if err != nil {
+		slog.Error("reading run failed", "err", err)
+		return exitError
+	}
+
+	paths := make([]string, 0, len(a)+len(b))
+	for p := range a {
+		paths = append(paths, p)
+	}
+	for p := range b {
+		if a[p] == nil {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	exit := 0
+	for _, p := range paths {
+		ea, eb := a[p], b[p]
+		switch {
+		case ea == nil:
+			fmt.Printf("+ %s: %s\n", p, eb.Version)
+		case eb == nil:
+			fmt.Printf("- %s: %s\n", p, ea.Version)
+		default:
+			deps := diffDeps(ea.Deps, eb.Deps)
+			if ea.Version == eb.Version && len(deps) == 0 {
+				continue
+			}
+			if ea.Version != eb.Version {
+				fmt.Printf("~ %s: %s -> %s\n", p, ea.Version, eb.Version)
+			} else {
+				fmt.Printf("~ %s: %s\n", p, ea.Version)
+			}
+			for _, d := range deps {
+				fmt.Printf("    %s\n", d)
+			}
+		}
+		exit = exitViolation
+	}
+	return exit
+}
+
+// diffDeps describes the differences between two module version maps,
+// one line per added, removed or changed module.
+func diffDeps(a, b map[string]string) []string {
+	var lines []string
+	for m, va := range a {
+		vb, ok := b[m]
+		switch {
+		case !ok:
+			lines = append(lines, "- "+m+"@"+va)
+		case va != vb:
+			lines = append(lines, "~ "+m+" "+va+" -> "+vb)
+		}
+	}
+	for m, vb := range b {
+		if _, ok := a[m]; !ok {
+			lines = append(lines, "+ "+m+"@"+vb)
+		}
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i][2:] < lines[j][2:] })
+	return lines
+}
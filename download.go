@@ -0,0 +1,384 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxDownload is the most that is downloaded for a single remote file.
+var maxDownload int64 = 2 << 30
+
+// Transient failures of HTTP requests are retried up to maxRetries times,
+// waiting about retryWait before the first retry and twice as long before
+// each further one.
+var (
+	maxRetries = 3
+	retryWait  = 500 * time.Millisecond
+)
+
+// maxRetryWait caps the wait between two attempts.
+const maxRetryWait = 30 * time.Second
+
+// addDownloadFlags registers the -max-download flag and the retry flags on
+// fs.
+func addDownloadFlags(fs *flag.FlagSet) {
+	fs.Int64Var(&maxDownload, "max-download", maxDownload, "download at most `n` bytes of each remote file")
+	addRetryFlags(fs)
+}
+
+// addRetryFlags registers the -retries and -retry-wait flags on fs.
+func addRetryFlags(fs *flag.FlagSet) {
+	fs.IntVar(&maxRetries, "retries", maxRetries, "retry failed downloads up to `n` times")
+	fs.DurationVar(&retryWait, "retry-wait", retryWait, "initial wait before retrying a download, doubled for each retry")
+}
+
+// maxResumes is how often an interrupted download is resumed.
+const maxResumes = 3
+
+// rangeBlockSize is the granularity in which rangeReader fetches and
+// caches the parts of a remote file.
+const rangeBlockSize = 64 << 10
+
+// httpGet sends a GET request for url with the headers from hdr and
+// fails unless the response status is 200 or 206. Network errors and
+// responses indicating a temporary problem are retried with backoff.
+func httpGet(url string, hdr http.Header) (*http.Response, error) {
+	return httpDo("GET", url, hdr, nil)
+}
+
+// httpDo is like httpGet for any method, sending body with every
+// attempt.
+func httpDo(method, url string, hdr http.Header, body []byte) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(method, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range hdr {
+			req.Header[k] = v
+		}
+		resp, err := httpClient.Do(req)
+		if (err == nil && !retryStatus(resp.StatusCode)) || attempt >= maxRetries {
+			if err != nil {
+				return nil, err
+			}
+			if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+				resp.Body.Close()
+				return nil, fmt.Errorf("%s: %s", url, resp.Status)
+			}
+			return resp, nil
+		}
+
+		wait := backoff(attempt)
+		if err == nil {
+			if d, ok := retryAfter(resp); ok {
+				wait = d
+			}
+			err = fmt.Errorf("%s", resp.Status)
+			resp.Body.Close()
+		}
+		slog.Info("retrying request", "url", url, "attempt", attempt+1, "wait", wait, "err", err)
+		time.Sleep(wait)
+	}
+}
+
+// retryStatus reports whether a response with status code may succeed
+// when the request is repeated.
+func retryStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns the wait before retry number attempt+1: exponential in
+// attempt, with full jitter so that many clients failing at once don't
+// retry in lockstep.
+func backoff(attempt int) time.Duration {
+	if retryWait <= 0 {
+		return 0
+	}
+	d := retryWait << uint(attempt)
+	if d <= 0 || d > maxRetryWait {
+		d = maxRetryWait
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+// retryAfter returns the wait requested by the Retry-After header of
+// resp, if it has one in seconds.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	secs, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || secs < 0 {
+		return 0, false
+	}
+	return min(time.Duration(secs)*time.Second, maxRetryWait), true
+}
+
+// validator returns the entity tag or modification time identifying the
+// version of a remote file, for use in If-Range. Weak tags can't be used.
+func validator(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+// resolved returns the URL a response was finally served from and the
+// headers to send there. Credentials are dropped once redirected to
+// another host, such as a pre-signed object storage URL.
+func resolved(rawURL string, hdr http.Header, resp *http.Response) (string, http.Header) {
+	final := resp.Request.URL
+	if u, err := url.Parse(rawURL); err != nil || u.Host != final.Host {
+		return final.String(), nil
+	}
+	return final.String(), hdr
+}
+
+// download reads a remote file, resuming with a range request where it
+// left off if the connection breaks and the server supports that.
+type download struct {
+	url       string
+	hdr       http.Header
+	body      io.ReadCloser
+	off       int64
+	limit     int64
+	validator string
+	resumes   int
+	exceeded  bool
+}
+
+func openDownload(url string, hdr http.Header, limit int64) (*download, error) {
+	resp, err := httpGet(url, hdr)
+	if err != nil {
+		return nil, err
+	}
+	if resp.ContentLength > limit {
+		resp.Body.Close()
+		return nil, errTooLarge
+	}
+	d := &download{body: resp.Body, limit: limit}
+	d.url, d.hdr = resolved(url, hdr, resp)
+	if resp.Header.Get("Accept-Ranges") == "bytes" {
+		d.validator = validator(resp)
+	}
+	return d, nil
+}
+
+func (d *download) Read(p []byte) (int, error) {
+	if d.off > d.limit {
+		d.exceeded = true
+		return 0, errTooLarge
+	}
+	if int64(len(p)) > d.limit-d.off+1 {
+		p = p[:d.limit-d.off+1]
+	}
+	for {
+		n, err := d.body.Read(p)
+		d.off += int64(n)
+		if err == nil || err == io.EOF || d.validator == "" || d.resumes >= maxResumes {
+			return n, err
+		}
+		slog.Info("resuming download", "url", d.url, "offset", d.off, "err", err)
+		d.resumes++
+		d.body.Close()
+		if rerr := d.resume(); rerr != nil {
+			slog.Warn("resuming download failed", "url", d.url, "err", rerr)
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (d *download) resume() error {
+	hdr := http.Header{}
+	for k, v := range d.hdr {
+		hdr[k] = v
+	}
+	hdr.Set("Range", fmt.Sprintf("bytes=%d-", d.off))
+	hdr.Set("If-Range", d.validator)
+	resp, err := httpGet(d.url, hdr)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		// The file changed, so starting over would be needed.
+		resp.Body.Close()
+		d.body = io.NopCloser(strings.NewReader(""))
+		return fmt.Errorf("file changed during download")
+	}
+	d.body = resp.Body
+	return nil
+}
+
+func (d *download) Close() error {
+	return d.body.Close()
+}
+
+// rangeReader gives random access to a remote file by fetching only the
+// parts that are read, using range requests.
+type rangeReader struct {
+	url       string
+	hdr       http.Header
+	size      int64
+	validator string
+	limit     int64
+	fetched   int64
+	blocks    map[int64][]byte
+}
+
+// openRange returns a rangeReader for url, or nil if the server doesn't
+// support range requests.
+func openRange(url string, hdr http.Header, limit int64) (*rangeReader, error) {
+	h := http.Header{}
+	for k, v := range hdr {
+		h[k] = v
+	}
+	h.Set("Range", fmt.Sprintf("bytes=0-%d", rangeBlockSize-1))
+	resp, err := httpGet(url, h)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return nil, nil
+	}
+	// Content-Range: bytes 0-65535/12345678
+	cr := resp.Header.Get("Content-Range")
+	i := strings.LastIndex(cr, "/")
+	if i < 0 {
+		return nil, nil
+	}
+	size, err := strconv.ParseInt(cr[i+1:], 10, 64)
+	if err != nil {
+		return nil, nil
+	}
+	r := &rangeReader{size: size, limit: limit, validator: validator(resp), blocks: make(map[int64][]byte)}
+	r.url, r.hdr = resolved(url, hdr, resp)
+	if err := r.store(0, resp.Body, min(size, rangeBlockSize)); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset")
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	end := min(off+int64(len(p)), r.size)
+	first := off / rangeBlockSize * rangeBlockSize
+	for b := first; b < end; {
+		if r.blocks[b] != nil {
+			b += rangeBlockSize
+			continue
+		}
+		e := b
+		for e < end && r.blocks[e] == nil {
+			e += rangeBlockSize
+		}
+		if err := r.fetch(b, e); err != nil {
+			return 0, err
+		}
+		b = e
+	}
+	n := 0
+	for b := first; b < end; b += rangeBlockSize {
+		blk := r.blocks[b]
+		lo := max(off-b, 0)
+		hi := min(end-b, int64(len(blk)))
+		n += copy(p[n:], blk[lo:hi])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// fetch downloads the blocks from start, which is aligned to
+// rangeBlockSize, up to the one containing end-1.
+func (r *rangeReader) fetch(start, end int64) error {
+	end = min(end, r.size)
+	if r.fetched+end-start > r.limit {
+		r.fetched = r.limit + 1
+		return errTooLarge
+	}
+	hdr := http.Header{}
+	for k, v := range r.hdr {
+		hdr[k] = v
+	}
+	hdr.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+	if r.validator != "" {
+		hdr.Set("If-Range", r.validator)
+	}
+	resp, err := httpGet(r.url, hdr)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("file changed during download")
+	}
+	return r.store(start, resp.Body, end-start)
+}
+
+func (r *rangeReader) store(start int64, body io.Reader, n int64) error {
+	for off := start; off < start+n; off += rangeBlockSize {
+		blk := make([]byte, min(rangeBlockSize, start+n-off))
+		if _, err := io.ReadFull(body, blk); err != nil {
+			return err
+		}
+		r.blocks[off] = blk
+		r.fetched += int64(len(blk))
+	}
+	return nil
+}
+
+// scanRemote calls fn with the results for the remote file at url, named
+// name, as scanArchive does. Plain binaries are read with range requests
+// where possible, so only the parts needed are downloaded. No more than
+// limit bytes are downloaded, or errTooLarge is returned.
+func scanRemote(name, url string, hdr http.Header, limit int64, fn func(name, ver string, err error)) error {
+	if !isArchive(name) {
+		ra, err := openRange(url, hdr, limit)
+		if err != nil {
+			return err
+		}
+		if ra != nil {
+			start := time.Now()
+			ver, err := findVersionAt(ra)
+			observeScan(start, err)
+			slog.Debug("fetched ranges", "url", url, "fetched", ra.fetched, "size", ra.size)
+			if ra.fetched > limit {
+				return errTooLarge
+			}
+			fn(name, ver, err)
+			return nil
+		}
+	}
+	d, err := openDownload(url, hdr, limit)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	err = scanArchive(name, d, fn)
+	if d.exceeded {
+		return errTooLarge
+	}
+	return err
+}
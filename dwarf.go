@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"debug/dwarf"
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+// dwarfSize returns the size of the DWARF sections of b once
+// decompressed, as far as the format records it: compressed ELF sections
+// do, .zdebug sections are counted as compressed.
+func dwarfSize(b Binary) uint64 {
+	var size uint64
+	isDWARF := func(name string) bool {
+		name = strings.TrimLeft(name, "._")
+		return strings.HasPrefix(name, "debug_") || strings.HasPrefix(name, "zdebug_")
+	}
+	switch f := b.(type) {
+	case *elfBinary:
+		for _, s := range f.Sections {
+			if isDWARF(s.Name) {
+				size += s.Size
+			}
+		}
+	case *peBinary:
+		for _, s := range f.Sections {
+			if isDWARF(s.Name) {
+				size += uint64(s.Size)
+			}
+		}
+	case *machoBinary:
+		for _, s := range f.Sections {
+			if isDWARF(s.Name) {
+				size += s.Size
+			}
+		}
+	}
+	return size
+}
+
+// hasDWARFSection reports whether b has the DWARF section name, given
+// without its .debug_ prefix, going by the section headers: unlike
+// DWARFSection, it doesn't read and decompress the section.
+func hasDWARFSection(b Binary, name string) bool {
+	switch f := b.(type) {
+	case *elfBinary:
+		return f.Section(".debug_"+name) != nil || f.Section(".zdebug_"+name) != nil
+	case *peBinary:
+		return f.Section(".debug_"+name) != nil || f.Section(".zdebug_"+name) != nil
+	case *machoBinary:
+		return f.Section("__debug_"+name) != nil || f.Section("__zdebug_"+name) != nil
+	}
+	return b.DWARFSection(name) != nil
+}
+
+// lookupPubname finds name in the contents of a .debug_pubnames section and
+// returns the offset of its entry in .debug_info. The Go linker emitted
+// the section until Go 1.12 and it is missing from newer binaries; its
+// DWARF 5 successor .debug_names has never been emitted for Go code, so it
+// is not consulted.
+func lookupPubname(data []byte, name string) (dwarf.Offset, bool) {
+	for len(data) >= 4 {
+		order := binary.ByteOrder(binary.LittleEndian)
+		unitLen := uint64(order.Uint32(data))
+		offSize := 4
+		hdr := 4
+		if unitLen == 0xffffffff {
+			if len(data) < 12 {
+				return 0, false
+			}
+			unitLen = order.Uint64(data[4:])
+			offSize = 8
+			hdr = 12
+		}
+		// The version is always 2, which also tells the byte order.
+		if len(data) < hdr+2 {
+			return 0, false
+		}
+		if order.Uint16(data[hdr:]) != 2 {
+			order = binary.BigEndian
+			if order.Uint16(data[hdr:]) != 2 {
+				return 0, false
+			}
+			unitLen = uint64(order.Uint32(data))
+			if offSize == 8 {
+				unitLen = order.Uint64(data[4:])
+			}
+		}
+		if unitLen > uint64(len(data)-hdr) {
+			return 0, false
+		}
+		unit := data[hdr : hdr+int(unitLen)]
+		data = data[hdr+int(unitLen):]
+
+		readOff := func(b []byte) uint64 {
+			if offSize == 8 {
+				return order.Uint64(b)
+			}
+			return uint64(order.Uint32(b))
+		}
+		if len(unit) < 2+2*offSize {
+			return 0, false
+		}
+		cu := readOff(unit[2:])
+		unit = unit[2+2*offSize:]
+		for len(unit) >= offSize {
+			die := readOff(unit)
+			if die == 0 {
+				break
+			}
+			unit = unit[offSize:]
+			i := bytes.IndexByte(unit, 0)
+			if i < 0 {
+				return 0, false
+			}
+			if string(unit[:i]) == name {
+				return dwarf.Offset(cu + die), true
+			}
+			unit = unit[i+1:]
+		}
+	}
+	return 0, false
+}
+
+// zdebugData decompresses the contents of a .zdebug section, which start
+// with "ZLIB" and the big-endian uncompressed size.
+func zdebugData(b []byte) []byte {
+	if len(b) < 12 || string(b[:4]) != "ZLIB" {
+		return nil
+	}
+	size := binary.BigEndian.Uint64(b[4:])
+	if maxMemory > 0 && size > uint64(maxMemory) {
+		return nil
+	}
+	r, err := zlib.NewReader(bytes.NewReader(b[12:]))
+	if err != nil {
+		return nil
+	}
+	defer r.Close()
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, io.LimitReader(r, int64(size))); err != nil {
+		return nil
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+	"sort"
+)
+
+// dwarfInfo describes the DWARF info of a binary: the DWARF versions its
+// compilation units use and the producers that wrote them, as recorded in
+// their DW_AT_producer attribute. The Go compiler names itself and its
+// version there, as in "Go cmd/compile go1.22.3; regabi", the flags it
+// was given following the semicolon, and C compilers do the same for the
+// cgo code linked in.
+type dwarfInfo struct {
+	Versions  []int           `json:"versions"`
+	Producers []dwarfProducer `json:"producers,omitempty"`
+}
+
+// dwarfProducer is a producer string and how many compilation units it
+// wrote.
+type dwarfProducer struct {
+	Producer string `json:"producer"`
+	Units    int    `json:"units"`
+}
+
+// findDWARFInfo returns the DWARF info description of b, or nil if b has
+// no DWARF info.
+func findDWARFInfo(b *binaryFile) *dwarfInfo {
+	d, err := b.DWARF()
+	if err != nil {
+		return nil
+	}
+	info := &dwarfInfo{Versions: dwarfVersions(b.DWARFSection("info"))}
+	units := make(map[string]int)
+	r := d.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil || e == nil {
+			break
+		}
+		if e.Tag == dwarf.TagCompileUnit {
+			if p, _ := e.Val(dwarf.AttrProducer).(string); p != "" {
+				units[p]++
+			}
+		}
+		r.SkipChildren()
+	}
+	for p, n := range units {
+		info.Producers = append(info.Producers, dwarfProducer{p, n})
+	}
+	sort.Slice(info.Producers, func(i, j int) bool {
+		pi, pj := info.Producers[i], info.Producers[j]
+		if pi.Units != pj.Units {
+			return pi.Units > pj.Units
+		}
+		return pi.Producer < pj.Producer
+	})
+	return info
+}
+
+// dwarfVersions returns the versions of the units in the .debug_info
+// section data, sorted. debug/dwarf reads them, but doesn't tell.
+func dwarfVersions(data []byte) []int {
+	seen := make(map[int]bool)
+	versions := []int{}
+	for len(data) > 0 {
+		v, n := dwarfUnitHeader(data, binary.LittleEndian)
+		if n == 0 {
+			// The version, between 2 and 5, tells the byte order.
+			v, n = dwarfUnitHeader(data, binary.BigEndian)
+		}
+		if n == 0 {
+			break
+		}
+		if !seen[v] {
+			seen[v] = true
+			versions = append(versions, v)
+		}
+		data = data[n:]
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// dwarfUnitHeader returns the version of the unit data starts with, read
+// in order, and its length including the header, or 0 if it is invalid.
+func dwarfUnitHeader(data []byte, order binary.ByteOrder) (version, n int) {
+	if len(data) < 6 {
+		return 0, 0
+	}
+	unitLen, hdr := uint64(order.Uint32(data)), 4
+	if unitLen == 0xffffffff {
+		if len(data) < 14 {
+			return 0, 0
+		}
+		unitLen, hdr = order.Uint64(data[4:]), 12
+	}
+	v := int(order.Uint16(data[hdr:]))
+	if v < 2 || v > 5 || unitLen < 2 || unitLen > uint64(len(data)-hdr) {
+		return 0, 0
+	}
+	return v, hdr + int(unitLen)
+}
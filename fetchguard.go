@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// fetchPolicy decides which URLs serve fetches for the url parameter of
+// /scan. Anyone who can post to the service can have it fetch them, so
+// only https URLs on the hosts listed with -fetch-hosts are, and never
+// from addresses that aren't public, which keeps the service from being
+// used to reach internal ones. A nil *fetchPolicy allows none.
+type fetchPolicy struct {
+	// hosts are the allowed host names, or nil if any public host is.
+	hosts map[string]bool
+}
+
+var errFetchDisabled = errors.New("fetching URLs is disabled, see -fetch-hosts")
+
+// newFetchPolicy returns the policy for the -fetch-hosts list, a comma
+// separated list of host names or "*" for any, or nil if it is empty.
+func newFetchPolicy(list string) *fetchPolicy {
+	if list == "" {
+		return nil
+	}
+	p := &fetchPolicy{}
+	if list == "*" {
+		return p
+	}
+	p.hosts = make(map[string]bool)
+	for _, h := range strings.Split(list, ",") {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			p.hosts[h] = true
+		}
+	}
+	return p
+}
+
+// check returns an error unless u may be fetched.
+func (p *fetchPolicy) check(u *url.URL) error {
+	if p == nil {
+		return errFetchDisabled
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("fetching %s URLs is not allowed", u.Scheme)
+	}
+	host := strings.ToLower(u.Hostname())
+	if p.hosts != nil && !p.hosts[host] {
+		return fmt.Errorf("fetching from %s is not allowed", host)
+	}
+	if ip := net.ParseIP(host); ip != nil && !isPublicIP(ip) {
+		return fmt.Errorf("fetching from %s is not allowed", host)
+	}
+	return nil
+}
+
+// client returns an HTTP client like httpClient enforcing p, on the
+// redirects followed too, and refusing to connect to addresses that
+// aren't public whatever the names resolve to. Proxies are not used, as
+// they would make the connections in its place.
+func (p *fetchPolicy) client() *http.Client {
+	t, ok := httpClient.Transport.(*http.Transport)
+	if !ok {
+		t = http.DefaultTransport.(*http.Transport)
+	}
+	t = t.Clone()
+	t.Proxy = nil
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: dialPublicOnly}
+	t.DialContext = d.DialContext
+	return &http.Client{
+		Transport: t,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return p.check(req.URL)
+		},
+	}
+}
+
+// dialPublicOnly refuses connections to addresses that aren't public. It
+// runs after the name was resolved, so names resolving to internal
+// addresses are caught too.
+func dialPublicOnly(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("refusing to connect to non-public address %s", host)
+	}
+	return nil
+}
+
+// sharedAddressSpace is 100.64.0.0/10, used for carrier-grade NAT.
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// isPublicIP reports whether ip is a globally routable unicast address:
+// not loopback, link-local (like 169.254.169.254, the metadata service of
+// cloud providers), private, shared or unspecified.
+func isPublicIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 0 {
+		// "This network", which reaches the host itself.
+		return false
+	}
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
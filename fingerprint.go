@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// versionEstimate is the range of Go releases a binary was likely built
+// with, as estimated by fingerprintVersion, and the share of the evidence
+// found that agrees with it.
+type versionEstimate struct {
+	Min        string  `json:"min"`
+	Max        string  `json:"max"`
+	Confidence float64 `json:"confidence"`
+}
+
+func (e *versionEstimate) String() string {
+	rng := e.Min
+	if e.Max != e.Min {
+		rng += " to " + e.Max
+	}
+	return fmt.Sprintf("%s, %.0f%% confidence", rng, 100*e.Confidence)
+}
+
+// runtimeMarker is a function or package of the runtime and the packages
+// it depends on that exists from release since (a Go 1 minor version) up
+// to and including until, or the latest if until is 0. Always is set for
+// markers every binary of those releases links, so that their absence
+// counts as evidence as well.
+type runtimeMarker struct {
+	fn, pkg      string
+	since, until int
+	always       bool
+}
+
+// runtimeMarkers are what the fingerprint matches the functions of a
+// binary against, which survive stripping and obfuscators that leave the
+// runtime alone. Each release is characterized by the markers it has.
+var runtimeMarkers = []runtimeMarker{
+	{fn: "runtime.asyncPreempt", since: 14, always: true},
+	{pkg: "runtime/internal/sys", since: 7, until: 23},
+	{pkg: "runtime/internal/atomic", since: 6, until: 22},
+	{pkg: "internal/abi", since: 17},
+	{pkg: "internal/godebugs", since: 21},
+	{pkg: "internal/chacha8rand", since: 22, always: true},
+	{pkg: "internal/runtime/atomic", since: 23},
+	{pkg: "internal/runtime/sys", since: 24},
+	{pkg: "internal/runtime/maps", since: 24},
+	{pkg: "internal/runtime/cgroup", since: 25},
+}
+
+// pclntabReleases maps the pclntab magic numbers to the releases that
+// write them.
+var pclntabReleases = map[uint32][2]int{
+	0xfffffffb: {2, 15},
+	0xfffffffa: {16, 17},
+	0xfffffff0: {18, 19},
+	0xfffffff1: {20, 0},
+}
+
+// Weights of the evidence the fingerprint weighs: the pclntab format,
+// markers found and markers missing that every binary would have.
+const (
+	pclntabWeight = 3
+	presentWeight = 2
+	absentWeight  = 1
+)
+
+// fingerprintVersion estimates the Go release of a binary without
+// runtime.buildVersion, build info or DWARF info from its pclntab: its
+// format and the runtimeMarkers among its functions are compared with
+// what each release has, and the releases agreeing best are reported.
+// The version returned is the oldest of them; the whole range is the
+// estimate stored in t, unless t is nil.
+func fingerprintVersion(b *binaryFile, t *scanTiming) (string, error) {
+	tab, err := b.Pclntab()
+	if err == errNoPclntab {
+		return "", noVersionError{err}
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(tab.Funcs) == 0 || tab.Funcs[0].LineTable == nil || len(tab.Funcs[0].LineTable.Data) < 4 {
+		return "", noVersionError{errors.New("pclntab has no functions")}
+	}
+	data := tab.Funcs[0].LineTable.Data
+	magic := binary.LittleEndian.Uint32(data)
+	if _, ok := pclntabReleases[magic]; !ok {
+		magic = binary.BigEndian.Uint32(data)
+	}
+	format, ok := pclntabReleases[magic]
+	if !ok {
+		return "", noVersionError{errors.New("unknown pclntab format")}
+	}
+	pkgs := make(map[string]bool)
+	for _, p := range linkedPackages(tab) {
+		pkgs[p] = true
+	}
+	present := make([]bool, len(runtimeMarkers))
+	for i, m := range runtimeMarkers {
+		if m.fn != "" {
+			present[i] = tab.LookupFunc(m.fn) != nil
+		} else {
+			present[i] = pkgs[m.pkg]
+		}
+	}
+
+	latest := latestRelease()
+	in := func(rel, since, until int) bool {
+		return rel >= since && (until == 0 || rel <= until)
+	}
+	best, lo, hi := -1.0, 0, 0
+	for rel := 2; rel <= latest.minor; rel++ {
+		agree, total := 0, pclntabWeight
+		if in(rel, format[0], format[1]) {
+			agree += pclntabWeight
+		}
+		for i, m := range runtimeMarkers {
+			expected := in(rel, m.since, m.until)
+			switch {
+			case present[i]:
+				total += presentWeight
+				if expected {
+					agree += presentWeight
+				}
+			case m.always:
+				total += absentWeight
+				if !expected {
+					agree += absentWeight
+				}
+			}
+		}
+		conf := float64(agree) / float64(total)
+		switch {
+		case conf > best:
+			best, lo, hi = conf, rel, rel
+		case conf == best && rel == hi+1:
+			hi = rel
+		}
+	}
+	est := &versionEstimate{Min: fmt.Sprintf("go1.%d", lo), Max: fmt.Sprintf("go1.%d", hi), Confidence: best}
+	if t != nil {
+		t.Estimate = est
+	}
+	return est.Min, nil
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+// +build !unix
+
+package main
+
+import "os"
+
+// fileDevice reports that the device of a file is not known, so that
+// -one-file-system has no effect where that is the case.
+func fileDevice(fi os.FileInfo) (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,19 @@
+//go:build unix
+// +build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileDevice returns the ID of the device holding the file described by
+// fi, which tells file systems apart.
+func fileDevice(fi os.FileInfo) (uint64, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return uint64(st.Dev), true
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// funcSymbol is a function listed in the pclntab, as printed by funcs
+// -json.
+type funcSymbol struct {
+	Name  string `json:"name"`
+	Entry uint64 `json:"entry"`
+	Size  uint64 `json:"size"`
+}
+
+// funcsResult is the funcs -json output for one binary.
+type funcsResult struct {
+	File  string       `json:"file"`
+	Funcs []funcSymbol `json:"funcs"`
+}
+
+// funcsMain implements "gover funcs": it lists the functions of binaries
+// with their entry addresses and sizes, read from the pclntab so that
+// stripped binaries can be examined as well.
+func funcsMain(args []string) int {
+	fs := flag.NewFlagSet("funcs", flag.ExitOnError)
+	fs.Usage = usage
+	jsonOut := fs.Bool("json", false, "print the functions as JSON")
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	files := parseArgs(fs, args)
+	setup()
+	if len(files) < 1 {
+		usage()
+	}
+
+	exit := 0
+	results := []interface{}{}
+	for i, file := range files {
+		tab, err := loadPclntab(file)
+		if err != nil {
+			slog.Error("reading pclntab failed", "file", file, "err", err)
+			exit = worseExit(exit, readExit(err))
+			if *jsonOut {
+				results = append(results, newFileFailure(file, err))
+			}
+			continue
+		}
+		res := funcsResult{File: file, Funcs: []funcSymbol{}}
+		for _, f := range tab.Funcs {
+			res.Funcs = append(res.Funcs, funcSymbol{Name: f.Name, Entry: f.Entry, Size: f.End - f.Entry})
+		}
+		if *jsonOut {
+			results = append(results, res)
+			continue
+		}
+
+		if len(files) > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s:\n", file)
+		}
+		for _, f := range res.Funcs {
+			fmt.Printf("%#x %d %s\n", f.Entry, f.Size, f.Name)
+		}
+	}
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(results)
+	}
+	return exit
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testELF returns a little-endian ELF64 executable with no program
+// headers and one section, named name, of type typ holding data.
+func testELF(name string, typ elf.SectionType, data []byte) []byte {
+	shstrtab := append([]byte("\x00.shstrtab\x00"+name), 0)
+	le := binary.LittleEndian
+	var b bytes.Buffer
+	hdr := make([]byte, 64)
+	copy(hdr, elf.ELFMAG)
+	hdr[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	hdr[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	hdr[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	le.PutUint16(hdr[16:], uint16(elf.ET_EXEC))
+	le.PutUint16(hdr[18:], uint16(elf.EM_X86_64))
+	le.PutUint32(hdr[20:], uint32(elf.EV_CURRENT))
+	shoff := 64 + len(shstrtab) + len(data)
+	le.PutUint64(hdr[40:], uint64(shoff))
+	le.PutUint16(hdr[52:], 64)
+	le.PutUint16(hdr[58:], 64)
+	le.PutUint16(hdr[60:], 3)
+	le.PutUint16(hdr[62:], 1)
+	b.Write(hdr)
+	b.Write(shstrtab)
+	b.Write(data)
+	sect := func(name uint32, typ elf.SectionType, off, size int) {
+		sh := make([]byte, 64)
+		le.PutUint32(sh, name)
+		le.PutUint32(sh[4:], uint32(typ))
+		le.PutUint64(sh[24:], uint64(off))
+		le.PutUint64(sh[32:], uint64(size))
+		le.PutUint64(sh[48:], 1)
+		b.Write(sh)
+	}
+	sect(0, elf.SHT_NULL, 0, 0)
+	sect(1, elf.SHT_STRTAB, 64, len(shstrtab))
+	sect(uint32(len("\x00.shstrtab\x00")), typ, 64+len(shstrtab), len(data))
+	return b.Bytes()
+}
+
+// testNote returns an ELF note named name of type typ, with namesz as
+// the size of the name it claims.
+func testNote(name string, namesz uint32, typ uint32, desc []byte) []byte {
+	le := binary.LittleEndian
+	n := make([]byte, 12)
+	le.PutUint32(n, namesz)
+	le.PutUint32(n[4:], uint32(len(desc)))
+	le.PutUint32(n[8:], typ)
+	pad := func(b []byte) []byte {
+		for len(b)%4 != 0 {
+			b = append(b, 0)
+		}
+		return b
+	}
+	n = append(n, pad(append([]byte(name), 0))...)
+	return append(n, pad(desc)...)
+}
+
+// binarySeeds are the crafted binaries the parsers were hardened against:
+// a note whose name size wraps around in 32 bits, headers cut short and
+// the test binary itself.
+func binarySeeds(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte("\x7fELF"))
+	f.Add([]byte("MZ\x00\x00"))
+	f.Add([]byte("\xcf\xfa\xed\xfe"))
+	f.Add([]byte("\xca\xfe\xba\xbe\x00\x00\x00\x02"))
+	f.Add(testELF(".note.go.buildid", elf.SHT_NOTE, testNote("Go", 4, 4, []byte("id"))))
+	f.Add(testELF(".note.go.buildid", elf.SHT_NOTE, testNote("Go", 0xfffffffe, 4, nil)))
+	f.Add(testELF(".go.buildinfo", elf.SHT_PROGBITS, []byte("\xff Go buildinf:\x08\x02")))
+	if exe, err := os.Executable(); err == nil {
+		if data, err := os.ReadFile(exe); err == nil {
+			f.Add(data)
+			f.Add(data[:len(data)/2])
+		}
+	}
+}
+
+func FuzzNewBinary(f *testing.F) {
+	binarySeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		b, err := newBinaryFile(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		defer b.Close()
+		b.PtrSize()
+		b.Arch()
+		b.BuildInfo()
+	})
+}
+
+func FuzzScanBinary(f *testing.F) {
+	binarySeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Panics are recovered into these errors, but should not
+		// happen in the first place.
+		if _, err := scanBinary("", bytes.NewReader(data), nil); err != nil && strings.HasPrefix(err.Error(), "malformed binary") {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzELFNote(f *testing.F) {
+	f.Add(testNote("Go", 3, 4, []byte("id")))
+	f.Add(testNote("Go", 0xfffffffe, 4, nil))
+	f.Add(testNote("Go", 0xfffffffd, 4, []byte("id")))
+	f.Add([]byte("\x04\x00\x00\x00\xff\xff\xff\xff\x04\x00\x00\x00Go\x00\x00"))
+	f.Fuzz(func(t *testing.T, note []byte) {
+		ef, err := elf.NewFile(bytes.NewReader(testELF(".note.go.buildid", elf.SHT_NOTE, note)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if desc := elfNote(ef, ".note.go.buildid", "Go", 4); len(desc) > len(note) {
+			t.Fatalf("description of %d bytes in a note of %d", len(desc), len(note))
+		}
+	})
+}
+
+func FuzzDWARFUnitHeader(f *testing.F) {
+	f.Add([]byte("\x07\x00\x00\x00\x04\x00\x00\x00\x00\x00\x08"))
+	f.Add([]byte("\xff\xff\xff\xff\x07\x00\x00\x00\x00\x00\x00\x00\x05\x00\x01"))
+	f.Add([]byte("\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff\x05\x00"))
+	f.Add([]byte("\xfe\xff\xff\xff\x02\x00"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			v, n := dwarfUnitHeader(data, order)
+			if n < 0 || n > len(data) || (n == 0) != (v == 0) {
+				t.Fatalf("version %d, length %d of %d bytes", v, n, len(data))
+			}
+		}
+	})
+}
+
+func FuzzParseRegQuery(f *testing.F) {
+	f.Add([]byte("\r\nHKEY_LOCAL_MACHINE\\SOFTWARE\\Microsoft\\Windows\\CurrentVersion\\Uninstall\\Foo\r\n" +
+		"    DisplayName    REG_SZ    Foo 2.1.0\r\n" +
+		"    InstallLocation    REG_EXPAND_SZ    %ProgramFiles%\\Foo\r\n"))
+	f.Add([]byte("    REG_SZ    \r\nHKEY_\r\n    REG_\r\n        REG_SZ"))
+	f.Fuzz(func(t *testing.T, out []byte) {
+		parseRegQuery(out)
+	})
+}
+
+func FuzzPlistStrings(f *testing.F) {
+	f.Add([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0"><dict>
+	<key>CFBundleExecutable</key><string>Foo</string>
+	<key>CFBundleShortVersionString</key><string>2.1.0</string>
+	<key>LSEnvironment</key><dict><key>A</key><string>B</string></dict>
+</dict></plist>`))
+	f.Add([]byte(`<plist><dict><key><key>`))
+	f.Add([]byte(`<plist><dict><string>`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		plistStrings(data)
+	})
+}
+
+// archiveSeeds are archives holding the crafted binaries: as tar and zip
+// members, compressed and nested in each other.
+func archiveSeeds(f *testing.F) {
+	elfNote := testELF(".note.go.buildid", elf.SHT_NOTE, testNote("Go", 0xfffffffe, 4, nil))
+	var tb bytes.Buffer
+	tw := tar.NewWriter(&tb)
+	for _, m := range []struct {
+		name string
+		data []byte
+	}{{"bin/note", elfNote}, {"bin/empty", nil}, {"bin/elf", []byte("\x7fELF")}} {
+		tw.WriteHeader(&tar.Header{Name: m.name, Mode: 0755, Size: int64(len(m.data)), Typeflag: tar.TypeReg})
+		tw.Write(m.data)
+	}
+	tw.Close()
+	var zb bytes.Buffer
+	zw := zip.NewWriter(&zb)
+	w, _ := zw.Create("inner.tar")
+	w.Write(tb.Bytes())
+	w, _ = zw.Create("bin/note")
+	w.Write(elfNote)
+	zw.Close()
+	var gb bytes.Buffer
+	gw := gzip.NewWriter(&gb)
+	gw.Write(tb.Bytes())
+	gw.Close()
+	for _, name := range []string{"a.tar", "a.zip", "a.tar.gz", "a.gz"} {
+		f.Add(name, tb.Bytes())
+		f.Add(name, zb.Bytes())
+		f.Add(name, gb.Bytes())
+	}
+	f.Add("a.tar", []byte{})
+	f.Add("a.zip", []byte("PK\x03\x04"))
+	f.Add("a.tar.gz", []byte("\x1f\x8b\x08"))
+}
+
+func FuzzScanArchive(f *testing.F) {
+	archiveSeeds(f)
+	f.Fuzz(func(t *testing.T, name string, data []byte) {
+		scanArchive(name, bytes.NewReader(data), func(member, ver string, err error) {
+			if err != nil && strings.HasPrefix(err.Error(), "malformed binary") {
+				t.Errorf("%s: %v", member, err)
+			}
+		})
+	})
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// probeSource is the program gen-testdata builds. The variable gives the
+// -var code paths something to read.
+const probeSource = `package main
+
+import "fmt"
+
+var probe = "gover"
+
+func main() { fmt.Println(probe) }
+`
+
+// probeModule is the go.mod of the probe program. The go line is old
+// enough for every toolchain with module support.
+const probeModule = "module example.com/probe\n\ngo 1.13\n"
+
+// fixture describes a binary written by gen-testdata, as listed in the
+// manifest.json next to it, so tests can check gover's results against
+// the way the binary was built.
+type fixture struct {
+	File      string `json:"file"`
+	GoVersion string `json:"goVersion"`
+	GOOS      string `json:"goos"`
+	GOARCH    string `json:"goarch"`
+	BuildMode string `json:"buildmode"`
+	Stripped  bool   `json:"stripped"`
+}
+
+// splitList splits a comma-separated flag value.
+func splitList(s string) []string {
+	var list []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// goCommand returns a go command running in the probe directory src with
+// the Go toolchain ver. "local" is the go command on $PATH. Other versions
+// use a golang.org/dl wrapper like go1.20.3 if installed, and otherwise
+// the local go command with GOTOOLCHAIN, which downloads toolchains of Go
+// 1.21 and later. With docker, the command runs in the golang image of
+// the version instead, with src mounted at /src and out at /out.
+func goCommand(ver, src, out string, env []string, docker bool, args ...string) *exec.Cmd {
+	if docker {
+		image := "golang:" + strings.TrimPrefix(ver, "go")
+		if ver == "local" {
+			image = "golang"
+		}
+		dargs := []string{"run", "--rm", "-v", src + ":/src", "-v", out + ":/out", "-w", "/src"}
+		for _, e := range env {
+			dargs = append(dargs, "-e", e)
+		}
+		dargs = append(dargs, image, "go")
+		return exec.Command("docker", append(dargs, args...)...)
+	}
+	gocmd := "go"
+	if ver != "local" {
+		if p, err := exec.LookPath(ver); err == nil {
+			gocmd = p
+		} else {
+			env = append(env, "GOTOOLCHAIN="+ver)
+		}
+	}
+	cmd := exec.Command(gocmd, args...)
+	cmd.Dir = src
+	cmd.Env = append(os.Environ(), env...)
+	return cmd
+}
+
+// toolchainVersion returns the version the Go toolchain ver reports, run
+// like goCommand does. go env doesn't know GOVERSION before Go 1.16 and
+// prints an empty line for it, so the output of go version is parsed
+// then.
+func toolchainVersion(ver, src, out string, docker bool) (string, error) {
+	msg, err := goCommand(ver, src, out, nil, docker, "env", "GOVERSION").Output()
+	if err != nil {
+		return "", err
+	}
+	if v := strings.TrimSpace(string(msg)); v != "" {
+		return v, nil
+	}
+	msg, err = goCommand(ver, src, out, nil, docker, "version").Output()
+	if err != nil {
+		return "", err
+	}
+	// go version go1.15.15 linux/amd64
+	f := strings.Fields(string(msg))
+	if len(f) < 3 || !strings.HasPrefix(f[2], "go") {
+		return "", fmt.Errorf("unexpected go version output %q", strings.TrimSpace(string(msg)))
+	}
+	return f[2], nil
+}
+
+// genTestdataMain implements "gover gen-testdata": it builds a small probe
+// program for every combination of the Go versions, platforms, build
+// modes and stripping given, writing the binaries and a manifest.json to
+// the output directory. Combinations a toolchain doesn't support are
+// logged and skipped.
+func genTestdataMain(args []string) int {
+	fs := flag.NewFlagSet("gen-testdata", flag.ExitOnError)
+	fs.Usage = usage
+	outDir := fs.String("o", "testdata/fixtures", "write the binaries to `dir`")
+	versions := fs.String("go", "local", "comma-separated Go `versions` to build with, like go1.20.3, or local for the go command on $PATH")
+	platforms := fs.String("platforms", "linux/amd64,linux/386,linux/arm64,windows/amd64,darwin/arm64", "comma-separated `goos/goarch` pairs to build for")
+	modes := fs.String("buildmodes", "exe,pie", "comma-separated build `modes`")
+	docker := fs.Bool("docker", false, "build in the golang container images instead of with local toolchains")
+	setup := addGlobalFlags(fs, slog.LevelInfo)
+	if len(parseArgs(fs, args)) > 0 {
+		usage()
+	}
+	setup()
+
+	fail := func(msg string, err error) int {
+		slog.Error(msg, "err", err)
+		return exitError
+	}
+	out, err := filepath.Abs(*outDir)
+	if err != nil {
+		return fail("creating output directory failed", err)
+	}
+	if err := os.MkdirAll(out, 0777); err != nil {
+		return fail("creating output directory failed", err)
+	}
+	src, err := ioutil.TempDir("", "gover-probe")
+	if err != nil {
+		return fail("creating probe failed", err)
+	}
+	defer os.RemoveAll(src)
+	if err := os.WriteFile(filepath.Join(src, "main.go"), []byte(probeSource), 0666); err != nil {
+		return fail("creating probe failed", err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "go.mod"), []byte(probeModule), 0666); err != nil {
+		return fail("creating probe failed", err)
+	}
+
+	exit := 0
+	fixtures := []fixture{}
+	for _, ver := range splitList(*versions) {
+		// The manifest records the version the toolchain reports, not
+		// what gover finds, so that tests can check the latter.
+		goVersion, err := toolchainVersion(ver, src, out, *docker)
+		if err != nil {
+			slog.Error("running toolchain failed", "go", ver, "err", err)
+			exit = worseExit(exit, exitError)
+			continue
+		}
+		for _, platform := range splitList(*platforms) {
+			goos, goarch, ok := strings.Cut(platform, "/")
+			if !ok {
+				slog.Error("invalid platform", "platform", platform)
+				exit = exitError
+				continue
+			}
+			for _, mode := range splitList(*modes) {
+				for _, strip := range []bool{false, true} {
+					name := fmt.Sprintf("%s_%s_%s_%s", ver, goos, goarch, mode)
+					var args []string
+					if mode != "exe" {
+						args = append(args, "-buildmode="+mode)
+					}
+					if strip {
+						name += "_stripped"
+						args = append(args, "-ldflags=-s -w")
+					}
+					if goos == "windows" {
+						name += ".exe"
+					}
+					file := filepath.Join(out, name)
+					if *docker {
+						file = "/out/" + name
+					}
+					args = append(append([]string{"build"}, args...), "-o", file, ".")
+					env := []string{"GOOS=" + goos, "GOARCH=" + goarch, "CGO_ENABLED=0", "GO111MODULE=on", "GOFLAGS=-mod=mod"}
+					cmd := goCommand(ver, src, out, env, *docker, args...)
+					if msg, err := cmd.CombinedOutput(); err != nil {
+						slog.Warn("build failed, skipping", "fixture", name, "err", err, "output", strings.TrimSpace(string(msg)))
+						continue
+					}
+					slog.Info("built", "fixture", name)
+					fixtures = append(fixtures, fixture{File: name, GoVersion: goVersion, GOOS: goos, GOARCH: goarch, BuildMode: mode, Stripped: strip})
+				}
+			}
+		}
+	}
+
+	manifest, err := json.MarshalIndent(fixtures, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(filepath.Join(out, "manifest.json"), append(manifest, '\n'))
+	}
+	if err != nil {
+		return fail("writing manifest failed", err)
+	}
+	fmt.Printf("wrote %d binaries to %s\n", len(fixtures), out)
+	return exit
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// TestFixtures scans the binaries gen-testdata wrote and checks that the
+// versions found are those of the toolchains they were built with. The
+// fixtures are read from $GOVER_FIXTURES or testdata/fixtures; if there
+// are none, a few are built with the local go command.
+func TestFixtures(t *testing.T) {
+	dir := os.Getenv("GOVER_FIXTURES")
+	if dir == "" {
+		dir = filepath.Join("testdata", "fixtures")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "manifest.json")); err != nil {
+		if testing.Short() {
+			t.Skip("no fixtures, and building them is skipped in short mode")
+		}
+		if _, err := exec.LookPath("go"); err != nil {
+			t.Skip("no fixtures, and no go command to build them with")
+		}
+		dir = t.TempDir()
+		args := []string{"-o", dir, "-log-level", "warn", "-platforms", "linux/amd64,windows/386,darwin/arm64", "-buildmodes", "exe"}
+		if exit := genTestdataMain(args); exit != exitOK {
+			t.Fatalf("gen-testdata exited with %d", exit)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fixtures []fixture
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("manifest lists no fixtures")
+	}
+	for _, f := range fixtures {
+		t.Run(f.File, func(t *testing.T) {
+			ver, err := findVersion(filepath.Join(dir, f.File))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ver != f.GoVersion {
+				t.Errorf("found %s, built with %s", ver, f.GoVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const githubAPI = "https://api.github.com"
+
+type githubRelease struct {
+	TagName string        `json:"tag_name"`
+	Assets  []githubAsset `json:"assets"`
+}
+
+type githubAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// ghMain implements "gover gh": it downloads the assets of a GitHub
+// release and reports the Go version of every binary found in them.
+func ghMain(args []string) int {
+	fs := flag.NewFlagSet("gh", flag.ExitOnError)
+	fs.Usage = usage
+	colorMode := addColorFlag(fs)
+	addLimitFlags(fs)
+	addArchiveJobsFlag(fs)
+	addPluginFlags(fs)
+	addDownloadFlags(fs)
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	releases := parseArgs(fs, args)
+	setup()
+	if len(releases) < 1 {
+		usage()
+	}
+
+	r := &reporter{names: true}
+	r.color = useColor(*colorMode, r.stdout())
+	for _, rel := range releases {
+		if err := ghScanRelease(rel, r); err != nil {
+			r.report(rel, "", err)
+		}
+	}
+	return r.exit
+}
+
+// ghScanRelease scans the release named by ref, which has the form
+// owner/repo[@tag]. Without a tag the latest release is used.
+func ghScanRelease(ref string, r *reporter) error {
+	repo, tag := ref, ""
+	if i := strings.Index(ref, "@"); i >= 0 {
+		repo, tag = ref[:i], ref[i+1:]
+	}
+	if strings.Count(repo, "/") != 1 {
+		return fmt.Errorf("expected owner/repo[@tag]")
+	}
+
+	u := githubAPI + "/repos/" + repo + "/releases/latest"
+	if tag != "" {
+		u = githubAPI + "/repos/" + repo + "/releases/tags/" + tag
+	}
+	body, err := githubGet(u, "application/vnd.github+json")
+	if err != nil {
+		return err
+	}
+	var rel githubRelease
+	err = json.NewDecoder(body).Decode(&rel)
+	body.Close()
+	if err != nil {
+		return err
+	}
+
+	prefix := repo + "@" + rel.TagName + "/"
+	for _, a := range rel.Assets {
+		if skipAsset(a.Name) {
+			continue
+		}
+		err := scanRemote(a.Name, a.URL, githubHeader("application/octet-stream"), maxDownload, func(name, ver string, err error) {
+			if isNoVersion(err) {
+				return
+			}
+			r.report(prefix+name, ver, err)
+		})
+		if err != nil {
+			r.report(prefix+a.Name, "", err)
+		}
+	}
+	return nil
+}
+
+// skipAsset reports whether a release asset is metadata that never
+// contains binaries.
+func skipAsset(name string) bool {
+	name = strings.ToLower(name)
+	for _, ext := range []string{".txt", ".md", ".json", ".sig", ".asc", ".pem", ".sbom", ".spdx", ".sha256", ".sha512", ".sha256sum", ".sha512sum"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func githubGet(url, accept string) (io.ReadCloser, error) {
+	resp, err := httpGet(url, githubHeader(accept))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// githubHeader returns the request headers for the GitHub API, including
+// the token from $GITHUB_TOKEN if set.
+func githubHeader(accept string) http.Header {
+	h := http.Header{}
+	h.Set("Accept", accept)
+	if tok := os.Getenv("GITHUB_TOKEN"); tok != "" {
+		h.Set("Authorization", "Bearer "+tok)
+	}
+	return h
+}
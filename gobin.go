@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// goEnv returns the value of a go environment variable, preferring the
+// process environment over asking the installed go command.
+func goEnv(key string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	out, err := exec.Command("go", "env", key).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// localGoVersion returns the version of the go command found on PATH.
+func localGoVersion() (string, error) {
+	out, err := exec.Command("go", "version").Output()
+	if err != nil {
+		return "", fmt.Errorf("go version: %s", err)
+	}
+	// go version go1.5.2 linux/amd64
+	f := strings.Fields(string(out))
+	if len(f) < 3 {
+		return "", fmt.Errorf("go version: unexpected output %q", out)
+	}
+	return f[2], nil
+}
+
+// gobinDirs returns the directories that go install puts tools into.
+func gobinDirs() []string {
+	var dirs []string
+	seen := make(map[string]bool)
+	add := func(dir string) {
+		if dir == "" || seen[dir] {
+			return
+		}
+		seen[dir] = true
+		dirs = append(dirs, dir)
+	}
+	add(goEnv("GOBIN"))
+	for _, p := range filepath.SplitList(goEnv("GOPATH")) {
+		if p != "" {
+			add(filepath.Join(p, "bin"))
+		}
+	}
+	if root := goEnv("GOROOT"); root != "" {
+		add(filepath.Join(root, "bin"))
+	}
+	return dirs
+}
+
+// gobinScanDirs are the gobinDirs scanned with -gobin. scanPaths scans
+// the executables in them, without descending into subdirectories, and
+// drops those that aren't Go binaries, as when walking directories.
+var gobinScanDirs map[string]bool
+
+// gobinTools calls fn for every executable in dir, or with the error if
+// it can't be read. A dir that doesn't exist has none.
+func gobinTools(dir string, fn func(path string, err error)) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fn(dir, err)
+		}
+		return
+	}
+	for _, fi := range fis {
+		file := filepath.Join(dir, fi.Name())
+		if fi, err := os.Stat(file); err == nil && isExecutable(fi) {
+			fn(file, nil)
+		}
+	}
+}
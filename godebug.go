@@ -0,0 +1,122 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// godebugDefault is a GODEBUG setting whose default changed in a Go
+// release. Binaries of main modules declaring an older Go version in
+// go.mod get the old value by default, recorded in DefaultGODEBUG.
+type godebugDefault struct {
+	name    string
+	changed int // minor version the default changed in; 21 means Go 1.21
+	old     string
+	removed int // minor version the setting was removed in, if any
+}
+
+// godebugDefaults is the table of GODEBUG settings with changed defaults,
+// following internal/godebugs in the Go distribution.
+var godebugDefaults = []godebugDefault{
+	{name: "asynctimerchan", changed: 23, old: "1", removed: 27},
+	{name: "containermaxprocs", changed: 25, old: "0"},
+	{name: "cryptocustomrand", changed: 26, old: "1"},
+	{name: "decoratemappings", changed: 25, old: "0"},
+	{name: "gotestjsonbuildtext", changed: 24, old: "1"},
+	{name: "gotypesalias", changed: 23, old: "0", removed: 27},
+	{name: "httpcookiemaxnum", changed: 24, old: "0"},
+	{name: "httplaxcontentlength", changed: 22, old: "1"},
+	{name: "httpmuxgo121", changed: 22, old: "1"},
+	{name: "httpservecontentkeepheaders", changed: 23, old: "1"},
+	{name: "multipathtcp", changed: 24, old: "0"},
+	{name: "netedns0", changed: 19, old: "0"},
+	{name: "panicnil", changed: 21, old: "1"},
+	{name: "randseednop", changed: 24, old: "0"},
+	{name: "rsa1024min", changed: 24, old: "0"},
+	{name: "tls10server", changed: 22, old: "1", removed: 27},
+	{name: "tls3des", changed: 23, old: "1", removed: 27},
+	{name: "tlskyber", changed: 23, old: "0", removed: 24},
+	{name: "tlsmlkem", changed: 24, old: "0"},
+	{name: "tlsrsakex", changed: 22, old: "1", removed: 27},
+	{name: "tlssecpmlkem", changed: 26, old: "0"},
+	{name: "tlssha1", changed: 25, old: "1"},
+	{name: "tlsunsafeekm", changed: 22, old: "1", removed: 27},
+	{name: "tracebacklabels", changed: 27, old: "0"},
+	{name: "updatemaxprocs", changed: 25, old: "0"},
+	{name: "urlmaxqueryparams", changed: 24, old: "0"},
+	{name: "urlstrictcolons", changed: 26, old: "0"},
+	{name: "winreadlinkvolume", changed: 23, old: "0"},
+	{name: "winsymlink", changed: 23, old: "0"},
+	{name: "x509keypairleaf", changed: 23, old: "0", removed: 27},
+	{name: "x509negativeserial", changed: 23, old: "1"},
+	{name: "x509rsacrt", changed: 24, old: "0"},
+	{name: "x509sha256skid", changed: 25, old: "0"},
+	{name: "x509sslcertoverrideplatform", changed: 27, old: "0"},
+	{name: "x509usepolicies", changed: 24, old: "0"},
+}
+
+// goMinor returns the minor version of the Go release ver, such as 21 for
+// go1.21.5, or -1 if ver is not a release.
+func goMinor(ver string) int {
+	ver = strings.TrimPrefix(ver, "go1.")
+	if i := strings.IndexAny(ver, ". -"); i >= 0 {
+		ver = ver[:i]
+	}
+	n, err := strconv.Atoi(ver)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// godebugProvenance marks where the settings of a binary built by the
+// toolchain goVersion come from: the defaults the toolchain applies for
+// the Go version declared in go.mod, or explicit godebug lines and
+// //go:debug directives. The declared Go version isn't recorded, so it is
+// inferred as the oldest whose defaults are all present. It is returned
+// as a minor version, or -1 if no setting is a default.
+func godebugProvenance(goVersion string, settings []godebugSetting) int {
+	toolchain := goMinor(goVersion)
+	if toolchain < 0 {
+		return -1
+	}
+	present := make(map[string]bool)
+	for _, s := range settings {
+		present[s.Name] = true
+	}
+	// defaultsFor returns the settings the toolchain defaults for a
+	// main module declaring Go 1.lang, or false if one is missing.
+	defaultsFor := func(lang int) (map[string]string, bool) {
+		defaults := make(map[string]string)
+		for _, d := range godebugDefaults {
+			if d.changed <= lang || d.changed > toolchain || d.removed != 0 && d.removed <= toolchain {
+				continue
+			}
+			if !present[d.name] {
+				return nil, false
+			}
+			defaults[d.name] = d.old
+		}
+		return defaults, true
+	}
+
+	lang := -1
+	var defaults map[string]string
+	for l := toolchain - 1; l >= 0; l-- {
+		d, ok := defaultsFor(l)
+		if !ok {
+			break
+		}
+		if len(d) > len(defaults) {
+			lang, defaults = l, d
+		}
+	}
+	for i, s := range settings {
+		if old, ok := defaults[s.Name]; ok && old == s.Value {
+			settings[i].Source = "default"
+		} else {
+			settings[i].Source = "directive"
+		}
+	}
+	return lang
+}
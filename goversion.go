@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// goVersion is a parsed Go release version such as go1.21.3 or go1.22rc1.
+type goVersion struct {
+	major, minor, patch int
+	// pre orders pre-releases before the release itself: 0 for betas,
+	// 1 for release candidates and 2 for final releases.
+	pre    int
+	preNum int
+}
+
+// parseGoVersion parses a version as reported by runtime.Version. Devel
+// builds and other unrecognized versions are rejected. Trailing
+// annotations such as " X:boringcrypto" are ignored.
+func parseGoVersion(s string) (goVersion, bool) {
+	var v goVersion
+	if i := strings.IndexAny(s, " \t"); i >= 0 {
+		s = s[:i]
+	}
+	if !strings.HasPrefix(s, "go") {
+		return v, false
+	}
+	s = s[2:]
+	v.pre = 2
+	for _, p := range []string{"beta", "rc"} {
+		if i := strings.Index(s, p); i >= 0 {
+			n, err := strconv.Atoi(s[i+len(p):])
+			if err != nil {
+				return v, false
+			}
+			v.preNum = n
+			if p == "beta" {
+				v.pre = 0
+			} else {
+				v.pre = 1
+			}
+			s = s[:i]
+			break
+		}
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return v, false
+	}
+	nums := []*int{&v.major, &v.minor, &v.patch}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		*nums[i] = n
+	}
+	return v, true
+}
+
+// compareGoVersions orders version strings by release. Versions that
+// can't be parsed, such as devel builds, sort after all releases and
+// among themselves by name.
+func compareGoVersions(a, b string) int {
+	va, oka := parseGoVersion(a)
+	vb, okb := parseGoVersion(b)
+	switch {
+	case oka && okb:
+		if va.less(vb) {
+			return -1
+		}
+		if vb.less(va) {
+			return 1
+		}
+		return 0
+	case oka:
+		return -1
+	case okb:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// less reports whether v is an older release than w.
+func (v goVersion) less(w goVersion) bool {
+	switch {
+	case v.major != w.major:
+		return v.major < w.major
+	case v.minor != w.minor:
+		return v.minor < w.minor
+	case v.pre != w.pre:
+		return v.pre < w.pre
+	case v.preNum != w.preNum:
+		return v.preNum < w.preNum
+	}
+	return v.patch < w.patch
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"debug/dwarf"
+	"debug/gosym"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// graphNode is a module in the dependency graph of a binary.
+type graphNode struct {
+	depModule
+	Main bool `json:"main,omitempty"`
+}
+
+// graphEdge is a requirement of module From on module To. Source tells
+// how it was recovered: "inlining" if code of From inlines code of To, as
+// recorded in the DWARF info, or "buildinfo" if the build info only shows
+// that To was compiled in for the main module From.
+type graphEdge struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Source string `json:"source"`
+}
+
+// graphResult is the graph -json output for one binary.
+type graphResult struct {
+	File  string      `json:"file"`
+	Nodes []graphNode `json:"nodes"`
+	Edges []graphEdge `json:"edges"`
+}
+
+// moduleGraph returns the dependency graph of the modules in the build info
+// of b. The build info lists the modules but not which requires which, so
+// edges between dependencies are only recovered where one inlines another,
+// and every module no other one is found to require is attached to the
+// main module.
+func moduleGraph(b *binaryFile) (*graphResult, error) {
+	bi, err := b.BuildInfo()
+	if err != nil {
+		return nil, err
+	}
+	res := &graphResult{File: b.name, Nodes: []graphNode{}, Edges: []graphEdge{}}
+	if bi.Main.Path != "" {
+		res.Nodes = append(res.Nodes, graphNode{depModule: newDepModule(&bi.Main), Main: true})
+	}
+	for _, m := range bi.Deps {
+		res.Nodes = append(res.Nodes, graphNode{depModule: newDepModule(m)})
+	}
+
+	required := make(map[string]bool)
+	if d, err := b.DWARF(); err == nil {
+		for _, e := range inlinedModuleEdges(d, res.Nodes) {
+			res.Edges = append(res.Edges, e)
+			required[e.To] = true
+		}
+	}
+	if bi.Main.Path != "" {
+		for _, n := range res.Nodes[1:] {
+			if !required[n.Path] {
+				res.Edges = append(res.Edges, graphEdge{From: bi.Main.Path, To: n.Path, Source: "buildinfo"})
+			}
+		}
+	}
+	return res, nil
+}
+
+// inlinedModuleEdges returns the edges between the modules of nodes that
+// the inlined calls in d show, sorted. The compilation units of Go code
+// are named after their package and the functions inlined into them refer
+// to the abstract function they are an instance of, named after the
+// package defining it; calls inlined into inlined code are made by the
+// package of the latter.
+func inlinedModuleEdges(d *dwarf.Data, nodes []graphNode) []graphEdge {
+	funcs := make(map[dwarf.Offset]string)
+	r := d.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil || e == nil {
+			break
+		}
+		if e.Tag == dwarf.TagSubprogram {
+			if name, _ := e.Val(dwarf.AttrName).(string); name != "" {
+				funcs[e.Offset] = name
+			}
+		}
+	}
+
+	moduleOf := func(pkg string) string {
+		best := ""
+		for _, n := range nodes {
+			p := n.Path
+			if (pkg == p || strings.HasPrefix(pkg, p+"/") || n.Main && pkg == "main") && len(p) > len(best) {
+				best = p
+			}
+		}
+		return best
+	}
+	seen := make(map[graphEdge]bool)
+	var edges []graphEdge
+	// callers holds the package of the code each open entry is part of.
+	var callers []string
+	r = d.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil || e == nil {
+			break
+		}
+		if e.Tag == 0 {
+			if len(callers) > 0 {
+				callers = callers[:len(callers)-1]
+			}
+			continue
+		}
+		pkg := ""
+		if len(callers) > 0 {
+			pkg = callers[len(callers)-1]
+		}
+		switch e.Tag {
+		case dwarf.TagCompileUnit:
+			if lang, _ := e.Val(dwarf.AttrLanguage).(int64); lang != dwLangGo {
+				r.SkipChildren()
+				continue
+			}
+			pkg, _ = e.Val(dwarf.AttrName).(string)
+		case dwarf.TagInlinedSubroutine:
+			origin, _ := e.Val(dwarf.AttrAbstractOrigin).(dwarf.Offset)
+			callee := funcPackage(&gosym.Func{Sym: &gosym.Sym{Name: funcs[origin]}})
+			from, to := moduleOf(pkg), moduleOf(callee)
+			if edge := (graphEdge{From: from, To: to, Source: "inlining"}); from != "" && to != "" && from != to && !seen[edge] {
+				seen[edge] = true
+				edges = append(edges, edge)
+			}
+			pkg = callee
+		}
+		if e.Children {
+			callers = append(callers, pkg)
+		}
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	return edges
+}
+
+// writeDOT writes g as a Graphviz digraph named after its file. Edges only
+// known from the build info are dashed.
+func writeDOT(g *graphResult) {
+	fmt.Printf("digraph %s {\n", strconv.Quote(g.File))
+	fmt.Println("\trankdir=LR;")
+	fmt.Println("\tnode [shape=box];")
+	for _, n := range g.Nodes {
+		label := n.Path
+		if n.Version != "" {
+			label += "\n" + n.Version
+		}
+		if n.Replace != "" {
+			label += "\n=> " + n.Replace
+		}
+		attrs := "label=" + strconv.Quote(label)
+		if n.Main {
+			attrs += ", style=bold"
+		}
+		fmt.Printf("\t%s [%s];\n", strconv.Quote(n.Path), attrs)
+	}
+	for _, e := range g.Edges {
+		style := ""
+		if e.Source == "buildinfo" {
+			style = " [style=dashed]"
+		}
+		fmt.Printf("\t%s -> %s%s;\n", strconv.Quote(e.From), strconv.Quote(e.To), style)
+	}
+	fmt.Println("}")
+}
+
+// graphMain implements "gover graph": it exports the dependency graphs of
+// the modules compiled into binaries, as Graphviz DOT or JSON.
+func graphMain(args []string) int {
+	fs := flag.NewFlagSet("graph", flag.ExitOnError)
+	fs.Usage = usage
+	jsonOut := fs.Bool("json", false, "print the graphs as JSON instead of DOT")
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	files := parseArgs(fs, args)
+	setup()
+	if len(files) < 1 {
+		usage()
+	}
+
+	exit := 0
+	results := []interface{}{}
+	for _, file := range files {
+		var g *graphResult
+		b, err := openBinary(file)
+		if err == nil {
+			g, err = moduleGraph(b)
+			b.Close()
+		}
+		if err != nil {
+			slog.Error("reading build info failed", "file", file, "err", err)
+			exit = worseExit(exit, readExit(err))
+			if *jsonOut {
+				results = append(results, newFileFailure(file, err))
+			}
+			continue
+		}
+		if *jsonOut {
+			results = append(results, g)
+			continue
+		}
+		writeDOT(g)
+	}
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(results)
+	}
+	return exit
+}
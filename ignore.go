@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFile is the name of the file listing the paths recursive scans
+// skip, relative to the directory it is in.
+const ignoreFile = ".goverignore"
+
+// useIgnoreFiles is cleared by -no-ignore.
+var useIgnoreFiles = true
+
+// oneFileSystem is set by -one-file-system.
+var oneFileSystem bool
+
+// ignoreRule is a pattern of an ignore file.
+type ignoreRule struct {
+	elems    []string // pattern split at "/"
+	negate   bool     // "!pattern" re-includes matching paths
+	dirOnly  bool     // "pattern/" only matches directories
+	anchored bool     // patterns containing a "/" match from the root
+}
+
+// ignoreList is the rules of an ignore file, in order. Like in gitignore
+// files, the last matching rule decides.
+type ignoreList []ignoreRule
+
+// loadIgnore reads the ignore file in the directory root. It returns nil
+// if there is none.
+func loadIgnore(root string) (ignoreList, error) {
+	f, err := os.Open(longPath(filepath.Join(root, ignoreFile)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseIgnore(f)
+}
+
+// parseIgnore parses the gitignore-style patterns read from r: blank lines and
+// lines starting with "#" are skipped, "\" escapes a leading "#" or "!",
+// and "**" matches any number of directories.
+func parseIgnore(r io.Reader) (ignoreList, error) {
+	var l ignoreList
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		var r ignoreRule
+		if line[0] == '!' {
+			r.negate = true
+			line = line[1:]
+		} else if line[0] == '\\' {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			r.anchored = true
+			line = strings.TrimLeft(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		r.elems = strings.Split(line, "/")
+		l = append(l, r)
+	}
+	return l, sc.Err()
+}
+
+// ignored reports whether the slash-separated path rel, relative to the
+// directory of the ignore file, is excluded.
+func (l ignoreList) ignored(rel string, dir bool) bool {
+	elems := strings.Split(rel, "/")
+	ignored := false
+	for _, r := range l {
+		if r.dirOnly && !dir {
+			continue
+		}
+		name := elems
+		if !r.anchored {
+			// Patterns without a slash match the name at any depth.
+			name = elems[len(elems)-1:]
+		}
+		if matchElems(r.elems, name) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// matchElems reports whether the path elements name match the pattern
+// elements pat.
+func matchElems(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			if len(pat) == 1 {
+				// A trailing "**" matches everything inside.
+				return len(name) > 0
+			}
+			for i := range name {
+				if matchElems(pat[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], name[0]); !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// walkFiles walks the file tree at root like filepath.Walk, calling fn for
+// every file and error, but skipping the paths excluded by the ignore file
+// in root and, if root is a preset directory, by its preset. Ignored
+// directories are not descended into, nor are pseudo file systems like
+// /proc and /sys below root, and with oneFileSystem, other file systems
+// mounted below root.
+func walkFiles(root string, fn func(path string, fi os.FileInfo, err error)) {
+	var (
+		ignore  ignoreList
+		rootDev uint64
+		haveDev bool
+		pseudo  = make(map[uint64]bool)
+	)
+	if fi, err := os.Stat(longPath(root)); err == nil {
+		if fi.IsDir() && useIgnoreFiles {
+			if ignore, err = loadIgnore(root); err != nil {
+				slog.Warn("reading ignore file failed", "dir", root, "err", err)
+			}
+		}
+		if l := presetIgnore[root]; fi.IsDir() && l != nil {
+			// The ignore file goes last, so it can re-include paths.
+			ignore = append(append(ignoreList{}, l...), ignore...)
+		}
+		if oneFileSystem {
+			rootDev, haveDev = fileDevice(fi)
+		}
+	}
+	// The tree is walked by its long path, but files are reported below
+	// root as given.
+	walkRoot := longPath(root)
+	filepath.Walk(walkRoot, func(path string, fi os.FileInfo, err error) error {
+		isRoot := path == walkRoot
+		if isRoot {
+			path = root
+		} else if walkRoot != root {
+			path = filepath.Join(root, strings.TrimPrefix(path, walkRoot))
+		}
+		if err != nil {
+			fn(path, fi, err)
+			return nil
+		}
+		if fi.IsDir() && !isRoot {
+			if dev, ok := fileDevice(fi); ok {
+				if haveDev && dev != rootDev {
+					slog.Debug("skipped other file system", "dir", path)
+					return filepath.SkipDir
+				}
+				p, ok := pseudo[dev]
+				if !ok {
+					p = isPseudoFS(longPath(path))
+					pseudo[dev] = p
+				}
+				if p {
+					slog.Debug("skipped pseudo file system", "dir", path)
+					return filepath.SkipDir
+				}
+			}
+		}
+		if ignore != nil && !isRoot {
+			rel, _ := filepath.Rel(root, path)
+			if ignore.ignored(filepath.ToSlash(rel), fi.IsDir()) {
+				slog.Debug("ignored", "file", path)
+				if fi.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		fn(path, fi, nil)
+		return nil
+	})
+}
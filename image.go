@@ -0,0 +1,307 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// imageMain implements "gover image": it scans the file systems of
+// container images saved with "docker save" or as OCI archives.
+func imageMain(args []string) int {
+	fs := flag.NewFlagSet("image", flag.ExitOnError)
+	fs.Usage = usage
+	colorMode := addColorFlag(fs)
+	addLimitFlags(fs)
+	addArchiveJobsFlag(fs)
+	addPluginFlags(fs)
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	images := parseArgs(fs, args)
+	setup()
+	if len(images) < 1 {
+		usage()
+	}
+
+	r := &reporter{names: true}
+	r.color = useColor(*colorMode, r.stdout())
+	for _, img := range images {
+		if err := scanImage(img, r); err != nil {
+			r.report(img, "", err)
+		}
+	}
+	return r.exit
+}
+
+// imageFile is the scan result of a file in an image layer.
+type imageFile struct {
+	ver string
+	err error
+}
+
+// scanImage reports the Go binaries in the image archive named name. The
+// layers are applied in the order given by the image manifest, so only
+// the files present in the final file system are reported.
+func scanImage(name string, r *reporter) error {
+	f, err := os.Open(longPath(name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var magic [2]byte
+	f.ReadAt(magic[:], 0)
+	if bytes.Equal(magic[:], []byte{0x1f, 0x8b}) {
+		// Random access is needed to apply the layers in order.
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		tmp, err := ioutil.TempFile("", "gover")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+		if _, err := io.Copy(tmp, &limitedReader{r: zr, n: maxExtractedBytes, err: errExtractLimit}); err != nil {
+			return err
+		}
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		f = tmp
+	}
+
+	entries, err := tarEntries(f)
+	if err != nil {
+		return err
+	}
+	layers, err := imageLayers(entries)
+	if err != nil {
+		return err
+	}
+
+	// Layers are independent until they are applied, so they are
+	// decompressed and scanned concurrently, and applied in order after.
+	members := make([][]layerMember, len(layers))
+	errs := make([]error, len(layers))
+	sem := make(chan struct{}, max(archiveJobs, 1))
+	var wg sync.WaitGroup
+	for i, layer := range layers {
+		sr, ok := entries[layer]
+		if !ok {
+			return fmt.Errorf("missing layer %s", layer)
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, layer string, sr *io.SectionReader) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			members[i], errs[i] = scanLayer(name, layer, sr)
+		}(i, layer, sr)
+	}
+	wg.Wait()
+
+	files := make(map[string]imageFile)
+	for i := range layers {
+		if errs[i] != nil {
+			return errs[i]
+		}
+		// Whiteouts hide what the layers below have, never files of
+		// their own layer, wherever those come in the archive, so they
+		// are applied before the layer is.
+		for _, m := range members[i] {
+			dir, base := path.Split(m.path)
+			switch {
+			case base == ".wh..wh..opq":
+				for f := range files {
+					if strings.HasPrefix(f, dir) {
+						delete(files, f)
+					}
+				}
+			case strings.HasPrefix(base, ".wh."):
+				del := dir + base[len(".wh."):]
+				for f := range files {
+					if f == del || strings.HasPrefix(f, del+"/") {
+						delete(files, f)
+					}
+				}
+			}
+		}
+		for _, m := range members[i] {
+			switch {
+			case strings.HasPrefix(path.Base(m.path), ".wh."):
+			case isNoVersion(m.err):
+				delete(files, m.path)
+			default:
+				files[m.path] = m.imageFile
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		r.report(name+":"+p, files[p].ver, files[p].err)
+	}
+	return nil
+}
+
+// layerMember is the scan result of a file in an image layer, by its
+// absolute path. Whiteout files are included, to be applied in order.
+type layerMember struct {
+	path string
+	imageFile
+}
+
+// scanLayer returns the scan results of the files in the layer sr of the
+// image name, or nil if the layer is in a format that isn't supported.
+func scanLayer(name, layer string, sr *io.SectionReader) ([]layerMember, error) {
+	lr, err := layerReader(sr)
+	if err != nil {
+		return nil, err
+	}
+	if lr == nil {
+		slog.Warn("skipped layer in unsupported format", "image", name, "layer", layer)
+		return nil, nil
+	}
+	slog.Debug("scanning layer", "image", name, "layer", layer)
+	var members []layerMember
+	x := newExtraction(func(member, ver string, err error) {
+		if isNoVersion(err) {
+			// Only whether it is a Go binary matters; don't hold
+			// on to the error of every file in the layer.
+			err = errNotGoMember
+		}
+		members = append(members, layerMember{path.Clean("/" + member), imageFile{ver, err}})
+	})
+	// The compression of the layer is hidden by layerReader, so only
+	// what is extracted counts, and the layer is at the top level.
+	err = x.tar(x.decompressed(lr, nil), "", 0)
+	x.p.wait()
+	return members, err
+}
+
+var errNotGoMember = noVersionError{errors.New("not a Go binary")}
+
+// tarEntries returns the regular files in the tar archive f by name,
+// without a leading "./".
+func tarEntries(f *os.File) (map[string]*io.SectionReader, error) {
+	entries := make(map[string]*io.SectionReader)
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		// The tar reader doesn't read ahead, so the file is
+		// positioned at the start of the entry's contents.
+		off, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		entries[path.Clean(hdr.Name)] = io.NewSectionReader(f, off, hdr.Size)
+	}
+}
+
+// imageLayers returns the names of the layers of an image archive in the
+// order they are applied, from manifest.json as written by "docker save"
+// or else from the OCI index.json. For multi-platform images the first
+// image is used.
+func imageLayers(entries map[string]*io.SectionReader) ([]string, error) {
+	readJSON := func(name string, v interface{}) error {
+		sr, ok := entries[name]
+		if !ok {
+			return fmt.Errorf("missing %s", name)
+		}
+		return json.NewDecoder(io.NewSectionReader(sr, 0, sr.Size())).Decode(v)
+	}
+
+	if _, ok := entries["manifest.json"]; ok {
+		var manifest []struct {
+			Layers []string
+		}
+		if err := readJSON("manifest.json", &manifest); err != nil {
+			return nil, err
+		}
+		if len(manifest) == 0 {
+			return nil, fmt.Errorf("no images in manifest.json")
+		}
+		var layers []string
+		for _, l := range manifest[0].Layers {
+			layers = append(layers, path.Clean(l))
+		}
+		return layers, nil
+	}
+
+	type descriptor struct {
+		MediaType string `json:"mediaType"`
+		Digest    string `json:"digest"`
+	}
+	var doc struct {
+		MediaType string       `json:"mediaType"`
+		Manifests []descriptor `json:"manifests"`
+		Layers    []descriptor `json:"layers"`
+	}
+	blob := func(digest string) string {
+		return "blobs/" + strings.Replace(digest, ":", "/", 1)
+	}
+	name := "index.json"
+	// Follow the indexes down to an image manifest.
+	for depth := 0; depth < 8; depth++ {
+		doc.Manifests, doc.Layers = nil, nil
+		if err := readJSON(name, &doc); err != nil {
+			return nil, err
+		}
+		if len(doc.Manifests) == 0 {
+			var layers []string
+			for _, l := range doc.Layers {
+				layers = append(layers, blob(l.Digest))
+			}
+			return layers, nil
+		}
+		name = blob(doc.Manifests[0].Digest)
+	}
+	return nil, fmt.Errorf("image indexes nested too deeply")
+}
+
+// layerReader returns the contents of r as an uncompressed tar stream, or
+// nil if r is not a tar file.
+func layerReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReaderSize(r, 1024)
+	magic, _ := br.Peek(2)
+	var in io.Reader = br
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		in = zr
+	}
+	tbr := bufio.NewReaderSize(in, 1024)
+	hdr, err := tbr.Peek(512)
+	if err != nil || string(hdr[257:262]) != "ustar" {
+		return nil, nil
+	}
+	return tbr, nil
+}
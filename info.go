@@ -0,0 +1,409 @@
+package main
+
+import (
+	"debug/buildinfo"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// binaryInfo is the build information "gover info" reports about a
+// binary.
+type binaryInfo struct {
+	File       string           `json:"file"`
+	GoVersion  string           `json:"goVersion"`
+	Path       string           `json:"path,omitempty"`
+	BuildID    string           `json:"buildID,omitempty"`
+	GNUBuildID string           `json:"gnuBuildID,omitempty"`
+	OS         string           `json:"os,omitempty"`
+	Arch       string           `json:"arch,omitempty"`
+	Microarch  string           `json:"microarch,omitempty"`
+	Binary     *binaryMeta      `json:"binary,omitempty"`
+	GODEBUG    []godebugSetting `json:"godebug,omitempty"`
+
+	// DefaultsFor is the Go version declared in go.mod as inferred from
+	// the default GODEBUG settings, if any are defaults.
+	DefaultsFor string `json:"defaultsFor,omitempty"`
+
+	FIPS *fipsInfo `json:"fips,omitempty"`
+	PGO  *pgoInfo  `json:"pgo,omitempty"`
+	Link *linkInfo `json:"link,omitempty"`
+
+	LinkVars []linkVar `json:"linkVars,omitempty"`
+
+	DebugBuild *debugBuild `json:"debugBuild,omitempty"`
+	DWARF      *dwarfInfo  `json:"dwarf,omitempty"`
+
+	Capabilities []string `json:"capabilities,omitempty"`
+}
+
+// microarchSettings maps architectures to the build settings selecting
+// their micro-architecture level, such as GOAMD64=v3.
+var microarchSettings = map[string]string{
+	"386":      "GO386",
+	"amd64":    "GOAMD64",
+	"arm":      "GOARM",
+	"arm64":    "GOARM64",
+	"mips":     "GOMIPS",
+	"mipsle":   "GOMIPS",
+	"mips64":   "GOMIPS64",
+	"mips64le": "GOMIPS64",
+	"ppc64":    "GOPPC64",
+	"ppc64le":  "GOPPC64",
+	"riscv64":  "GORISCV64",
+	"wasm":     "GOWASM",
+}
+
+// fipsInfo describes the FIPS 140 mode of a binary. Mode is "fips140" for
+// the Go Cryptographic Module of Go 1.24 and later, or "boringcrypto" for
+// BoringCrypto builds. Module is the frozen module version selected with
+// GOFIPS140, or "" for the module in the toolchain, and Setting is the
+// default fips140 GODEBUG setting, "on" or "only".
+type fipsInfo struct {
+	Mode    string `json:"mode"`
+	Module  string `json:"module,omitempty"`
+	Setting string `json:"setting,omitempty"`
+}
+
+// pgoInfo describes the profile-guided optimization of a binary. Profile
+// is the path of the profile it was built with, as recorded in the -pgo
+// build setting, or "" if none was used; Default is set if that is the
+// default.pgo in the main package directory, picked by -pgo=auto.
+type pgoInfo struct {
+	Used    bool   `json:"used"`
+	Profile string `json:"profile,omitempty"`
+	Default bool   `json:"default,omitempty"`
+}
+
+// findPGOInfo returns the PGO description for the -pgo build setting
+// profile of a binary built with Go version ver, or nil if it can't be
+// told. Go 1.21 and later enable PGO by default and record the profile
+// whenever one is used, so no setting means none was; earlier releases
+// only record it if it was asked for.
+func findPGOInfo(ver, profile string) *pgoInfo {
+	if profile == "" {
+		if goMinor(ver) < 21 {
+			return nil
+		}
+		return &pgoInfo{}
+	}
+	base := profile[strings.LastIndexAny(profile, `/\`)+1:]
+	return &pgoInfo{Used: true, Profile: profile, Default: base == "default.pgo"}
+}
+
+// godebugSetting is a default GODEBUG setting compiled into a binary.
+// Source is "default" for settings the toolchain defaults to for the Go
+// version declared in go.mod, and "directive" for those set by godebug
+// lines in go.mod or //go:debug directives in the main package.
+type godebugSetting struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source,omitempty"`
+}
+
+// parseGODEBUG parses the DefaultGODEBUG build setting recorded by Go 1.21
+// and later, a comma-separated list of name=value pairs, sorted by name.
+func parseGODEBUG(s string) []godebugSetting {
+	var settings []godebugSetting
+	for _, kv := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || name == "" {
+			continue
+		}
+		settings = append(settings, godebugSetting{Name: name, Value: value})
+	}
+	sort.Slice(settings, func(i, j int) bool { return settings[i].Name < settings[j].Name })
+	return settings
+}
+
+// newBinaryInfo collects the build information of the binary b, named
+// file, from its build info bi and the binary itself.
+func newBinaryInfo(file string, b *binaryFile, bi *buildinfo.BuildInfo) *binaryInfo {
+	info := &binaryInfo{File: file, GoVersion: bi.GoVersion, Path: bi.Path}
+	fips := &fipsInfo{}
+	settings := make(map[string]string)
+	for _, s := range bi.Settings {
+		settings[s.Key] = s.Value
+		switch s.Key {
+		case "GOFIPS140":
+			if s.Value != "off" {
+				fips.Mode, fips.Module = "fips140", s.Value
+			}
+			if fips.Module == "latest" {
+				fips.Module = ""
+			}
+		case "GOEXPERIMENT":
+			for _, exp := range strings.Split(s.Value, ",") {
+				if exp == "boringcrypto" {
+					fips.Mode = "boringcrypto"
+				}
+			}
+		case "DefaultGODEBUG":
+			info.GODEBUG = parseGODEBUG(s.Value)
+			if lang := godebugProvenance(bi.GoVersion, info.GODEBUG); lang >= 0 {
+				info.DefaultsFor = fmt.Sprintf("go1.%d", lang)
+			}
+		}
+	}
+	info.OS, info.Arch = settings["GOOS"], settings["GOARCH"]
+	if info.Arch == "" {
+		// Go 1.17 and earlier don't record the build settings.
+		info.Arch = findArch(b)
+	}
+	if key, ok := microarchSettings[info.Arch]; ok {
+		info.Microarch = settings[key]
+	}
+	for _, s := range info.GODEBUG {
+		if s.Name == "fips140" && s.Value != "off" {
+			fips.Setting = s.Value
+			if fips.Mode == "" {
+				fips.Mode = "fips140"
+			}
+		}
+	}
+	// Before Go 1.19 BoringCrypto was a separate release branch with
+	// versions like go1.18.2b7.
+	if fips.Mode == "" && (strings.Contains(bi.GoVersion, "X:boringcrypto") || isBoringRelease(bi.GoVersion)) {
+		fips.Mode = "boringcrypto"
+	}
+	if fips.Mode != "" {
+		info.FIPS = fips
+	}
+	if len(bi.Settings) > 0 {
+		info.PGO = findPGOInfo(bi.GoVersion, settings["-pgo"])
+	}
+	info.Link = findLinkInfo(b, settings["-ldflags"])
+	info.LinkVars = findLinkVars(b)
+	info.DebugBuild = findDebugBuild(b, settings["-gcflags"])
+	info.DWARF = findDWARFInfo(b)
+	info.BuildID = findGoBuildID(b)
+	info.GNUBuildID = findGNUBuildID(b)
+	info.Binary = findBinaryMeta(b)
+	if tab, err := b.Pclntab(); err == nil {
+		info.Capabilities = findCapabilities(tab)
+	} else {
+		slog.Warn("reading pclntab failed", "file", file, "err", err)
+	}
+	return info
+}
+
+// print writes info as indented "key: value" lines.
+func (info *binaryInfo) print(w io.Writer) {
+	fmt.Fprintf(w, "%s:\n", info.File)
+	fmt.Fprintf(w, "  go: %s\n", info.GoVersion)
+	if info.Path != "" {
+		fmt.Fprintf(w, "  path: %s\n", info.Path)
+	}
+	if info.BuildID != "" {
+		fmt.Fprintf(w, "  build id: %s\n", info.BuildID)
+	}
+	if info.GNUBuildID != "" {
+		fmt.Fprintf(w, "  gnu build id: %s\n", info.GNUBuildID)
+	}
+	if info.Arch != "" {
+		arch := info.Arch
+		if info.OS != "" {
+			arch = info.OS + "/" + arch
+		}
+		if info.Microarch != "" {
+			arch += " (" + microarchSettings[info.Arch] + "=" + info.Microarch + ")"
+		}
+		fmt.Fprintf(w, "  arch: %s\n", arch)
+	}
+	if m := info.Binary; m != nil {
+		fmt.Fprintf(w, "  format: %s\n", m)
+		var secs []string
+		for name, ok := range m.Sections {
+			if ok {
+				secs = append(secs, name)
+			}
+		}
+		sort.Strings(secs)
+		fmt.Fprintf(w, "  sections: %s\n", strings.Join(secs, ", "))
+	}
+	if d := info.DWARF; d != nil {
+		vers := make([]string, len(d.Versions))
+		for i, v := range d.Versions {
+			vers[i] = strconv.Itoa(v)
+		}
+		fmt.Fprintf(w, "  dwarf: version %s\n", strings.Join(vers, ", "))
+		for _, p := range d.Producers {
+			units := "units"
+			if p.Units == 1 {
+				units = "unit"
+			}
+			fmt.Fprintf(w, "  producer: %s (%d %s)\n", p.Producer, p.Units, units)
+		}
+	}
+	for _, s := range info.GODEBUG {
+		src := "directive"
+		if s.Source == "default" {
+			src = "default for " + info.DefaultsFor
+		}
+		fmt.Fprintf(w, "  godebug: %s=%s (%s)\n", s.Name, s.Value, src)
+	}
+	if f := info.FIPS; f != nil {
+		fips := f.Mode
+		if f.Module != "" {
+			fips += " " + f.Module
+		}
+		if f.Setting != "" {
+			fips += " (fips140=" + f.Setting + ")"
+		}
+		fmt.Fprintf(w, "  fips: %s\n", fips)
+	}
+	if p := info.PGO; p != nil {
+		switch {
+		case p.Default:
+			fmt.Fprintf(w, "  pgo: %s (default)\n", p.Profile)
+		case p.Used:
+			fmt.Fprintf(w, "  pgo: %s\n", p.Profile)
+		default:
+			fmt.Fprintf(w, "  pgo: off\n")
+		}
+	}
+	if l := info.Link; l != nil {
+		if l.Linker != "" {
+			fmt.Fprintf(w, "  link: %s (%s)\n", l.Mode, l.Linker)
+		} else {
+			fmt.Fprintf(w, "  link: %s\n", l.Mode)
+		}
+		for _, c := range l.Comment {
+			fmt.Fprintf(w, "  comment: %s\n", c)
+		}
+	}
+	for _, v := range info.LinkVars {
+		switch {
+		case v.Current == nil:
+			fmt.Fprintf(w, "  -X: %s=%s\n", v.Name, v.Value)
+		case *v.Current != v.Value:
+			fmt.Fprintf(w, "  -X: %s=%s (binary has %q)\n", v.Name, v.Value, *v.Current)
+		default:
+			fmt.Fprintf(w, "  -X: %s=%s (resolved)\n", v.Name, v.Value)
+		}
+	}
+	if info.Capabilities != nil {
+		fmt.Fprintf(w, "  capabilities: %s\n", strings.Join(info.Capabilities, ", "))
+	}
+	if db := info.DebugBuild; db != nil {
+		if db.NoOptimization != nil {
+			fmt.Fprintf(w, "  unoptimized: %s\n", packageList(db.NoOptimization))
+		}
+		if db.NoInlining != nil {
+			fmt.Fprintf(w, "  not inlined: %s\n", packageList(db.NoInlining))
+		}
+	}
+}
+
+// packageList formats the package names pkgs, abbreviating long lists.
+// The main package is listed first.
+func packageList(pkgs []string) string {
+	const max = 5
+	for i, p := range pkgs {
+		if p == "main" {
+			pkgs = append([]string{"main"}, append(pkgs[:i:i], pkgs[i+1:]...)...)
+			break
+		}
+	}
+	if len(pkgs) <= max {
+		return strings.Join(pkgs, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(pkgs[:max], ", "), len(pkgs)-max)
+}
+
+// isBoringRelease reports whether ver is a release of the BoringCrypto
+// branch, which adds a "b" and a number to the Go version.
+func isBoringRelease(ver string) bool {
+	i := strings.LastIndex(ver, "b")
+	if i < 0 || i == len(ver)-1 || !strings.HasPrefix(ver, "go1.") {
+		return false
+	}
+	for _, c := range ver[i+1:] {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return goMinor(ver[:i]) >= 0
+}
+
+// safeBinaryInfo is newBinaryInfo, failing rather than panicking on
+// malformed binaries like scanBinary: the notes, DWARF info and pclntab
+// read for the details are not all checked by the debug packages.
+func safeBinaryInfo(file string, b *binaryFile, bi *buildinfo.BuildInfo) (info *binaryInfo, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			slog.Debug("panic while reading build details", "panic", p, "stack", string(debug.Stack()))
+			info, err = nil, fmt.Errorf("malformed binary: %v", p)
+		}
+	}()
+	return newBinaryInfo(file, b, bi), nil
+}
+
+// infoMain implements "gover info": it reports build details recorded in
+// binaries beyond the Go version.
+func infoMain(args []string) int {
+	fs := flag.NewFlagSet("info", flag.ExitOnError)
+	fs.Usage = usage
+	jsonOut := fs.Bool("json", false, "print the build details as JSON")
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	files := parseArgs(fs, args)
+	setup()
+	if len(files) < 1 {
+		usage()
+	}
+
+	exit := 0
+	infos := []interface{}{}
+	for i, file := range files {
+		b, err := openBinary(file)
+		if err != nil {
+			slog.Error("reading binary failed", "file", file, "err", err)
+			exit = worseExit(exit, readExit(err))
+			if *jsonOut {
+				infos = append(infos, newFileFailure(file, err))
+			}
+			continue
+		}
+		bi, err := b.BuildInfo()
+		if err != nil {
+			slog.Error("reading build info failed", "file", file, "err", err)
+			b.Close()
+			exit = worseExit(exit, readExit(err))
+			if *jsonOut {
+				infos = append(infos, newFileFailure(file, err))
+			}
+			continue
+		}
+		info, err := safeBinaryInfo(file, b, bi)
+		b.Close()
+		if err != nil {
+			slog.Error("reading build details failed", "file", file, "err", err)
+			exit = worseExit(exit, exitError)
+			if *jsonOut {
+				infos = append(infos, newFileFailure(file, err))
+			}
+			continue
+		}
+		if *jsonOut {
+			infos = append(infos, info)
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		info.print(os.Stdout)
+	}
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(infos)
+	}
+	return exit
+}
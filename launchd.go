@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// launchdDirs are the directories launchd loads job definitions from.
+var launchdDirs = []string{
+	"/Library/LaunchDaemons",
+	"/Library/LaunchAgents",
+	"/System/Library/LaunchDaemons",
+	"/System/Library/LaunchAgents",
+}
+
+// launchdMain implements "gover launchd": it reports the Go version of the
+// programs run by launchd jobs.
+func launchdMain(args []string) int {
+	fs := flag.NewFlagSet("launchd", flag.ExitOnError)
+	fs.Usage = usage
+	colorMode := addColorFlag(fs)
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	dirs := parseArgs(fs, args)
+	setup()
+	if len(dirs) == 0 {
+		dirs = launchdDirs
+		if home := os.Getenv("HOME"); home != "" {
+			dirs = append(dirs, filepath.Join(home, "Library", "LaunchAgents"))
+		}
+	}
+
+	r := &reporter{names: true, eol: true}
+	r.color = useColor(*colorMode, r.stdout())
+	for _, dir := range dirs {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.plist"))
+		sort.Strings(files)
+		for _, f := range files {
+			label, prog, err := parseLaunchdPlist(f)
+			if err != nil {
+				r.report(f, "", err)
+				continue
+			}
+			if prog == "" {
+				continue
+			}
+			if label == "" {
+				label = strings.TrimSuffix(filepath.Base(f), ".plist")
+			}
+			reportService(r, label, prog)
+		}
+	}
+	return r.exit
+}
+
+// readPlist returns the property list file in XML. Binary property lists
+// are converted with plutil(1).
+func readPlist(file string) ([]byte, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(data, []byte("bplist")) {
+		data, err = exec.Command("plutil", "-convert", "xml1", "-o", "-", file).Output()
+		if err != nil {
+			return nil, fmt.Errorf("binary property list: plutil: %s", err)
+		}
+	}
+	return data, nil
+}
+
+// parseLaunchdPlist returns the label and program of a launchd job
+// definition.
+func parseLaunchdPlist(file string) (label, program string, err error) {
+	data, err := readPlist(file)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Only the top-level dictionary is of interest: remember the last
+	// key seen at depth 2 (plist > dict) and pick up its value.
+	d := xml.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	key := ""
+	var args []string
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", "", err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			switch {
+			case depth == 3 && t.Name.Local == "key":
+				var k string
+				if err := d.DecodeElement(&k, &t); err != nil {
+					return "", "", err
+				}
+				key = k
+				depth--
+			case depth == 3 && t.Name.Local == "string":
+				var v string
+				if err := d.DecodeElement(&v, &t); err != nil {
+					return "", "", err
+				}
+				switch key {
+				case "Label":
+					label = v
+				case "Program":
+					program = v
+				}
+				depth--
+			case depth == 4 && key == "ProgramArguments" && t.Name.Local == "string":
+				var v string
+				if err := d.DecodeElement(&v, &t); err != nil {
+					return "", "", err
+				}
+				args = append(args, v)
+				depth--
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	if program == "" && len(args) > 0 {
+		program = args[0]
+	}
+	return label, program, nil
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// linkInfo describes how a binary was linked. Mode is "internal" if the
+// Go linker wrote the binary itself and "external" if it used the host
+// linker, whose identification is Linker, if known. Comment holds the
+// identification strings compilers and linkers left in ELF binaries.
+type linkInfo struct {
+	Mode    string   `json:"mode"`
+	Linker  string   `json:"linker,omitempty"`
+	Comment []string `json:"comment,omitempty"`
+}
+
+// findLinkInfo determines how the binary was linked. ldflags are the
+// linker flags recorded in its build info, which take precedence.
+func findLinkInfo(bf *binaryFile, ldflags string) *linkInfo {
+	var li *linkInfo
+	switch b := bf.Binary.(type) {
+	case *elfBinary:
+		li = elfLinkInfo(b.File)
+	case *peBinary:
+		li = peLinkInfo(b.File, bf.r)
+	case *machoBinary:
+		li = machoLinkInfo(b.File)
+	default:
+		return nil
+	}
+	for _, flag := range strings.Fields(ldflags) {
+		switch strings.TrimLeft(flag, "-") {
+		case "linkmode=internal":
+			li.Mode = "internal"
+		case "linkmode=external":
+			li.Mode = "external"
+		}
+	}
+	return li
+}
+
+// elfLinkInfo tells internally from externally linked ELF binaries by
+// their entry point, which is in the Go runtime only for the former, and
+// otherwise by the .comment section the Go linker doesn't write. Of the
+// common linkers, LLD and mold identify themselves in .comment and gold
+// in a note; GNU ld leaves no trace and is assumed otherwise.
+func elfLinkInfo(f *elf.File) *linkInfo {
+	li := &linkInfo{Mode: "internal"}
+	if s := f.Section(".comment"); s != nil {
+		li.Mode = "external"
+		data, _ := s.Data()
+		for _, c := range bytes.Split(data, []byte{0}) {
+			if c := strings.TrimSpace(string(c)); c != "" {
+				li.Comment = append(li.Comment, c)
+			}
+		}
+	}
+	if syms, err := f.Symbols(); err == nil {
+		for _, s := range syms {
+			if s.Value == f.Entry && elf.ST_TYPE(s.Info) == elf.STT_FUNC {
+				if strings.HasPrefix(s.Name, "_rt0_") {
+					li.Mode = "internal"
+				} else {
+					li.Mode = "external"
+				}
+				break
+			}
+		}
+	}
+	if li.Mode == "internal" {
+		return li
+	}
+
+	for _, c := range li.Comment {
+		switch {
+		case strings.HasPrefix(c, "Linker: "):
+			li.Linker = strings.TrimPrefix(c, "Linker: ")
+		case strings.HasPrefix(c, "mold "):
+			li.Linker = c
+		}
+	}
+	if li.Linker == "" {
+		if s := f.Section(".note.gnu.gold-version"); s != nil {
+			li.Linker = "gold"
+			data, _ := s.Data()
+			// The note's description is the version string.
+			if i := bytes.Index(data, []byte("gold ")); i >= 0 {
+				li.Linker = string(bytes.TrimRight(data[i:], "\x00"))
+			}
+		} else {
+			li.Linker = "GNU ld"
+		}
+	}
+	return li
+}
+
+// peLinkInfo identifies the linker of a PE binary r by the linker version
+// in the optional header, which the Go linker sets to 3.0, and the Rich
+// header Microsoft's linker puts after the DOS stub.
+func peLinkInfo(f *pe.File, r io.ReaderAt) *linkInfo {
+	var major, minor uint8
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		major, minor = oh.MajorLinkerVersion, oh.MinorLinkerVersion
+	case *pe.OptionalHeader64:
+		major, minor = oh.MajorLinkerVersion, oh.MinorLinkerVersion
+	}
+	li := &linkInfo{Mode: "external"}
+	switch {
+	case hasRichHeader(r):
+		li.Linker = fmt.Sprintf("Microsoft link %d.%d", major, minor)
+	case major == 3 && minor == 0:
+		li.Mode = "internal"
+	case major == 2:
+		li.Linker = fmt.Sprintf("GNU ld 2.%d", minor)
+	default:
+		li.Linker = fmt.Sprintf("linker version %d.%d", major, minor)
+	}
+	return li
+}
+
+// hasRichHeader reports whether the PE file r has a Rich header between
+// the DOS stub and the PE signature.
+func hasRichHeader(r io.ReaderAt) bool {
+	var off [4]byte
+	if _, err := r.ReadAt(off[:], 0x3c); err != nil {
+		return false
+	}
+	n := binary.LittleEndian.Uint32(off[:])
+	if n < 0x80 || n > 4096 {
+		return false
+	}
+	stub := make([]byte, n)
+	if _, err := r.ReadAt(stub, 0); err != nil {
+		return false
+	}
+	return bytes.Contains(stub[0x80:], []byte("Rich"))
+}
+
+const (
+	machoBuildVersion = 0x32 // LC_BUILD_VERSION
+	machoToolLD       = 3    // TOOL_LD
+)
+
+// machoLinkInfo identifies the linker of a Mach-O binary by the tools
+// listed in its LC_BUILD_VERSION command, which include ld64 for
+// externally linked binaries.
+func machoLinkInfo(f *macho.File) *linkInfo {
+	li := &linkInfo{Mode: "internal"}
+	for _, l := range f.Loads {
+		raw := l.Raw()
+		if len(raw) < 24 || f.ByteOrder.Uint32(raw) != machoBuildVersion {
+			continue
+		}
+		ntools := f.ByteOrder.Uint32(raw[20:])
+		tools := raw[24:]
+		for i := uint32(0); i < ntools && len(tools) >= 8; i++ {
+			if f.ByteOrder.Uint32(tools) == machoToolLD {
+				v := f.ByteOrder.Uint32(tools[4:])
+				li.Mode = "external"
+				li.Linker = fmt.Sprintf("ld64 %d.%d.%d", v>>16, v>>8&0xff, v&0xff)
+			}
+			tools = tools[8:]
+		}
+	}
+	return li
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"debug/elf"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// libraryDirs are searched for shared libraries after the run paths of a
+// binary and $LD_LIBRARY_PATH, like the dynamic linker does.
+var libraryDirs = []string{"/lib", "/usr/lib", "/lib64", "/usr/lib64", "/usr/local/lib"}
+
+// spilledFiles are the temporary files, copies of archive members,
+// uploads or the standard input, that are scanned in place of files that
+// are not on this host, so the libraries they need are not looked for on
+// it either.
+var spilledFiles sync.Map
+
+// markSpilled records that the temporary file name is a copy of a file
+// from elsewhere, until the returned function is called.
+func markSpilled(name string) (unmark func()) {
+	spilledFiles.Store(name, true)
+	return func() { spilledFiles.Delete(name) }
+}
+
+func isSpilled(name string) bool {
+	_, ok := spilledFiles.Load(name)
+	return ok
+}
+
+// sameFile reports whether the paths a and b are the same file.
+func sameFile(a, b string) bool {
+	ai, err := os.Stat(longPath(a))
+	if err != nil {
+		return false
+	}
+	bi, err := os.Stat(longPath(b))
+	return err == nil && os.SameFile(ai, bi)
+}
+
+// scanSharedLib returns the Go version of the Go shared library path of a
+// shared-linked binary. The libraries it needs in turn are not followed:
+// the runtime is in the one the binary needs, and a library that needs
+// itself or another one could otherwise be followed without end.
+func scanSharedLib(path string, t *scanTiming) (string, error) {
+	f, err := os.Open(longPath(path))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return scanBinary("", f, t)
+}
+
+// sharedLinked reports whether the ELF binary f was linked with
+// -linkshared: the runtime is not part of it, so it imports runtime symbols
+// from a Go shared library.
+func sharedLinked(f *elf.File) bool {
+	syms, err := f.ImportedSymbols()
+	if err != nil {
+		return false
+	}
+	for _, s := range syms {
+		if strings.HasPrefix(s.Name, "runtime.") {
+			return true
+		}
+	}
+	return false
+}
+
+// goSharedLib returns the Go shared library, like libstd.so, that the
+// binary file, parsed as f, was linked against with -linkshared. lib is the
+// name of the library as in DT_NEEDED and path where it was found, or ""
+// if it wasn't. ok is false if the binary is not shared-linked. Libraries
+// are only looked for on this host for files on it, not for spilled ones.
+func goSharedLib(file string, f *elf.File) (lib, path string, ok bool) {
+	defer func() {
+		// Malformed dynamic sections can make debug/elf panic.
+		if recover() != nil {
+			lib, path, ok = "", "", false
+		}
+	}()
+	if !sharedLinked(f) {
+		return "", "", false
+	}
+	needed, err := f.DynString(elf.DT_NEEDED)
+	if err != nil || len(needed) == 0 {
+		return "", "", false
+	}
+	if !isSpilled(file) {
+		dirs := libraryPath(f, file)
+		for _, name := range needed {
+			p := findLibrary(name, dirs)
+			if p != "" && isGoRuntimeLib(p) {
+				return name, p, true
+			}
+		}
+	}
+	// None was found, so report the library most likely to hold the
+	// runtime: libstd.so, the standard library, unless the binary names
+	// a library of its own.
+	for _, name := range needed {
+		if name == "libstd.so" {
+			return name, "", true
+		}
+	}
+	return needed[0], "", true
+}
+
+// libraryPath returns the directories the dynamic linker searches for the
+// libraries of f, read from file: DT_RPATH unless there is a DT_RUNPATH,
+// $LD_LIBRARY_PATH, DT_RUNPATH and the default directories, with $ORIGIN
+// in the run paths expanded to the directory of file.
+func libraryPath(f *elf.File, file string) []string {
+	origin := filepath.Dir(file)
+	if abs, err := filepath.Abs(origin); err == nil {
+		origin = abs
+	}
+	split := func(list []string) []string {
+		var dirs []string
+		for _, l := range list {
+			for _, d := range filepath.SplitList(l) {
+				d = strings.ReplaceAll(d, "${ORIGIN}", origin)
+				d = strings.ReplaceAll(d, "$ORIGIN", origin)
+				if d != "" {
+					dirs = append(dirs, d)
+				}
+			}
+		}
+		return dirs
+	}
+	runpath, _ := f.DynString(elf.DT_RUNPATH)
+	var dirs []string
+	if len(runpath) == 0 {
+		rpath, _ := f.DynString(elf.DT_RPATH)
+		dirs = split(rpath)
+	}
+	dirs = append(dirs, split([]string{os.Getenv("LD_LIBRARY_PATH")})...)
+	dirs = append(dirs, split(runpath)...)
+	return append(dirs, libraryDirs...)
+}
+
+// findLibrary returns the path of the library name in the first of dirs
+// that has it, or "". Names with a slash are paths already.
+func findLibrary(name string, dirs []string) string {
+	if strings.Contains(name, "/") {
+		dirs = []string{""}
+	}
+	for _, d := range dirs {
+		p := filepath.Join(d, name)
+		if fi, err := os.Stat(longPath(p)); err == nil && fi.Mode().IsRegular() {
+			return p
+		}
+	}
+	return ""
+}
+
+// isGoRuntimeLib reports whether the file name is a Go shared library
+// holding the runtime package, according to the list of packages the Go
+// linker records in it.
+func isGoRuntimeLib(name string) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	f, err := elf.Open(longPath(name))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	s := f.Section(".note.go.pkg-list")
+	if s == nil {
+		return false
+	}
+	data, err := s.Data()
+	if err != nil {
+		return false
+	}
+	// The note is a header, the owner "Go" padded to four bytes and
+	// the package paths, one per line.
+	for _, p := range strings.Split(string(data), "\n") {
+		if p == "runtime" || strings.HasSuffix(p, "\x00runtime") {
+			return true
+		}
+	}
+	return false
+}
+
+// sharedLibOf returns the Go shared library the binary file was linked
+// against, as for the sharedLib field of scan results: the path it was
+// found at, or its name if it wasn't. It returns "" for binaries that are
+// not shared-linked, or if b, the opened file, is nil.
+func sharedLibOf(b *binaryFile, file string) string {
+	if b == nil {
+		return ""
+	}
+	ef, ok := b.Binary.(*elfBinary)
+	if !ok {
+		return ""
+	}
+	lib, path, ok := goSharedLib(file, ef.File)
+	if !ok {
+		return ""
+	}
+	if path == "" {
+		return lib
+	}
+	return path
+}
@@ -0,0 +1,134 @@
+package main
+
+import "strings"
+
+// linkVar is a string variable set at link time with -ldflags -X, as in
+// -X main.version=1.2.3. Value is what the build info records it was set
+// to and Current what the binary holds, if it could be read.
+type linkVar struct {
+	Name    string  `json:"name"`
+	Value   string  `json:"value"`
+	Current *string `json:"current,omitempty"`
+}
+
+// splitQuoted splits the flags s the way cmd/go records them in the build
+// settings: separated by spaces, with fields containing spaces enclosed
+// in single or double quotes.
+func splitQuoted(s string) []string {
+	var fields []string
+	for {
+		s = strings.TrimLeft(s, " \t\n\r")
+		if s == "" {
+			return fields
+		}
+		if q := s[0]; q == '\'' || q == '"' {
+			if i := strings.IndexByte(s[1:], q); i >= 0 {
+				fields = append(fields, s[1:1+i])
+				s = s[2+i:]
+				continue
+			}
+		}
+		i := strings.IndexAny(s, " \t\n\r")
+		if i < 0 {
+			i = len(s)
+		}
+		fields = append(fields, s[:i])
+		s = s[i:]
+	}
+}
+
+// parseLinkVars returns the -X assignments in the linker flags ldflags,
+// from the -ldflags build setting. Like the linker, it accepts -X and --X
+// with the assignment as the next flag or after an equals sign.
+func parseLinkVars(ldflags string) []linkVar {
+	var vars []linkVar
+	flags := splitQuoted(ldflags)
+	for i := 0; i < len(flags); i++ {
+		f := flags[i]
+		if strings.HasPrefix(f, "--") {
+			f = f[1:]
+		}
+		var assign string
+		switch {
+		case f == "-X" && i+1 < len(flags):
+			i++
+			assign = flags[i]
+		case strings.HasPrefix(f, "-X="):
+			assign = f[len("-X="):]
+		default:
+			continue
+		}
+		name, value, ok := strings.Cut(assign, "=")
+		if !ok || name == "" {
+			continue
+		}
+		vars = append(vars, linkVar{Name: name, Value: value})
+	}
+	return vars
+}
+
+// findLinkVars returns the -X assignments recorded in the build info of b
+// with the values the variables have in b, read at their symbols, or as
+// described by the DWARF info if there are none. It returns nil if b is
+// nil or has no build info.
+func findLinkVars(b *binaryFile) []linkVar {
+	if b == nil {
+		return nil
+	}
+	bi, err := b.BuildInfo()
+	if err != nil {
+		return nil
+	}
+	var vars []linkVar
+	for _, s := range bi.Settings {
+		if s.Key == "-ldflags" {
+			vars = parseLinkVars(s.Value)
+		}
+	}
+	for i := range vars {
+		if cur, ok := readLinkVar(b, vars[i].Name); ok {
+			vars[i].Current = &cur
+		}
+	}
+	return vars
+}
+
+// readLinkVar reads the string variable name from b.
+func readLinkVar(b *binaryFile, name string) (val string, ok bool) {
+	defer func() {
+		if recover() != nil {
+			val, ok = "", false
+		}
+	}()
+	if addr, ok, err := symbolAddr(b, name); err == nil && ok {
+		if s, err := readStringAt(b, addr); err == nil {
+			return s, true
+		}
+	}
+	d, err := b.DWARF()
+	if err != nil {
+		return "", false
+	}
+	v, err := findVariable(b, d, name)
+	if v == nil || err != nil {
+		return "", false
+	}
+	s, err := readString(b, v)
+	return s, err == nil
+}
+
+// linkVarValues returns the variables vars as reported in the vars of scan
+// results: by name, with their current value or else the recorded one.
+func linkVarValues(vars []linkVar) map[string]interface{} {
+	if len(vars) == 0 {
+		return nil
+	}
+	m := make(map[string]interface{}, len(vars))
+	for _, v := range vars {
+		m[v.Name] = v.Value
+		if v.Current != nil {
+			m[v.Name] = *v.Current
+		}
+	}
+	return m
+}
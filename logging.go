@@ -0,0 +1,33 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// addLogFlags registers the -log-format and -log-level flags on fs. The
+// returned function installs the configured default logger and has to be
+// called once the flags are parsed. level is the default minimum level:
+// the CLI only logs warnings and errors, while long-running modes also
+// log informational messages.
+func addLogFlags(fs *flag.FlagSet, level slog.Level) func() {
+	format := fs.String("log-format", "text", "log format: text or json")
+	lvl := fs.String("log-level", strings.ToLower(level.String()), "minimum log level: debug, info, warn or error")
+	return func() {
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(*lvl)); err != nil {
+			fmt.Fprintf(os.Stderr, "gover: invalid -log-level %q\n", *lvl)
+			usage()
+		}
+		opts := &slog.HandlerOptions{Level: l}
+		checkFlagValue("log-format", *format)
+		var h slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+		if *format == "json" {
+			h = slog.NewJSONHandler(os.Stderr, opts)
+		}
+		slog.SetDefault(slog.New(h))
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+// longPath returns path unchanged; only Windows limits the length of
+// paths passed to its file APIs.
+func longPath(path string) string {
+	return path
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// longPath returns path in the extended-length form, \\?\C:\dir\file or
+// \\?\UNC\server\share\dir\file for UNC paths, which the Windows file APIs
+// accept beyond MAX_PATH, so that deep trees can be scanned. Such paths
+// are not normalized by Windows, so path is made absolute and cleaned
+// first. Device paths and paths already in that form are returned as is.
+func longPath(path string) string {
+	if strings.HasPrefix(path, `\\?\`) || strings.HasPrefix(path, `\\.\`) {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
@@ -2,66 +2,269 @@ package main
 
 import (
 	"bytes"
+	"debug/buildinfo"
 	"debug/dwarf"
 	"debug/elf"
+	"debug/gosym"
 	"debug/macho"
 	"debug/pe"
 	"encoding/binary"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
 	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
 )
 
+var errUnsupportedFormat = errors.New("unsupported binary format")
+
+// Exit codes, the same for all subcommands, so that scripts can tell the
+// outcomes apart without parsing the log.
+const (
+	exitOK        = 0 // everything was scanned and is fine
+	exitError     = 1 // some file or input couldn't be read or scanned
+	exitNotGo     = 2 // some file given is not a Go binary
+	exitViolation = 3 // some result violates a policy, like -max-age
+	exitUsage     = 4 // invalid arguments or configuration
+)
+
+// exitSeverity orders the exit codes for runs with several outcomes. Scan
+// errors come first, as the results are then incomplete, followed by
+// policy violations and files that aren't Go binaries.
+var exitSeverity = map[int]int{exitOK: 0, exitNotGo: 1, exitViolation: 2, exitError: 3, exitUsage: 4}
+
+// worseExit returns the more severe of the exit codes a and b.
+func worseExit(a, b int) int {
+	if exitSeverity[b] > exitSeverity[a] {
+		return b
+	}
+	return a
+}
+
+// readExit returns the exit code for err from reading a binary: exitError
+// if the file couldn't be read and exitNotGo if it was read but lacks what
+// makes it a Go binary, like build info or a pclntab.
+func readExit(err error) int {
+	var pe *fs.PathError
+	if errors.As(err, &pe) || isTruncated(err) {
+		return exitError
+	}
+	return exitNotGo
+}
+
+// noVersionError marks failures which mean that a file simply carries no
+// detectable Go version, as opposed to errors reading it.
+type noVersionError struct {
+	err error
+}
+
+func (e noVersionError) Error() string {
+	return e.err.Error()
+}
+
+func (e noVersionError) Unwrap() error {
+	return e.err
+}
+
+func isNoVersion(err error) bool {
+	_, ok := err.(noVersionError)
+	return ok
+}
+
 type Binary interface {
 	DWARF() (*dwarf.Data, error)
+	// DWARFSection returns the decompressed contents of a DWARF section
+	// given without its .debug_ prefix, or nil if there is none.
+	DWARFSection(name string) []byte
 	Close() error
 
 	ReadAtVaddr(b []byte, vaddr uint64) (int, error)
+	// PtrSize returns the size of a pointer in bytes, or 0 if it is not
+	// known.
 	PtrSize() uint
+	// Arch returns the target architecture, using GOARCH names where
+	// there is one.
+	Arch() string
 }
 
-func openBinary(name string) (Binary, error) {
-	f, err := os.Open(name)
+// openBinary opens the binary file name for reading all the information
+// gover extracts from it.
+func openBinary(name string) (*binaryFile, error) {
+	f, err := os.Open(longPath(name))
 	if err != nil {
 		return nil, err
 	}
+	b, err := newBinaryFile(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	b.name, b.c = name, f
+	return b, nil
+}
+
+// newBinaryFile parses the binary read from r, like openBinary does for
+// files. Closing it does not close r.
+func newBinaryFile(r io.ReaderAt) (*binaryFile, error) {
+	b, err := newBinary(r)
+	if err != nil {
+		return nil, err
+	}
+	return &binaryFile{Binary: b, r: r}, nil
+}
+
+// readBuildInfo returns the build info of the binary file name, like
+// buildinfo.ReadFile, but reading universal binaries as well.
+func readBuildInfo(name string) (*buildinfo.BuildInfo, error) {
+	b, err := openBinary(name)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+	return b.BuildInfo()
+}
+
+// binaryFile is a Binary opened by openBinary or newBinaryFile; r is what
+// everything about it is read from, and name and c, if set, are the file
+// it was opened as and what is closed with it.
+// The debug packages don't close readers they were not opened with, so it
+// does that itself.
+//
+// The DWARF info, build info and pclntab are parsed once, when first
+// needed, and shared by everything read from the file, so that reporting
+// the architecture, dependencies and variables of one binary doesn't
+// parse it over and over. A binaryFile is not safe for concurrent use.
+type binaryFile struct {
+	Binary
+	name string
+	r    io.ReaderAt
+	c    io.Closer
+
+	dwarf    *dwarf.Data
+	dwarfErr error
+	bi       *buildinfo.BuildInfo
+	biErr    error
+	tab      *gosym.Table
+	tabErr   error
+	parsed   struct{ dwarf, bi, tab bool }
+}
+
+func (b *binaryFile) Close() error {
+	b.Binary.Close()
+	if b.c == nil {
+		return nil
+	}
+	return b.c.Close()
+}
+
+func (b *binaryFile) DWARF() (*dwarf.Data, error) {
+	if !b.parsed.dwarf {
+		b.parsed.dwarf = true
+		b.dwarf, b.dwarfErr = b.Binary.DWARF()
+	}
+	return b.dwarf, b.dwarfErr
+}
+
+// BuildInfo returns the build info of the binary.
+func (b *binaryFile) BuildInfo() (*buildinfo.BuildInfo, error) {
+	if !b.parsed.bi {
+		b.parsed.bi = true
+		b.bi, b.biErr = buildinfo.Read(sliceReader(b.Binary, b.r))
+	}
+	return b.bi, b.biErr
+}
+
+// Pclntab returns the pclntab of the binary, as read by readPclntab.
+func (b *binaryFile) Pclntab() (*gosym.Table, error) {
+	if !b.parsed.tab {
+		b.parsed.tab = true
+		b.tab, b.tabErr = readPclntab(b)
+	}
+	return b.tab, b.tabErr
+}
+
+// newBinary parses the executable read from r. Closing the returned
+// Binary does not close r.
+func newBinary(r io.ReaderAt) (Binary, error) {
+	size := readerSize(r)
 	magic := make([]byte, 4)
-	if _, err := f.ReadAt(magic[:], 0); err != nil {
+	if n, err := r.ReadAt(magic[:], 0); err != nil {
+		if err == io.EOF {
+			if err := checkEmpty(r, size, magic[:n]); err != nil {
+				return nil, err
+			}
+			return nil, errUnsupportedFormat
+		}
+		return nil, err
+	}
+	if err := checkEmpty(r, size, magic); err != nil {
 		return nil, err
 	}
 
 	if bytes.HasPrefix(magic, []byte{0x7f, 'E', 'L', 'F'}) {
-		e, err := elf.NewFile(f)
+		e, err := elf.NewFile(r)
 		if err != nil {
-			return nil, err
+			return nil, headerTruncated(err, size)
 		}
-		return &elfBinary{File: e}, nil
+		return &elfBinary{File: e, mapped: elfAddrMap(e, r)}, nil
 	} else if bytes.HasPrefix(magic, []byte{'M', 'Z'}) {
-		p, err := pe.Open(name)
+		p, err := pe.NewFile(r)
 		if err != nil {
-			return nil, err
+			return nil, headerTruncated(err, size)
 		}
-		return &peBinary{File: p}, nil
+		if p.OptionalHeader == nil {
+			// An object file rather than an executable.
+			return nil, errUnsupportedFormat
+		}
+		pb := &peBinary{File: p}
+		pb.mapped = peAddrMap(p, pb.imageBase(), r)
+		return pb, nil
 	} else if bytes.HasPrefix(magic, []byte{0xcf, 0xfa, 0xed, 0xfe}) {
-		m, err := macho.Open(name)
+		m, err := macho.NewFile(r)
 		if err != nil {
-			return nil, err
+			return nil, headerTruncated(err, size)
+		}
+		return &machoBinary{File: m, mapped: machoAddrMap(m, r)}, nil
+	} else if bytes.HasPrefix(magic, []byte{0xca, 0xfe, 0xba, 0xbe}) {
+		ff, err := macho.NewFatFile(r)
+		if err != nil {
+			// Java class files have the same magic number.
+			return nil, errUnsupportedFormat
 		}
-		return &machoBinary{File: m}, nil
+		return newFatSlice(ff, r), nil
 	}
-	return nil, fmt.Errorf("unsupported binary format")
+	return nil, errUnsupportedFormat
 }
 
 type elfBinary struct {
 	*elf.File
+	mapped *addrMap
 }
 
 func (e *elfBinary) ReadAtVaddr(b []byte, vaddr uint64) (int, error) {
-	for _, s := range e.Sections {
-		if vaddr >= s.Addr && vaddr < s.Addr+s.Size {
-			return s.ReadAt(b, int64(vaddr-s.Addr))
+	return e.mapped.readAt(b, vaddr)
+}
+
+func (e *elfBinary) DWARFSection(name string) []byte {
+	if s := e.Section(".debug_" + name); s != nil {
+		// Open takes care of SHF_COMPRESSED sections.
+		b, err := io.ReadAll(s.Open())
+		if err != nil {
+			return nil
 		}
+		return b
+	}
+	if s := e.Section(".zdebug_" + name); s != nil {
+		b, _ := s.Data()
+		return zdebugData(b)
 	}
-	return 0, fmt.Errorf("addr not mapped")
+	return nil
 }
 
 func (e *elfBinary) PtrSize() uint {
@@ -70,36 +273,87 @@ func (e *elfBinary) PtrSize() uint {
 		return 4
 	case elf.ELFCLASS64:
 		return 8
-	default:
-		panic("unknown elf class")
 	}
+	return 0
+}
+
+func (e *elfBinary) Arch() string {
+	switch e.Machine {
+	case elf.EM_386:
+		return "386"
+	case elf.EM_X86_64:
+		return "amd64"
+	case elf.EM_ARM:
+		return "arm"
+	case elf.EM_AARCH64:
+		return "arm64"
+	case elf.EM_PPC64:
+		if e.ByteOrder == binary.LittleEndian {
+			return "ppc64le"
+		}
+		return "ppc64"
+	case elf.EM_S390:
+		return "s390x"
+	case elf.EM_MIPS:
+		arch := "mips"
+		if e.Class == elf.ELFCLASS64 {
+			arch = "mips64"
+		}
+		if e.ByteOrder == binary.LittleEndian {
+			arch += "le"
+		}
+		return arch
+	case elf.EM_RISCV:
+		return "riscv64"
+	case elf.EM_LOONGARCH:
+		return "loong64"
+	}
+	return strings.ToLower(strings.TrimPrefix(e.Machine.String(), "EM_"))
 }
 
 type peBinary struct {
 	*pe.File
+	mapped *addrMap
 }
 
 func (p *peBinary) ReadAtVaddr(b []byte, vaddr uint64) (int, error) {
-	base := p.imageBase()
-	for _, s := range p.Sections {
-		start := base + uint64(s.VirtualAddress)
-		end := start + uint64(s.Size)
-		if vaddr >= start && vaddr < end {
-			return s.ReadAt(b, int64(vaddr-(base+uint64(s.VirtualAddress))))
-		}
+	return p.mapped.readAt(b, vaddr)
+}
+
+func (p *peBinary) DWARFSection(name string) []byte {
+	if s := p.Section(".debug_" + name); s != nil {
+		b, _ := s.Data()
+		return b
 	}
-	return 0, fmt.Errorf("addr not mapped")
+	if s := p.Section(".zdebug_" + name); s != nil {
+		b, _ := s.Data()
+		return zdebugData(b)
+	}
+	return nil
 }
 
 func (p *peBinary) PtrSize() uint {
-	// FIXME?
 	switch p.OptionalHeader.(type) {
 	case *pe.OptionalHeader32:
 		return 4
 	case *pe.OptionalHeader64:
 		return 8
 	}
-	panic("unknown pe format")
+	return 0
+}
+
+func (p *peBinary) Arch() string {
+	switch p.Machine {
+	case pe.IMAGE_FILE_MACHINE_I386:
+		return "386"
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		return "amd64"
+	case pe.IMAGE_FILE_MACHINE_ARMNT:
+		return "arm"
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		return "arm64"
+	}
+	return fmt.Sprintf("pe machine %#x", p.Machine)
 }
 
 func (p *peBinary) imageBase() uint64 {
@@ -109,44 +363,164 @@ func (p *peBinary) imageBase() uint64 {
 	case *pe.OptionalHeader64:
 		return oh.ImageBase
 	}
-	panic("unknown pe format")
+	return 0
 }
 
 type machoBinary struct {
 	*macho.File
+	mapped *addrMap
+	// fat is the universal binary m is the slice for the running
+	// architecture of, if any, and r the reader of that slice.
+	fat *macho.FatFile
+	r   io.ReaderAt
 }
 
-func (m *machoBinary) ReadAtVaddr(b []byte, vaddr uint64) (int, error) {
-	for _, s := range m.Sections {
-		if vaddr >= s.Addr && vaddr < s.Addr+s.Size {
-			return s.ReadAt(b, int64(vaddr-s.Addr))
+// newFatSlice returns the slice of the universal binary ff, read from r,
+// for the running architecture, or the first if there is none. Everything
+// but the version is reported for that slice.
+func newFatSlice(ff *macho.FatFile, r io.ReaderAt) *machoBinary {
+	a := ff.Arches[0]
+	for _, fa := range ff.Arches {
+		if (&machoBinary{File: fa.File}).Arch() == runtime.GOARCH {
+			a = fa
+			break
 		}
 	}
-	return 0, fmt.Errorf("addr not mapped")
+	sr := io.NewSectionReader(r, int64(a.Offset), int64(a.Size))
+	return &machoBinary{File: a.File, mapped: machoAddrMap(a.File, sr), fat: ff, r: sr}
+}
+
+func (m *machoBinary) Close() error {
+	if m.fat != nil {
+		return m.fat.Close()
+	}
+	return m.File.Close()
+}
+
+// fatVersion returns the Go version of the universal binary ff read from
+// r. The slices are usually built by the same toolchain; if not, the
+// oldest version is reported, as that is what policies care about.
+func fatVersion(ff *macho.FatFile, r io.ReaderAt, t *scanTiming) (string, error) {
+	ver, firstErr := "", error(nil)
+	for _, a := range ff.Arches {
+		sr := io.NewSectionReader(r, int64(a.Offset), int64(a.Size))
+		m := &machoBinary{File: a.File, mapped: machoAddrMap(a.File, sr)}
+		v, err := runStrategies(&binaryFile{Binary: m, r: sr}, t)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if ver != "" && v != ver {
+			slog.Debug("slices of universal binary differ", "arch", m.Arch(), "version", v, "other", ver)
+		}
+		if ver == "" || compareGoVersions(v, ver) < 0 {
+			ver = v
+		}
+	}
+	if ver == "" {
+		return "", firstErr
+	}
+	return ver, nil
+}
+
+// sliceReader returns the reader of the binary b, which is r unless b is
+// a slice of a universal binary read from r.
+func sliceReader(b Binary, r io.ReaderAt) io.ReaderAt {
+	if m, ok := b.(*machoBinary); ok && m.r != nil {
+		return m.r
+	}
+	return r
+}
+
+func (m *machoBinary) ReadAtVaddr(b []byte, vaddr uint64) (int, error) {
+	return m.mapped.readAt(b, vaddr)
+}
+
+func (m *machoBinary) DWARFSection(name string) []byte {
+	if s := m.Section("__debug_" + name); s != nil {
+		b, _ := s.Data()
+		return b
+	}
+	if s := m.Section("__zdebug_" + name); s != nil {
+		b, _ := s.Data()
+		return zdebugData(b)
+	}
+	return nil
 }
 
 func (m *machoBinary) PtrSize() uint {
-	switch m.Cpu {
-	case macho.Cpu386, macho.CpuArm, macho.CpuPpc:
+	switch m.Magic {
+	case macho.Magic32:
 		return 4
-	case macho.CpuAmd64, macho.CpuPpc64:
+	case macho.Magic64:
 		return 8
 	}
-	panic("unknown macho cpu")
+	return 0
+}
+
+func (m *machoBinary) Arch() string {
+	switch m.Cpu {
+	case macho.Cpu386:
+		return "386"
+	case macho.CpuAmd64:
+		return "amd64"
+	case macho.CpuArm:
+		return "arm"
+	case macho.CpuArm64:
+		return "arm64"
+	case macho.CpuPpc:
+		return "ppc"
+	case macho.CpuPpc64:
+		return "ppc64"
+	}
+	return strings.ToLower(m.Cpu.String())
 }
 
+// dwLangGo is the DW_AT_language value of Go compilation units.
+const dwLangGo = 0x16
+
 type variable struct {
 	Addr uint64
 	Type dwarf.Type
 }
 
+// maxStringLen is the longest string readString reads from a binary.
+var maxStringLen uint64 = 64 << 10
+
+// badStringError reports a string variable whose header points to data
+// that can't be read, as found in corrupt binaries.
+type badStringError struct {
+	Addr, Len uint64
+	Reason    string
+}
+
+func (e *badStringError) Error() string {
+	return fmt.Sprintf("invalid string of length %d at %#x: %s", e.Len, e.Addr, e.Reason)
+}
+
 func readString(b Binary, v *variable) (string, error) {
 	if v.Type.String() != "struct string" {
 		return "", fmt.Errorf("wrong type %q", v.Type.String())
 	}
 
-	val := make([]byte, v.Type.Size())
-	if _, err := b.ReadAtVaddr(val, v.Addr); err != nil {
+	if b.PtrSize() != 4 && b.PtrSize() != 8 {
+		return "", fmt.Errorf("unknown pointer size")
+	}
+	if v.Type.Size() != 2*int64(b.PtrSize()) {
+		return "", fmt.Errorf("wrong string header size %d", v.Type.Size())
+	}
+	return readStringAt(b, v.Addr)
+}
+
+// readStringAt reads the string whose header is at the address addr.
+func readStringAt(b Binary, addr uint64) (string, error) {
+	if b.PtrSize() != 4 && b.PtrSize() != 8 {
+		return "", fmt.Errorf("unknown pointer size")
+	}
+	val := make([]byte, 2*b.PtrSize())
+	if _, err := b.ReadAtVaddr(val, addr); err != nil {
 		return "", err
 	}
 
@@ -161,15 +535,48 @@ func readString(b Binary, v *variable) (string, error) {
 		slen = binary.LittleEndian.Uint64(val[8:])
 	}
 
+	// The length is untrusted, so don't allocate whatever it claims.
+	if slen > maxStringLen {
+		return "", &badStringError{Addr: sptr, Len: slen, Reason: "too long"}
+	}
 	val = make([]byte, slen)
 	if _, err := b.ReadAtVaddr(val, sptr); err != nil {
-		return "", err
+		return "", &badStringError{Addr: sptr, Len: slen, Reason: err.Error()}
 	}
 
 	return string(val), nil
 }
 
-func findVariable(d *dwarf.Data, name string) (*variable, error) {
+// findVariable looks up the global variable name. If the binary has a
+// .debug_pubnames index, the variable's entry is read directly. Otherwise
+// the compilation unit of the variable's package is searched first, and
+// all units only if the variable is not found there.
+func findVariable(b Binary, d *dwarf.Data, name string) (*variable, error) {
+	if off, ok := lookupPubname(b.DWARFSection("pubnames"), name); ok {
+		dr := d.Reader()
+		dr.Seek(off)
+		e, err := dr.Next()
+		if err == nil && e != nil && e.Tag == dwarf.TagVariable && e.Val(dwarf.AttrName) == name {
+			v, err := entryVariable(d, e)
+			if v != nil || err != nil {
+				return v, err
+			}
+		}
+	}
+
+	if i := strings.LastIndex(name, "."); i > 0 {
+		v, err := searchVariable(d, name, name[:i])
+		if v != nil || err != nil {
+			return v, err
+		}
+	}
+	return searchVariable(d, name, "")
+}
+
+// searchVariable walks the DWARF entries for the global variable name. If
+// unit is not empty, only the compilation unit of that name is searched,
+// which for Go code is the import path of the package.
+func searchVariable(d *dwarf.Data, name, unit string) (*variable, error) {
 	dr := d.Reader()
 	for {
 		e, err := dr.Next()
@@ -177,7 +584,24 @@ func findVariable(d *dwarf.Data, name string) (*variable, error) {
 			return nil, err
 		}
 
-		if e.Tag != dwarf.TagVariable {
+		switch e.Tag {
+		case dwarf.TagCompileUnit:
+			// Skip the units of C code linked in with cgo without
+			// walking all of their entries.
+			if lang, ok := e.Val(dwarf.AttrLanguage).(int64); ok && lang != dwLangGo {
+				dr.SkipChildren()
+			} else if unit != "" && e.Val(dwarf.AttrName) != unit {
+				dr.SkipChildren()
+			}
+			continue
+		case dwarf.TagVariable:
+		default:
+			// Global variables are direct children of their unit,
+			// so there is no need to look at function locals,
+			// type members and the like.
+			if e.Children {
+				dr.SkipChildren()
+			}
 			continue
 		}
 
@@ -185,78 +609,306 @@ func findVariable(d *dwarf.Data, name string) (*variable, error) {
 		if !ok || aname != name {
 			continue
 		}
-		loc, ok := e.Val(dwarf.AttrLocation).([]uint8)
-		if !ok {
+		v, err := entryVariable(d, e)
+		if v == nil && err == nil {
 			continue
 		}
-		if loc[0] != 3 {
-			return nil, fmt.Errorf("can't determine variable addr")
-		}
-		addr := uint64(0)
-		switch len(loc) {
-		case 5:
-			addr = uint64(binary.LittleEndian.Uint32(loc[1:]))
-		case 9:
-			addr = uint64(binary.LittleEndian.Uint64(loc[1:]))
-		default:
-			return nil, fmt.Errorf("unknown addr size")
-		}
+		return v, err
+	}
+}
 
-		off, ok := e.Val(dwarf.AttrType).(dwarf.Offset)
-		if !ok {
-			continue
-		}
-		typ, err := d.Type(off)
-		if err != nil {
-			return nil, err
-		}
+// entryVariable returns the address and type of the variable described by
+// e, or nil if e lacks either.
+func entryVariable(d *dwarf.Data, e *dwarf.Entry) (*variable, error) {
+	loc, ok := e.Val(dwarf.AttrLocation).([]uint8)
+	if !ok {
+		return nil, nil
+	}
+	if len(loc) == 0 || loc[0] != 3 {
+		return nil, fmt.Errorf("can't determine variable addr")
+	}
+	addr := uint64(0)
+	switch len(loc) {
+	case 5:
+		addr = uint64(binary.LittleEndian.Uint32(loc[1:]))
+	case 9:
+		addr = uint64(binary.LittleEndian.Uint64(loc[1:]))
+	default:
+		return nil, fmt.Errorf("unknown addr size")
+	}
 
-		return &variable{Addr: addr, Type: typ}, nil
+	off, ok := e.Val(dwarf.AttrType).(dwarf.Offset)
+	if !ok {
+		return nil, nil
 	}
-	return nil, nil
+	typ, err := d.Type(off)
+	if err != nil {
+		return nil, err
+	}
+
+	return &variable{Addr: addr, Type: typ}, nil
 }
 
+// findVersion returns the Go version file was built with.
 func findVersion(file string) (string, error) {
-	e, err := openBinary(file)
-	if err != nil {
-		return "", err
+	return findVersionTimed(file, nil)
+}
+
+// findVersionTimed is findVersion, recording the time the scan and each
+// strategy took and the bytes read in t, unless t is nil.
+func findVersionTimed(file string, t *scanTiming) (string, error) {
+	ver, b, err := openVersion(file, t)
+	if b != nil {
+		b.Close()
 	}
-	defer e.Close()
+	return ver, err
+}
 
-	d, err := e.DWARF()
+// openVersion is findVersionTimed, but also returns the binary the scan
+// parsed, still open, so that what else is reported about the file is
+// read without opening and parsing it again. It is nil if the file
+// couldn't be parsed; otherwise the caller closes it.
+func openVersion(file string, t *scanTiming) (ver string, b *binaryFile, err error) {
+	start := time.Now()
+	f, err := os.Open(longPath(file))
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+	var r io.ReaderAt = f
+	if t != nil {
+		cr := &countingReaderAt{r: f}
+		r = cr
+		defer func() {
+			t.BytesRead += cr.n
+			t.Seconds = time.Since(start).Seconds()
+		}()
+	}
+	ver, b, err = scanBinaryFile(file, r, t)
+	if b != nil {
+		b.name, b.c = file, f
+	} else {
+		defer f.Close()
+	}
+	if isNoVersion(err) && len(plugins) > 0 {
+		pstart := time.Now()
+		pver, perr := findVersionPlugins(file)
+		t.add("plugins", time.Since(pstart))
+		if !isNoVersion(perr) {
+			return pver, b, perr
+		}
+		err = addDiagnosis(err, "plugins", perr.Error())
+	}
+	return ver, b, err
+}
+
+// findVersionAt returns the Go version of the binary read from r.
+// Malformed files can make the debug packages panic; that is reported as
+// an error so one such file can't abort a batch or server scan.
+func findVersionAt(r io.ReaderAt) (ver string, err error) {
+	return findVersionAtTimed(r, nil)
+}
+
+// findVersionAtTimed is findVersionAt, recording the time of each strategy
+// in t unless t is nil.
+func findVersionAtTimed(r io.ReaderAt, t *scanTiming) (string, error) {
+	return scanBinary("", r, t)
+}
+
+// scanBinary returns the Go version of the binary file read from r,
+// parsing it once for every strategy. file is only used to find the Go
+// shared library of shared-linked binaries, which is not done if it is "".
+// The times are recorded in t unless it is nil.
+func scanBinary(file string, r io.ReaderAt, t *scanTiming) (string, error) {
+	ver, b, err := scanBinaryFile(file, r, t)
+	if b != nil {
+		b.Close()
+	}
+	return ver, err
+}
+
+// scanBinaryFile is scanBinary, but also returns the binary parsed from
+// r, which every strategy read, for reading what else is reported about
+// it. It is nil if r couldn't be parsed; otherwise the caller closes it,
+// which doesn't close r.
+func scanBinaryFile(file string, r io.ReaderAt, t *scanTiming) (ver string, b *binaryFile, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			slog.Debug("panic while scanning", "panic", p, "stack", string(debug.Stack()))
+			if b != nil {
+				b.Close()
+			}
+			ver, b, err = "", nil, fmt.Errorf("malformed binary: %v", p)
+		}
+	}()
+
+	b, err = newBinaryFile(r)
+	if err == errUnsupportedFormat || isEmptyFile(err) {
+		return "", nil, noVersionError{err}
 	}
-	v, err := findVariable(d, "runtime.buildVersion")
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+	// Binaries cut off in their data are still scanned, as what is left
+	// may well have the version, but if it doesn't, that is why.
+	if terr := checkTruncated(b.Binary, readerSize(r)); terr != nil {
+		defer func() {
+			if err != nil {
+				ver, err = "", terr
+			} else {
+				slog.Warn("binary is truncated", "file", file, "err", terr)
+			}
+		}()
+	}
+	if ef, ok := b.Binary.(*elfBinary); ok && file != "" {
+		if lib, path, ok := goSharedLib(file, ef.File); ok {
+			// The runtime, and with it the version that matters, is
+			// in the shared library; what the binary itself records
+			// is only the toolchain it was linked with.
+			switch {
+			case path == "":
+				slog.Debug("Go shared library not found", "file", file, "lib", lib)
+			case sameFile(path, file):
+				slog.Debug("Go shared library is the binary itself", "file", file)
+			default:
+				ver, err := scanSharedLib(path, t)
+				if err == nil {
+					return ver, b, nil
+				}
+				slog.Debug("scanning Go shared library failed", "file", file, "lib", path, "err", err)
+			}
+		}
 	}
-	if v == nil {
-		return "", fmt.Errorf("can't find version symbol")
+	if m, ok := b.Binary.(*machoBinary); ok && m.fat != nil {
+		ver, err = fatVersion(m.fat, r, t)
+		return ver, b, err
 	}
-	return readString(e, v)
+	ver, err = runStrategies(b, t)
+	return ver, b, err
 }
 
-func main() {
-	if len(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "usage: %s files...\n", os.Args[0])
-		os.Exit(1)
+// addGlobalFlags registers the flags every subcommand accepts, controlling
+// logging and TLS. level is the default minimum log level. The returned
+// function applies them and has to be called once the flags are parsed.
+func addGlobalFlags(fs *flag.FlagSet, level slog.Level) func() {
+	setupHTTP := addHTTPFlags(fs)
+	setupLogging := addLogFlags(fs, level)
+	return func() {
+		setupLogging()
+		setupHTTP()
 	}
+}
 
-	exit := 0
-	for _, f := range os.Args[1:] {
-		ver, err := findVersion(f)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "gover: %s: %s\n", f, err)
-			exit = 1
-			continue
+// parseArgs parses flags from args, allowing them to be interspersed with
+// positional arguments, and returns the positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) []string {
+	if collectFlags != nil {
+		collectFlags(fs)
+		runtime.Goexit()
+	}
+	var rest []string
+	for {
+		fs.Parse(args)
+		n := len(args) - fs.NArg()
+		if n > 0 && args[n-1] == "--" {
+			return append(rest, fs.Args()...)
 		}
-		if len(os.Args) > 2 {
-			fmt.Printf("%s: %s\n", f, ver)
-		} else {
-			fmt.Println(ver)
+		args = fs.Args()
+		if len(args) == 0 {
+			return rest
 		}
+		rest = append(rest, args[0])
+		args = args[1:]
 	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s [scan] [-r [-no-ignore] [-one-file-system] [-on-error skip|fail]] [-j n] [-timeout d] [-strict] [-no-cache] [-timing] [-plugin cmd] [-config file] [-var name] [-link-vars] [-app-version] [-digest algo] [-meta] [-compat go-version|syft|trivy] [-against-local] [-age] [-max-age age] [-H|-h] [-o file] [-progress[=json]] [-null] [-color when] [-path] [-gobin] [-apps] [-preset names] [-db file [-changed-only]] [-checkpoint file] [-json|-ndjson] [-schema] [-summary] [-sort key] [-group-by key] files...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s [scan] -watch [-r] [-interval d] [-webhook url] dirs...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s deps [-json] files...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s graph [-json] files...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s info [-json] files...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s funcs [-json] files...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s packages [-json] files...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s stats [-json] files...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s symbolize [-json] file [addrs...]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s sbom file\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s dep-snapshot [-sha commit] [-ref ref] [-correlator key] [-job-id id] files...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s vuln [-api url] files...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s image [-j n] image.tar...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s ssh [-r] [-j n] host:path...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s gh [-max-download n] [-j n] owner/repo[@tag]...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s systemd [unit-dir...]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s launchd [plist-dir...]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s winsvc\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s winapps [-json] [-no-program-files]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s services\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s history [-db file] [files...]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s diff old.json new.json\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s compare [-a] a.bin b.bin\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s sbom-diff old new\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s monitor [-webhook url] [-debug-addr addr]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s serve [-addr addr] [-max-size n] [-j n] [-webhook url] [-fetch-hosts hosts] [-debug-addr addr]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s update-db [-url url]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s version [-m]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s self-update [-check] [-f]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s gen-testdata [-o dir] [-go versions] [-platforms list] [-buildmodes list] [-docker]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s completion bash|zsh|fish|powershell\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "all commands accept -log-format, -log-level, -cacert and -insecure-skip-verify\n")
+	os.Exit(exitUsage)
+}
 
-	os.Exit(exit)
+// commands maps the subcommand names to their implementations, which
+// are passed the arguments following the name and return the exit code.
+var commands = map[string]func(args []string) int{
+	"scan":         scanMain,
+	"deps":         depsMain,
+	"graph":        graphMain,
+	"info":         infoMain,
+	"funcs":        funcsMain,
+	"packages":     packagesMain,
+	"stats":        statsMain,
+	"symbolize":    symbolizeMain,
+	"update-db":    updateDBMain,
+	"version":      versionMain,
+	"self-update":  selfUpdateMain,
+	"gen-testdata": genTestdataMain,
+	"sbom":         sbomMain,
+	"dep-snapshot": snapshotMain,
+	"vuln":         vulnMain,
+	"image":        imageMain,
+	"ssh":          sshMain,
+	"gh":           ghMain,
+	"systemd":      systemdMain,
+	"launchd":      launchdMain,
+	"winsvc":       winsvcMain,
+	"winapps":      winappsMain,
+	"services":     servicesMain,
+	"compare":      compareMain,
+	"sbom-diff":    sbomDiffMain,
+	"diff":         diffMain,
+	"history":      historyMain,
+	"serve":        serveMain,
+	"monitor":      monitorMain,
+}
+
+// servicesMain implements "gover services" using the service manager of
+// the running system.
+func servicesMain(args []string) int {
+	switch runtime.GOOS {
+	case "darwin":
+		return launchdMain(args)
+	case "windows":
+		return winsvcMain(args)
+	}
+	return systemdMain(args)
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			os.Exit(cmd(os.Args[2:]))
+		}
+	}
+	// Without a subcommand, the arguments are files to scan.
+	os.Exit(scanMain(os.Args[1:]))
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"log/slog"
+	"sync"
+)
+
+// maxMemory is the -max-memory budget, in bytes, or 0 for none.
+var maxMemory int64
+
+// memoryBudget bounds the memory taken by the scans running at the same
+// time. The debug packages read the sections gover looks at, the DWARF
+// info above all, into memory, so what a scan takes is estimated by the
+// size of the file scanned. A nil *memoryBudget puts no bound on scans.
+type memoryBudget struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	limit int64
+	used  int64
+}
+
+var (
+	memBudgetOnce sync.Once
+	memBudget     *memoryBudget
+)
+
+// scanBudget returns the budget of -max-memory, or nil if there is none.
+func scanBudget() *memoryBudget {
+	memBudgetOnce.Do(func() {
+		if maxMemory > 0 {
+			memBudget = &memoryBudget{limit: maxMemory}
+			memBudget.cond = sync.NewCond(&memBudget.mu)
+		}
+	})
+	return memBudget
+}
+
+// acquire waits until n bytes of the budget are free and takes them. The
+// returned function gives them back. A scan larger than the whole budget
+// waits for all others to finish and then runs alone, rather than never.
+func (m *memoryBudget) acquire(n int64) (release func()) {
+	if m == nil {
+		return func() {}
+	}
+	if n > m.limit {
+		n = m.limit
+	}
+	m.mu.Lock()
+	if m.used+n > m.limit {
+		slog.Debug("waiting for memory budget", "bytes", n, "used", m.used, "limit", m.limit)
+	}
+	for m.used+n > m.limit {
+		m.cond.Wait()
+	}
+	m.used += n
+	m.mu.Unlock()
+	return func() {
+		m.mu.Lock()
+		m.used -= n
+		m.mu.Unlock()
+		m.cond.Broadcast()
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+// binaryMeta describes the format of an executable, like file(1) does.
+// Machine is the format's own name for the target, as opposed to the
+// GOARCH name in Arch. Stripped is set if there is no symbol table.
+// Sections tells which of the sections the Go toolchain writes are
+// present: dwarf, symtab, pclntab, buildinfo and gobuildid, the note
+// holding the Go build ID. PE binaries keep pclntab and buildinfo in
+// general data sections, so those are only reported for ELF and Mach-O.
+type binaryMeta struct {
+	Format   string          `json:"format"`
+	Bits     int             `json:"bits"`
+	Endian   string          `json:"endian"`
+	Machine  string          `json:"machine"`
+	Arch     string          `json:"arch,omitempty"`
+	PIE      bool            `json:"pie"`
+	Stripped bool            `json:"stripped"`
+	Sections map[string]bool `json:"sections"`
+}
+
+// findBinaryMeta returns the format description of the binary b, or nil
+// if b is nil, as for files that are not executables.
+func findBinaryMeta(b *binaryFile) *binaryMeta {
+	if b == nil {
+		return nil
+	}
+	m := &binaryMeta{Bits: 8 * int(b.PtrSize()), Arch: b.Arch(), Sections: make(map[string]bool)}
+	_, err := b.DWARF()
+	m.Sections["dwarf"] = err == nil
+
+	endian := func(bo binary.ByteOrder) string {
+		if bo == binary.BigEndian {
+			return "big"
+		}
+		return "little"
+	}
+	switch f := b.Binary.(type) {
+	case *elfBinary:
+		m.Format, m.Endian, m.Machine = "elf", endian(f.ByteOrder), f.Machine.String()
+		if f.Type == elf.ET_DYN {
+			// Shared libraries are ET_DYN as well, but are not
+			// loaded by an interpreter.
+			m.PIE = f.Section(".interp") != nil || elfFlags1(f.File)&uint64(elf.DF_1_PIE) != 0
+		}
+		m.Stripped = f.Section(".symtab") == nil
+		m.Sections["symtab"] = !m.Stripped
+		m.Sections["pclntab"] = f.Section(".gopclntab") != nil || f.Section(".data.rel.ro.gopclntab") != nil
+		m.Sections["buildinfo"] = f.Section(".go.buildinfo") != nil
+		m.Sections["gobuildid"] = f.Section(".note.go.buildid") != nil
+	case *peBinary:
+		m.Format, m.Endian, m.Machine = "pe", "little", peMachine(f.Machine)
+		var dllChars uint16
+		switch oh := f.OptionalHeader.(type) {
+		case *pe.OptionalHeader32:
+			dllChars = oh.DllCharacteristics
+		case *pe.OptionalHeader64:
+			dllChars = oh.DllCharacteristics
+		}
+		m.PIE = dllChars&pe.IMAGE_DLLCHARACTERISTICS_DYNAMIC_BASE != 0
+		m.Stripped = f.NumberOfSymbols == 0
+		m.Sections["symtab"] = !m.Stripped
+	case *machoBinary:
+		m.Format, m.Endian, m.Machine = "macho", endian(f.ByteOrder), f.Cpu.String()
+		m.PIE = f.Flags&macho.FlagPIE != 0
+		m.Stripped = f.Symtab == nil || len(f.Symtab.Syms) == 0
+		m.Sections["symtab"] = !m.Stripped
+		m.Sections["pclntab"] = f.Section("__gopclntab") != nil
+		m.Sections["buildinfo"] = f.Section("__go_buildinfo") != nil
+	}
+	return m
+}
+
+// elfFlags1 returns the DT_FLAGS_1 entry of the dynamic section of f.
+func elfFlags1(f *elf.File) uint64 {
+	vals, err := f.DynValue(elf.DT_FLAGS_1)
+	if err != nil || len(vals) == 0 {
+		return 0
+	}
+	return vals[0]
+}
+
+func peMachine(m uint16) string {
+	switch m {
+	case pe.IMAGE_FILE_MACHINE_I386:
+		return "IMAGE_FILE_MACHINE_I386"
+	case pe.IMAGE_FILE_MACHINE_AMD64:
+		return "IMAGE_FILE_MACHINE_AMD64"
+	case pe.IMAGE_FILE_MACHINE_ARMNT:
+		return "IMAGE_FILE_MACHINE_ARMNT"
+	case pe.IMAGE_FILE_MACHINE_ARM64:
+		return "IMAGE_FILE_MACHINE_ARM64"
+	}
+	return fmt.Sprintf("%#x", m)
+}
+
+// String summarizes m in one line, like "elf 64-bit little-endian EM_X86_64
+// pie stripped".
+func (m *binaryMeta) String() string {
+	s := []string{m.Format, fmt.Sprintf("%d-bit", m.Bits), m.Endian + "-endian", m.Machine}
+	if m.PIE {
+		s = append(s, "pie")
+	}
+	if m.Stripped {
+		s = append(s, "stripped")
+	}
+	return strings.Join(s, " ")
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"expvar"
+	"flag"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+	"time"
+)
+
+// Metrics of the long-running commands, published with expvar as the
+// "gover" variable at /debug/vars of the -debug-addr listener, next to
+// the runtime's memstats.
+var (
+	metrics = expvar.NewMap("gover")
+
+	scansTotal     = new(expvar.Int)
+	scanErrors     = new(expvar.Int)
+	scanSeconds    = new(expvar.Float)
+	scanDurations  = new(expvar.Map).Init()
+	cacheHits      = new(expvar.Int)
+	cacheMisses    = new(expvar.Int)
+	requestsTotal  = new(expvar.Int)
+	requestSeconds = new(expvar.Float)
+	queueDepth     = new(expvar.Int)
+	inFlight       = new(expvar.Int)
+)
+
+// scanDurationBuckets are the upper bounds, in seconds, of the durations
+// scans are counted by.
+var scanDurationBuckets = []float64{0.001, 0.01, 0.1, 1, 10, 60}
+
+func init() {
+	metrics.Set("scans", scansTotal)
+	metrics.Set("scan_errors", scanErrors)
+	metrics.Set("scan_seconds", scanSeconds)
+	metrics.Set("scan_durations", scanDurations)
+	metrics.Set("cache_hits", cacheHits)
+	metrics.Set("cache_misses", cacheMisses)
+	metrics.Set("cache_hit_rate", expvar.Func(func() interface{} {
+		hits, misses := cacheHits.Value(), cacheMisses.Value()
+		if hits+misses == 0 {
+			return 0.0
+		}
+		return float64(hits) / float64(hits+misses)
+	}))
+	metrics.Set("requests", requestsTotal)
+	metrics.Set("request_seconds", requestSeconds)
+	metrics.Set("queue_depth", queueDepth)
+	metrics.Set("in_flight", inFlight)
+}
+
+// observeScan records a scan of a file that started at start and failed
+// with err, which files without a Go version don't count as.
+func observeScan(start time.Time, err error) {
+	d := time.Since(start).Seconds()
+	scansTotal.Add(1)
+	scanSeconds.Add(d)
+	if err != nil && !isNoVersion(err) {
+		scanErrors.Add(1)
+	}
+	bucket := "+Inf"
+	for _, le := range scanDurationBuckets {
+		if d <= le {
+			bucket = strconv.FormatFloat(le, 'g', -1, 64)
+			break
+		}
+	}
+	scanDurations.Add(bucket, 1)
+}
+
+// observeCache records a lookup in a cache of scan results.
+func observeCache(hit bool) {
+	if hit {
+		cacheHits.Add(1)
+	} else {
+		cacheMisses.Add(1)
+	}
+}
+
+// addDebugFlag registers -debug-addr on fs.
+func addDebugFlag(fs *flag.FlagSet) *string {
+	return fs.String("debug-addr", "", "serve pprof profiles and expvar metrics on `addr`, which should not be reachable publicly")
+}
+
+// startDebugServer serves the pprof profiles at /debug/pprof/ and the
+// expvar metrics at /debug/vars on addr, if it is set. It has a listener
+// of its own so that profiling is never exposed along with the service.
+func startDebugServer(addr string) {
+	if addr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	slog.Info("serving debug endpoints", "addr", addr)
+	go func() {
+		slog.Error("debug server failed", "err", http.ListenAndServe(addr, mux))
+	}()
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+)
+
+// monitorMain implements "gover monitor".
+func monitorMain(args []string) int {
+	fs := flag.NewFlagSet("monitor", flag.ExitOnError)
+	fs.Usage = usage
+	webhook := fs.String("webhook", "", "post policy violations to this URL")
+	debugAddr := addDebugFlag(fs)
+	colorMode := addColorFlag(fs)
+	setup := addGlobalFlags(fs, slog.LevelInfo)
+	parseArgs(fs, args)
+	setup()
+
+	r := &reporter{names: true, eol: true, webhook: *webhook}
+	r.color = useColor(*colorMode, r.stdout())
+	startDebugServer(*debugAddr)
+	if err := monitor(r); err != nil {
+		slog.Error("monitor failed", "err", err)
+	}
+	return exitError
+}
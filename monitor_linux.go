@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Constants of the Linux process events connector, see
+// include/uapi/linux/connector.h and include/uapi/linux/cn_proc.h.
+const (
+	netlinkConnector = 11
+	cnIdxProc        = 1
+	cnValProc        = 1
+
+	procCnMcastListen = 1
+	procEventExec     = 2
+
+	nlmsgHdrLen = 16
+	cnMsgLen    = 20
+)
+
+var nativeEndian binary.ByteOrder = binary.LittleEndian
+
+func init() {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 0 {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+type monitorKey struct {
+	path  string
+	size  int64
+	mtime time.Time
+}
+
+type monitorResult struct {
+	ver string
+	err error
+}
+
+// monitorEvent is an exec of the binary path by pid at time, with f the
+// binary opened through /proc as soon as the event was received. Holding
+// it keeps the binary readable once the process is gone, which for short
+// lived ones is often so by the time it is scanned.
+type monitorEvent struct {
+	time time.Time
+	pid  int
+	path string
+	f    *os.File
+}
+
+// monitorCache holds the results of the binaries scanned by monitor.
+// Results are cached by path, size and modification time, as most execs
+// are of the same few binaries. mu also serializes reporting.
+type monitorCache struct {
+	mu      sync.Mutex
+	results map[monitorKey]monitorResult
+}
+
+// monitor subscribes to process exec events through the kernel's process
+// events connector and reports the Go version of every executed binary.
+// Events are received by one goroutine and scanned by as many workers as
+// there are CPUs, so that slow scans don't hold up reading events. It
+// needs CAP_NET_ADMIN and never returns unless the subscription fails.
+func monitor(r *reporter) error {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM, netlinkConnector)
+	if err != nil {
+		return fmt.Errorf("proc connector: %s", err)
+	}
+	defer syscall.Close(fd)
+
+	sa := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK, Groups: cnIdxProc, Pid: uint32(os.Getpid())}
+	if err := syscall.Bind(fd, sa); err != nil {
+		return fmt.Errorf("proc connector: %s", err)
+	}
+
+	msg := make([]byte, nlmsgHdrLen+cnMsgLen+4)
+	nativeEndian.PutUint32(msg[0:], uint32(len(msg)))
+	nativeEndian.PutUint16(msg[4:], syscall.NLMSG_DONE)
+	nativeEndian.PutUint32(msg[12:], uint32(os.Getpid()))
+	cn := msg[nlmsgHdrLen:]
+	nativeEndian.PutUint32(cn[0:], cnIdxProc)
+	nativeEndian.PutUint32(cn[4:], cnValProc)
+	nativeEndian.PutUint16(cn[16:], 4)
+	nativeEndian.PutUint32(cn[cnMsgLen:], procCnMcastListen)
+	if err := syscall.Sendto(fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return fmt.Errorf("proc connector: %s", err)
+	}
+
+	cache := &monitorCache{results: make(map[monitorKey]monitorResult)}
+	events := make(chan monitorEvent, 256)
+	defer close(events)
+	for i := 0; i < runtime.NumCPU(); i++ {
+		go func() {
+			for e := range events {
+				monitorExec(e, cache, r)
+			}
+		}()
+	}
+
+	buf := make([]byte, os.Getpagesize())
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err == syscall.ENOBUFS {
+			// The socket buffer overran, which busy hosts do: the
+			// events that didn't fit are lost, but the next ones
+			// are received.
+			slog.Warn("proc connector dropped events", "err", err)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("proc connector: %s", err)
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			continue
+		}
+		for _, m := range msgs {
+			// struct cn_msg, then struct proc_event: what, cpu,
+			// timestamp_ns and the event data.
+			ev := m.Data
+			if len(ev) < cnMsgLen+16+8 || nativeEndian.Uint32(ev[cnMsgLen:]) != procEventExec {
+				continue
+			}
+			pid := int(nativeEndian.Uint32(ev[cnMsgLen+16:]))
+			if e, ok := openExec(pid); ok {
+				events <- e
+			}
+		}
+	}
+}
+
+// openExec returns the event for the exec by pid, with the binary it
+// runs opened, or false if the process is already gone.
+func openExec(pid int) (monitorEvent, bool) {
+	exe := "/proc/" + strconv.Itoa(pid) + "/exe"
+	path, err := os.Readlink(exe)
+	if err != nil {
+		return monitorEvent{}, false
+	}
+	f, err := os.Open(exe)
+	if err != nil {
+		return monitorEvent{}, false
+	}
+	return monitorEvent{time: time.Now(), pid: pid, path: path, f: f}, true
+}
+
+// monitorExec reports the binary run by the exec e and closes it. It is
+// called concurrently.
+func monitorExec(e monitorEvent, cache *monitorCache, r *reporter) {
+	defer e.f.Close()
+	fi, err := e.f.Stat()
+	if err != nil {
+		return
+	}
+	key := monitorKey{e.path, fi.Size(), fi.ModTime()}
+	cache.mu.Lock()
+	res, ok := cache.results[key]
+	cache.mu.Unlock()
+	observeCache(ok)
+	if !ok {
+		// Scan through the descriptor opened via /proc so deleted or
+		// replaced binaries are still read correctly.
+		start := time.Now()
+		res.ver, res.err = findVersion("/proc/self/fd/" + strconv.Itoa(int(e.f.Fd())))
+		observeScan(start, res.err)
+		cache.mu.Lock()
+		cache.results[key] = res
+		cache.mu.Unlock()
+	}
+	if isNoVersion(res.err) || os.IsNotExist(res.err) {
+		return
+	}
+	name := fmt.Sprintf("%s pid %d %s", e.time.Format(time.RFC3339), e.pid, e.path)
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+	r.report(name, res.ver, res.err)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import "errors"
+
+func monitor(r *reporter) error {
+	return errors.New("monitor is only supported on Linux")
+}
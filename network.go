@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// httpClient is used for all downloads. Like webhookClient it uses a
+// transport that honors HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+var httpClient = &http.Client{}
+
+// addHTTPFlags registers the -cacert and -insecure-skip-verify flags on fs.
+// The returned function configures the HTTP clients accordingly and has to
+// be called once the flags are parsed.
+func addHTTPFlags(fs *flag.FlagSet) func() {
+	cacert := fs.String("cacert", "", "trust the PEM encoded CA certificates in `file` in addition to the system ones")
+	insecure := fs.Bool("insecure-skip-verify", false, "don't verify the TLS certificates of servers")
+	return func() {
+		if *cacert == "" && !*insecure {
+			return
+		}
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = &tls.Config{InsecureSkipVerify: *insecure}
+		if *cacert != "" {
+			pool, err := loadCertPool(*cacert)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "gover: -cacert: %v\n", err)
+				os.Exit(exitUsage)
+			}
+			t.TLSClientConfig.RootCAs = pool
+		}
+		httpClient.Transport = t
+		webhookClient.Transport = t
+	}
+}
+
+// loadCertPool returns the system certificate pool with the certificates
+// in the PEM file name added.
+func loadCertPool(name string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", name)
+	}
+	return pool, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// atomicFile is written to a temporary file next to its destination, and
+// only renamed into place by Commit, so readers never see a partially
+// written file.
+type atomicFile struct {
+	*os.File
+	name string
+}
+
+func createAtomic(name string) (*atomicFile, error) {
+	f, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &atomicFile{File: f, name: name}, nil
+}
+
+// Commit flushes the file to disk and moves it to its destination.
+func (f *atomicFile) Commit() error {
+	if err := f.Chmod(0644); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), f.name)
+}
+
+// Abort discards the file.
+func (f *atomicFile) Abort() {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+var (
+	interruptOnce sync.Once
+	interruptMu   sync.Mutex
+	interruptFns  []func()
+)
+
+// onInterrupt arranges for fn to run if gover is interrupted or
+// terminated, before it exits with status 130, so that temporary files
+// are not left behind.
+func onInterrupt(fn func()) {
+	interruptOnce.Do(func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sig
+			interruptMu.Lock()
+			for _, fn := range interruptFns {
+				fn()
+			}
+			os.Exit(130)
+		}()
+	})
+	interruptMu.Lock()
+	interruptFns = append(interruptFns, fn)
+	interruptMu.Unlock()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"debug/gosym"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"sort"
+)
+
+// packagesResult is the packages -json output for one binary.
+type packagesResult struct {
+	File     string   `json:"file"`
+	Packages []string `json:"packages"`
+}
+
+// funcPackage returns the import path of the package defining f, or "" for
+// functions generated by the compiler and C functions. The linker escapes
+// dots and some other characters in the last path element, as in
+// gopkg.in/yaml%2ev3.
+func funcPackage(f *gosym.Func) string {
+	pkg := f.PackageName()
+	if pkg == "_" {
+		// Closures in functions with a go:linkname to "_".
+		return ""
+	}
+	if p, err := url.PathUnescape(pkg); err == nil {
+		pkg = p
+	}
+	return pkg
+}
+
+// linkedPackages returns the sorted import paths of the Go packages with
+// functions in tab.
+func linkedPackages(tab *gosym.Table) []string {
+	seen := make(map[string]bool)
+	pkgs := []string{}
+	for i := range tab.Funcs {
+		pkg := funcPackage(&tab.Funcs[i])
+		if pkg != "" && !seen[pkg] {
+			seen[pkg] = true
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	sort.Strings(pkgs)
+	return pkgs
+}
+
+// packagesMain implements "gover packages": it lists the Go packages
+// linked into binaries, derived from the function names in the pclntab.
+// Packages all of whose functions were inlined or removed by the linker
+// don't appear.
+func packagesMain(args []string) int {
+	fs := flag.NewFlagSet("packages", flag.ExitOnError)
+	fs.Usage = usage
+	jsonOut := fs.Bool("json", false, "print the packages as JSON")
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	files := parseArgs(fs, args)
+	setup()
+	if len(files) < 1 {
+		usage()
+	}
+
+	exit := 0
+	results := []interface{}{}
+	for i, file := range files {
+		tab, err := loadPclntab(file)
+		if err != nil {
+			slog.Error("reading pclntab failed", "file", file, "err", err)
+			exit = worseExit(exit, readExit(err))
+			if *jsonOut {
+				results = append(results, newFileFailure(file, err))
+			}
+			continue
+		}
+		res := packagesResult{File: file, Packages: linkedPackages(tab)}
+		if *jsonOut {
+			results = append(results, res)
+			continue
+		}
+
+		if len(files) > 1 {
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Printf("%s:\n", file)
+		}
+		for _, pkg := range res.Packages {
+			fmt.Println(pkg)
+		}
+	}
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(results)
+	}
+	return exit
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// pathExecutables returns the executables reachable through the PATH
+// environment variable. Commands shadowed by an earlier PATH entry of the
+// same name are left out, as they are never run.
+func pathExecutables() []string {
+	var files []string
+	seen := make(map[string]bool)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir == "" {
+			dir = "."
+		}
+		fis, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, fi := range fis {
+			name := fi.Name()
+			key := name
+			if runtime.GOOS == "windows" {
+				key = strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+			}
+			if seen[key] {
+				continue
+			}
+			file := filepath.Join(dir, name)
+			// Stat follows symlinks, which is how most tools end up
+			// on the PATH.
+			fi, err := os.Stat(file)
+			if err != nil || !isExecutable(fi) {
+				continue
+			}
+			seen[key] = true
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+func isExecutable(fi os.FileInfo) bool {
+	if !fi.Mode().IsRegular() {
+		return false
+	}
+	if runtime.GOOS != "windows" {
+		return fi.Mode()&0111 != 0
+	}
+	pathext := os.Getenv("PATHEXT")
+	if pathext == "" {
+		pathext = ".com;.exe;.bat;.cmd"
+	}
+	ext := strings.ToLower(filepath.Ext(fi.Name()))
+	for _, e := range filepath.SplitList(strings.ToLower(pathext)) {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
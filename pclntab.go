@@ -0,0 +1,220 @@
+package main
+
+import (
+	"debug/elf"
+	"debug/gosym"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var errNoPclntab = errors.New("no pclntab found")
+
+// pclntabMagics are the magic numbers starting the pclntab header of Go
+// 1.2, 1.16, 1.18 and 1.20 and later.
+var pclntabMagics = []uint32{0xfffffffb, 0xfffffffa, 0xfffffff0, 0xfffffff1}
+
+// pclntabSymbols are the linker symbols locating the pclntab and the text
+// it describes.
+var pclntabSymbols = map[string]bool{
+	"runtime.text":     true,
+	"runtime.pclntab":  true,
+	"runtime.epclntab": true,
+}
+
+// progData reads the data of an ELF segment like that of a section.
+type progData struct{ p *elf.Prog }
+
+func (d progData) Data() ([]byte, error) {
+	return io.ReadAll(d.p.Open())
+}
+
+// loadPclntab reads the pclntab of the binary file.
+func loadPclntab(file string) (*gosym.Table, error) {
+	b, err := openBinary(file)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+	return b.Pclntab()
+}
+
+// readPclntab reads the pclntab of bf, the table the runtime uses for stack
+// traces. It maps program counters to functions and lines and is kept
+// when binaries are stripped, so the functions it lists are available
+// without a symbol table or DWARF info.
+func readPclntab(bf *binaryFile) (tab *gosym.Table, err error) {
+	var (
+		data   []byte
+		text   uint64
+		syms   = make(map[string]uint64)
+		order  binary.ByteOrder
+		search []interface{ Data() ([]byte, error) }
+	)
+	switch b := bf.Binary.(type) {
+	case *elfBinary:
+		order = b.ByteOrder
+		if all, err := b.Symbols(); err == nil {
+			for _, s := range all {
+				if pclntabSymbols[s.Name] {
+					syms[s.Name] = s.Value
+				}
+			}
+		}
+		for _, name := range []string{".gopclntab", ".data.rel.ro.gopclntab"} {
+			if s := b.Section(name); s != nil {
+				data, _ = s.Data()
+				break
+			}
+		}
+		if s := b.Section(".text"); s != nil {
+			text = s.Addr
+		}
+		for _, s := range b.Sections {
+			if s.Type == elf.SHT_PROGBITS && s.Flags&elf.SHF_EXECINSTR == 0 {
+				search = append(search, s)
+			}
+		}
+		if !hasLoadedSections(b.File) {
+			// Without sections for them, the read-only segments
+			// are where the pclntab is, as for elfAddrMap.
+			for _, p := range b.Progs {
+				if p.Type == elf.PT_LOAD && p.Flags&elf.PF_X == 0 {
+					search = append(search, progData{p})
+				}
+			}
+		}
+	case *peBinary:
+		order = binary.LittleEndian
+		base := b.imageBase()
+		for _, s := range b.Symbols {
+			if pclntabSymbols[s.Name] && s.SectionNumber > 0 && int(s.SectionNumber) <= len(b.Sections) {
+				syms[s.Name] = base + uint64(b.Sections[s.SectionNumber-1].VirtualAddress) + uint64(s.Value)
+			}
+		}
+		if s := b.Section(".text"); s != nil {
+			text = base + uint64(s.VirtualAddress)
+		}
+		for _, s := range b.Sections {
+			if s.Characteristics&pe.IMAGE_SCN_MEM_EXECUTE == 0 {
+				search = append(search, s)
+			}
+		}
+	case *machoBinary:
+		order = b.ByteOrder
+		if b.Symtab != nil {
+			for _, s := range b.Symtab.Syms {
+				if pclntabSymbols[s.Name] {
+					syms[s.Name] = s.Value
+				}
+			}
+		}
+		if s := b.Section("__gopclntab"); s != nil {
+			data, _ = s.Data()
+		}
+		if s := b.Section("__text"); s != nil {
+			text = s.Addr
+		}
+		for _, s := range b.Sections {
+			if s.Seg != "__TEXT" || s.Name != "__text" {
+				search = append(search, s)
+			}
+		}
+	default:
+		return nil, errUnsupportedFormat
+	}
+
+	if data == nil {
+		start, end := syms["runtime.pclntab"], syms["runtime.epclntab"]
+		if start != 0 && end > start && end-start < 1<<30 {
+			buf := make([]byte, end-start)
+			if _, err := bf.ReadAtVaddr(buf, start); err == nil {
+				data = buf
+			}
+		}
+	}
+	if data == nil {
+		// Stripped PE binaries keep the pclntab somewhere in their
+		// data sections, so look for its header.
+		for _, s := range search {
+			sdata, err := s.Data()
+			if err != nil {
+				continue
+			}
+			if i := findPclntabHeader(sdata, order); i >= 0 {
+				data = sdata[i:]
+				break
+			}
+		}
+	}
+	if data == nil {
+		return nil, errNoPclntab
+	}
+	if t, ok := syms["runtime.text"]; ok {
+		text = t
+	} else if t := pclntabTextStart(data, order); t != 0 {
+		text = t
+	}
+
+	// gosym panics on tables that are corrupt rather than returning an
+	// error.
+	defer func() {
+		if r := recover(); r != nil {
+			tab, err = nil, fmt.Errorf("corrupt pclntab: %v", r)
+		}
+	}()
+	tab, err = gosym.NewTable(nil, gosym.NewLineTable(data, text))
+	if err != nil {
+		return nil, err
+	}
+	if len(tab.Funcs) == 0 {
+		return nil, errNoPclntab
+	}
+	return tab, nil
+}
+
+// findPclntabHeader returns the offset of the first plausible pclntab
+// header in data, or -1: the magic number is followed by two zero bytes,
+// the instruction size quantum and the pointer size.
+func findPclntabHeader(data []byte, order binary.ByteOrder) int {
+	for i := 0; i+8 <= len(data); i += 4 {
+		magic := order.Uint32(data[i:])
+		known := false
+		for _, m := range pclntabMagics {
+			known = known || magic == m
+		}
+		if !known || data[i+4] != 0 || data[i+5] != 0 {
+			continue
+		}
+		quantum, ptrSize := data[i+6], data[i+7]
+		if (quantum == 1 || quantum == 2 || quantum == 4) && (ptrSize == 4 || ptrSize == 8) {
+			return i
+		}
+	}
+	return -1
+}
+
+// pclntabTextStart returns the address of runtime.text recorded in the
+// header of Go 1.18 and later pclntabs, or 0. Position independent
+// binaries have 0 there until the dynamic linker relocates it.
+func pclntabTextStart(data []byte, order binary.ByteOrder) uint64 {
+	if len(data) < 8 {
+		return 0
+	}
+	switch order.Uint32(data) {
+	case 0xfffffff0, 0xfffffff1:
+	default:
+		return 0
+	}
+	ptrSize := int(data[7])
+	off := 8 + 2*ptrSize
+	if len(data) < off+ptrSize {
+		return 0
+	}
+	if ptrSize == 4 {
+		return uint64(order.Uint32(data[off:]))
+	}
+	return order.Uint64(data[off:])
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// archiveJobs is the number of archive members, and of image layers, that
+// are scanned at the same time.
+var archiveJobs = runtime.NumCPU()
+
+// addArchiveJobsFlag registers -j on fs, for the commands that scan
+// archives and don't have a -j of their own.
+func addArchiveJobsFlag(fs *flag.FlagSet) {
+	fs.IntVar(&archiveJobs, "j", archiveJobs, "number of archive members and image layers to scan concurrently")
+}
+
+var (
+	memberSlotsOnce sync.Once
+	memberSlots     chan struct{}
+)
+
+// acquireMemberSlot waits until fewer than archiveJobs members are being
+// scanned, across all archives, and returns the function that ends the
+// scan. The layers of an image are read concurrently, each with its own
+// pipeline, so that bounds more than any one of them.
+func acquireMemberSlot() (release func()) {
+	memberSlotsOnce.Do(func() {
+		memberSlots = make(chan struct{}, max(archiveJobs, 1))
+	})
+	memberSlots <- struct{}{}
+	return func() { <-memberSlots }
+}
+
+// copyBuffers are the buffers archive members are copied with, shared by
+// the concurrent scans of a server rather than allocated for every member.
+var copyBuffers = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 32<<10)
+		return &b
+	},
+}
+
+// spillReader copies the contents of r into a temporary file, and returns
+// its name and size. The caller removes it.
+func spillReader(r io.Reader) (name string, size int64, err error) {
+	f, err := ioutil.TempFile("", "gover")
+	if err != nil {
+		return "", 0, err
+	}
+	buf := copyBuffers.Get().(*[]byte)
+	// Hide the file's ReadFrom, which would allocate a buffer of its
+	// own.
+	size, err = io.CopyBuffer(struct{ io.Writer }{f}, &limitedReader{r: r, n: maxFileSize}, *buf)
+	copyBuffers.Put(buf)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", 0, err
+	}
+	return f.Name(), size, nil
+}
+
+// scanSpilled looks for a Go version in the temporary file name of size
+// bytes, written by spillReader, and removes it.
+func scanSpilled(name string, size int64) (string, error) {
+	defer os.Remove(name)
+	defer markSpilled(name)()
+	defer acquireMemberSlot()()
+	defer scanBudget().acquire(size)()
+	start := time.Now()
+	ver, err := findVersion(name)
+	observeScan(start, err)
+	return ver, err
+}
+
+// memberJob is an archive member queued in a memberPipeline.
+type memberJob struct {
+	name string
+	file string
+	size int64
+	done chan struct{}
+
+	ver string
+	err error
+}
+
+// memberPipeline scans the members of an archive while it is read on:
+// reading, which for compressed archives is mostly decompressing, is
+// sequential, but the members read are spilled to temporary files and
+// scanned concurrently. Results are passed to fn in the order the members
+// were added, from a single goroutine. Members are only spilled as far as
+// 2*archiveJobs ahead of the one whose result is passed on next, so at
+// most 2*archiveJobs+2 of them are on disk at once, each up to
+// maxFileSize.
+type memberPipeline struct {
+	fn    func(name, ver string, err error)
+	queue chan *memberJob
+	order chan *memberJob
+	done  chan struct{}
+}
+
+func newMemberPipeline(fn func(name, ver string, err error)) *memberPipeline {
+	jobs := max(archiveJobs, 1)
+	p := &memberPipeline{
+		fn:    fn,
+		queue: make(chan *memberJob),
+		order: make(chan *memberJob, 2*jobs),
+		done:  make(chan struct{}),
+	}
+	for i := 0; i < jobs; i++ {
+		go func() {
+			for j := range p.queue {
+				j.ver, j.err = scanSpilled(j.file, j.size)
+				close(j.done)
+			}
+		}()
+	}
+	go func() {
+		for j := range p.order {
+			<-j.done
+			p.fn(j.name, j.ver, j.err)
+		}
+		close(p.done)
+	}()
+	return p
+}
+
+// add reads the member name from r and queues it for scanning. It
+// returns an error only if reading has to stop, which is when the
+// archive exceeds the limits on extraction; other failures are the
+// member's result.
+func (p *memberPipeline) add(name string, r io.Reader) error {
+	file, size, err := spillReader(r)
+	if isExtractAbort(err) {
+		return err
+	}
+	j := &memberJob{name: name, file: file, size: size, done: make(chan struct{})}
+	p.order <- j
+	if err != nil {
+		j.err = err
+		close(j.done)
+		return nil
+	}
+	p.queue <- j
+	return nil
+}
+
+// result queues the result of a member that isn't scanned.
+func (p *memberPipeline) result(name, ver string, err error) {
+	j := &memberJob{name: name, ver: ver, err: err, done: make(chan struct{})}
+	close(j.done)
+	p.order <- j
+}
+
+// wait waits until the results of all members added were passed to fn.
+// Nothing can be added after.
+func (p *memberPipeline) wait() {
+	close(p.queue)
+	close(p.order)
+	<-p.done
+}
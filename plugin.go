@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// plugins are the external detectors given with -plugin, in order.
+var plugins []string
+
+// pluginTimeout is how long a detector may take for one file.
+const pluginTimeout = 30 * time.Second
+
+// pluginProtocol is the version of the detector protocol.
+const pluginProtocol = 1
+
+// pluginRequest is written to the standard input of a detector. File is
+// an absolute path.
+type pluginRequest struct {
+	Protocol int    `json:"protocol"`
+	File     string `json:"file"`
+}
+
+// pluginResponse is read from the standard output of a detector. Both
+// fields are empty if the detector doesn't recognize the file; Error
+// reports a file that it recognized but failed to read.
+type pluginResponse struct {
+	Version string `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// pluginsFlag is the value of the repeatable -plugin flag.
+type pluginsFlag struct{}
+
+func (pluginsFlag) String() string { return strings.Join(plugins, ",") }
+
+func (pluginsFlag) Set(s string) error {
+	plugins = append(plugins, s)
+	return nil
+}
+
+// addPluginFlags registers the -plugin flag on fs.
+func addPluginFlags(fs *flag.FlagSet) {
+	fs.Var(pluginsFlag{}, "plugin", "ask the detector `command` about files gover finds no version in; may be repeated")
+}
+
+// findVersionPlugins asks the detectors in turn for the Go version of
+// file until one recognizes it. The result is a noVersionError if none
+// does. A detector that fails is logged and skipped.
+func findVersionPlugins(file string) (string, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	req, err := json.Marshal(pluginRequest{Protocol: pluginProtocol, File: abs})
+	if err != nil {
+		return "", err
+	}
+	for _, p := range plugins {
+		resp, err := runPlugin(p, req)
+		if err != nil {
+			slog.Warn("detector failed", "plugin", p, "file", file, "err", err)
+			continue
+		}
+		if resp.Error != "" {
+			return "", fmt.Errorf("%s: %s", filepath.Base(p), resp.Error)
+		}
+		if resp.Version != "" {
+			slog.Debug("detected by plugin", "plugin", p, "file", file, "version", resp.Version)
+			return resp.Version, nil
+		}
+	}
+	return "", noVersionError{errors.New("not recognized by any plugin")}
+}
+
+// runPlugin runs the detector p with the JSON request req.
+func runPlugin(p string, req []byte) (*pluginResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), pluginTimeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, p)
+	cmd.Stdin = bytes.NewReader(append(req, '\n'))
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%v: %s", err, msg)
+		}
+		return nil, err
+	}
+	var resp pluginResponse
+	if err := json.Unmarshal(stdout.Bytes(), &resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %v", err)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// preset is a set of directories a package manager installs binaries to,
+// scanned recursively by -preset, with patterns in the syntax of ignore
+// files for what below them holds no binaries worth scanning.
+type preset struct {
+	// dirs starting with "~/" are in the home directory.
+	dirs   []string
+	ignore []string
+}
+
+var presets = map[string]preset{
+	"homebrew": {
+		dirs:   []string{"/opt/homebrew/Cellar", "/usr/local/Cellar", "/home/linuxbrew/.linuxbrew/Cellar", "~/.linuxbrew/Cellar"},
+		ignore: []string{"share/", "include/", "*.rb", "*.h"},
+	},
+	"flatpak": {
+		// Runtimes are the shared base systems apps run on, not apps.
+		dirs:   []string{"/var/lib/flatpak/app", "~/.local/share/flatpak/app"},
+		ignore: []string{"share/", "include/", "locale/"},
+	},
+	"snap": {
+		// Base snaps are whole distributions the others run on.
+		dirs:   []string{"/snap"},
+		ignore: []string{"/core/", "/core[0-9]*/", "/bare/", "/gnome-*/", "/gtk-common-themes/", "**/usr/share/", "include/"},
+	},
+	"nix": {
+		// .links holds hard links to duplicates of other store files.
+		dirs:   []string{"/nix/store"},
+		ignore: []string{"/.links/", "*.drv", "*-source/", "share/", "include/", "*.patch"},
+	},
+}
+
+// presetIgnore holds the exclusions of the -preset given, by the preset
+// directory they apply to, before the rules of its ignore file. Preset
+// directories are walked even without -r.
+var presetIgnore map[string]ignoreList
+
+// isPresetDir reports whether dir is a directory of the -preset given.
+func isPresetDir(dir string) bool {
+	_, ok := presetIgnore[dir]
+	return ok
+}
+
+// presetNames returns the names of the presets, sorted.
+func presetNames() []string {
+	var names []string
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyPresets returns the directories of the comma-separated presets in
+// list that exist on this system, and records their exclusions for them in
+// presetIgnore.
+func applyPresets(list string) ([]string, error) {
+	home, _ := os.UserHomeDir()
+	var dirs []string
+	for _, name := range splitList(list) {
+		p, ok := presets[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown preset %q, want one of %s", name, strings.Join(presetNames(), ", "))
+		}
+		for _, d := range p.dirs {
+			if strings.HasPrefix(d, "~/") {
+				if home == "" {
+					continue
+				}
+				d = filepath.Join(home, d[2:])
+			}
+			if fi, err := os.Stat(longPath(d)); err != nil || !fi.IsDir() {
+				slog.Debug("preset directory not found", "preset", name, "dir", d)
+				continue
+			}
+			l, err := parseIgnore(strings.NewReader(strings.Join(p.ignore, "\n")))
+			if err != nil {
+				return nil, err
+			}
+			if presetIgnore == nil {
+				presetIgnore = make(map[string]ignoreList)
+			}
+			presetIgnore[d] = append(presetIgnore[d], l...)
+			dirs = append(dirs, d)
+		}
+	}
+	return dirs, nil
+}
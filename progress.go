@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progressMode is the value of the -progress flag. It can be given without
+// a value to select the text status line.
+type progressMode string
+
+func (m *progressMode) String() string { return string(*m) }
+
+func (m *progressMode) Set(s string) error {
+	switch s {
+	case "true", "text":
+		*m = "text"
+	case "false", "":
+		*m = ""
+	default:
+		if !isFlagValue("progress", s) {
+			return fmt.Errorf("must be %s", strings.Join(flagValues["progress"], " or "))
+		}
+		*m = progressMode(s)
+	}
+	return nil
+}
+
+func (m *progressMode) IsBoolFlag() bool { return true }
+
+// progress periodically reports how far a scan has got on standard error,
+// either as a status line that is redrawn in place or as JSON heartbeat
+// events, one per line.
+type progress struct {
+	mode  string
+	w     io.Writer
+	tty   bool
+	start time.Time
+	stop  chan struct{}
+	done  chan struct{}
+
+	mu      sync.Mutex
+	drawn   bool
+	scanned int
+	found   int
+	errors  int
+}
+
+// progressEvent is a heartbeat printed by -progress=json.
+type progressEvent struct {
+	Event   string  `json:"event"`
+	Elapsed float64 `json:"elapsed"`
+	Scanned int     `json:"scanned"`
+	Found   int     `json:"found"`
+	Errors  int     `json:"errors"`
+}
+
+func startProgress(mode string) *progress {
+	p := &progress{
+		mode:  mode,
+		w:     os.Stderr,
+		start: time.Now(),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if fi, err := os.Stderr.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		p.tty = true
+	}
+	interval := time.Second
+	if p.tty && mode == "text" {
+		interval = 200 * time.Millisecond
+	}
+	go func() {
+		defer close(p.done)
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-t.C:
+				p.mu.Lock()
+				p.print("progress")
+				p.mu.Unlock()
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// update runs f, which may write to the terminal, with the status line
+// removed and counts a scanned file. found and failed tell whether it
+// turned out to be a Go binary or could not be scanned.
+func (p *progress) update(found, failed bool, f func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.drawn {
+		fmt.Fprint(p.w, "\r\x1b[K")
+		p.drawn = false
+	}
+	p.scanned++
+	if found {
+		p.found++
+	}
+	if failed {
+		p.errors++
+	}
+	f()
+}
+
+// finish stops the periodic reports and prints the final counts.
+func (p *progress) finish() {
+	close(p.stop)
+	<-p.done
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.print("done")
+	if p.drawn {
+		fmt.Fprintln(p.w)
+	}
+}
+
+func (p *progress) print(event string) {
+	if p.mode == "json" {
+		json.NewEncoder(p.w).Encode(progressEvent{
+			Event:   event,
+			Elapsed: time.Since(p.start).Seconds(),
+			Scanned: p.scanned,
+			Found:   p.found,
+			Errors:  p.errors,
+		})
+		return
+	}
+	line := fmt.Sprintf("scanned %d files, %d Go binaries, %d errors", p.scanned, p.found, p.errors)
+	if !p.tty {
+		fmt.Fprintln(p.w, line)
+		return
+	}
+	fmt.Fprint(p.w, "\r\x1b[K"+line)
+	p.drawn = true
+}
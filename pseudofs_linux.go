@@ -0,0 +1,32 @@
+package main
+
+import "syscall"
+
+// pseudoFS are the magic numbers of the Linux file systems that hold no
+// binaries, only kernel state presented as files. Reading some of those
+// files blocks or has side effects.
+var pseudoFS = map[uint32]bool{
+	0x9fa0:     true, // proc
+	0x62656572: true, // sysfs
+	0x1cd1:     true, // devpts
+	0x27e0eb:   true, // cgroup
+	0x63677270: true, // cgroup2
+	0x64626720: true, // debugfs
+	0x74726163: true, // tracefs
+	0x73636673: true, // securityfs
+	0xcafe4a11: true, // bpf
+	0x6e736673: true, // nsfs
+	0x42494e4d: true, // binfmt_misc
+	0x50495045: true, // pipefs
+	0x534f434b: true, // sockfs
+}
+
+// isPseudoFS reports whether the directory dir is on a pseudo file system
+// like /proc or /sys.
+func isPseudoFS(dir string) bool {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return false
+	}
+	return pseudoFS[uint32(st.Type)]
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+// isPseudoFS reports whether the directory dir is on a pseudo file
+// system. Only Linux mounts those inside the tree, as /proc and /sys.
+func isPseudoFS(dir string) bool {
+	return false
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// releaseDataURL is where "gover update-db" fetches the release data
+// from by default. The signature is at the same URL with ".sig" appended.
+// Release builds set it, and releaseKey, with -ldflags -X; see
+// signdata.go for how the data is signed.
+var releaseDataURL = ""
+
+// releaseKey is the base64 encoded Ed25519 public key the project signs
+// release data and the checksum files of gover releases with. Builds
+// without one can't verify either, so update-db and self-update refuse
+// to run.
+var releaseKey = ""
+
+var errNoReleaseKey = errors.New("gover was built without a release signing key")
+
+// maxReleaseData limits the size of downloaded release data files.
+const maxReleaseData = 1 << 20
+
+// releaseData is the data about Go releases that can be updated without
+// a new gover release: the latest major release, which determines which
+// ones are end of life, and the release dates as in goReleaseDates.
+// Generated orders the files published, so that an old one can't be
+// installed over a newer one.
+type releaseData struct {
+	Generated time.Time           `json:"generated"`
+	Latest    string              `json:"latest"`
+	Releases  map[string][]string `json:"releases"`
+}
+
+var (
+	releaseDataOnce sync.Once
+	releaseDataUpd  *releaseData
+)
+
+// releaseDataPath returns the location of the downloaded release data in
+// the user's cache directory.
+func releaseDataPath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gover", "releases.json"), nil
+}
+
+// updatedReleaseData returns the release data last downloaded by
+// "gover update-db", or nil if there is none, in which case the data
+// compiled into gover is used. The signature is checked again, so a
+// tampered file is ignored.
+func updatedReleaseData() *releaseData {
+	releaseDataOnce.Do(func() {
+		name, err := releaseDataPath()
+		if err != nil {
+			return
+		}
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return
+		}
+		sig, err := os.ReadFile(name + ".sig")
+		if err != nil {
+			return
+		}
+		rd, err := verifyReleaseData(data, sig)
+		if err != nil {
+			slog.Warn("ignoring release data", "file", name, "err", err)
+			return
+		}
+		releaseDataUpd = rd
+	})
+	return releaseDataUpd
+}
+
+// verifySignature checks that data is signed by releaseKey with the
+// base64 encoded signature sig.
+func verifySignature(data, sig []byte) error {
+	if releaseKey == "" {
+		return errNoReleaseKey
+	}
+	key, err := base64.StdEncoding.DecodeString(releaseKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return errors.New("malformed release signing key")
+	}
+	s, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig)))
+	if err != nil || len(s) != ed25519.SignatureSize {
+		return errors.New("malformed signature")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), data, s) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// verifyReleaseData checks that data is signed by releaseKey with the
+// base64 encoded signature sig, and parses it.
+func verifyReleaseData(data, sig []byte) (*releaseData, error) {
+	if err := verifySignature(data, sig); err != nil {
+		return nil, err
+	}
+	var rd releaseData
+	if err := json.Unmarshal(data, &rd); err != nil {
+		return nil, err
+	}
+	if rd.Generated.IsZero() {
+		return nil, errors.New("release data has no generation time")
+	}
+	if _, ok := parseGoVersion(rd.Latest); !ok {
+		return nil, fmt.Errorf("invalid latest release %q", rd.Latest)
+	}
+	for rel, dates := range rd.Releases {
+		for _, d := range dates {
+			if _, err := time.Parse(time.DateOnly, d); err != nil {
+				return nil, fmt.Errorf("invalid date %q for %s", d, rel)
+			}
+		}
+	}
+	return &rd, nil
+}
+
+// fetchReleaseData downloads the release data and its signature from url
+// and verifies them.
+func fetchReleaseData(url string) (data, sig []byte, err error) {
+	get := func(url string) ([]byte, error) {
+		resp, err := httpGet(url, nil)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(io.LimitReader(resp.Body, maxReleaseData+1))
+		if err == nil && len(b) > maxReleaseData {
+			err = fmt.Errorf("%s: larger than %d bytes", url, maxReleaseData)
+		}
+		return b, err
+	}
+	if data, err = get(url); err != nil {
+		return nil, nil, err
+	}
+	if sig, err = get(url + ".sig"); err != nil {
+		return nil, nil, err
+	}
+	if _, err := verifyReleaseData(data, sig); err != nil {
+		return nil, nil, err
+	}
+	return data, sig, nil
+}
+
+// writeFileAtomic replaces the file name with data.
+func writeFileAtomic(name string, data []byte) error {
+	f, err := createAtomic(name)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Commit()
+}
+
+// updateDBMain implements "gover update-db": it downloads the latest
+// release data into the user's cache directory, so that new releases are
+// known without updating gover. Data generated before the installed data
+// is refused, so that it can't be rolled back to hide releases.
+func updateDBMain(args []string) int {
+	fs := flag.NewFlagSet("update-db", flag.ExitOnError)
+	fs.Usage = usage
+	url := fs.String("url", releaseDataURL, "fetch the release data from `url`")
+	addRetryFlags(fs)
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	if len(parseArgs(fs, args)) > 0 {
+		usage()
+	}
+	setup()
+
+	if releaseKey == "" {
+		slog.Error("can't verify release data", "err", errNoReleaseKey)
+		return exitError
+	}
+	if *url == "" {
+		slog.Error("no release data URL, see -url")
+		return exitError
+	}
+	data, sig, err := fetchReleaseData(*url)
+	if err != nil {
+		slog.Error("fetching release data failed", "url", *url, "err", err)
+		return exitError
+	}
+	rd, _ := verifyReleaseData(data, sig)
+	if old := updatedReleaseData(); old != nil {
+		switch {
+		case rd.Generated.Equal(old.Generated):
+			fmt.Printf("release data is up to date: latest %s, %d releases\n", rd.Latest, len(rd.Releases))
+			return 0
+		case rd.Generated.Before(old.Generated):
+			slog.Error("refusing older release data", "generated", rd.Generated, "installed", old.Generated)
+			return exitError
+		}
+	}
+	name, err := releaseDataPath()
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(name), 0777)
+	}
+	// The signature goes first: until the data is replaced as well,
+	// the old data fails verification and is ignored.
+	if err == nil {
+		err = writeFileAtomic(name+".sig", sig)
+	}
+	if err == nil {
+		err = writeFileAtomic(name, data)
+	}
+	if err != nil {
+		slog.Error("writing release data failed", "err", err)
+		return exitError
+	}
+	fmt.Printf("release data updated: latest %s, %d releases\n", rd.Latest, len(rd.Releases))
+	return 0
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// latestGoRelease is the newest major Go release known to this version of
+// gover, the last one goReleaseDates lists. The toolchain gover was built
+// with and the release data downloaded by "gover update-db" are considered
+// as well.
+const latestGoRelease = "go1.25"
+
+// isEOL reports whether a Go release no longer receives security fixes.
+// Each major release is supported until two newer major releases exist.
+func isEOL(ver string) bool {
+	v, ok := parseGoVersion(ver)
+	if !ok {
+		return false
+	}
+	latest := latestRelease()
+	if v.major != latest.major {
+		return v.major < latest.major
+	}
+	return v.minor+2 <= latest.minor
+}
+
+// latestRelease returns the newest Go release known: latestGoRelease, the
+// toolchain gover was built with or the latest of the release data
+// downloaded by "gover update-db", whichever is newest.
+func latestRelease() goVersion {
+	latest, _ := parseGoVersion(latestGoRelease)
+	if rv, ok := parseGoVersion(runtime.Version()); ok && latest.less(rv) {
+		latest = rv
+	}
+	if rd := updatedReleaseData(); rd != nil {
+		if rv, ok := parseGoVersion(rd.Latest); ok && latest.less(rv) {
+			latest = rv
+		}
+	}
+	return latest
+}
+
+// goReleaseDates lists the release dates of Go releases, indexed by the
+// patch number, from go.dev/doc/devel/release. Point releases are listed
+// from Go 1.16 on. Release data downloaded by "gover update-db" takes
+// precedence.
+var goReleaseDates = map[string][]string{
+	"go1.0":  {"2012-03-28"},
+	"go1.1":  {"2013-05-13"},
+	"go1.2":  {"2013-12-01"},
+	"go1.3":  {"2014-06-18"},
+	"go1.4":  {"2014-12-10"},
+	"go1.5":  {"2015-08-19"},
+	"go1.6":  {"2016-02-17"},
+	"go1.7":  {"2016-08-15"},
+	"go1.8":  {"2017-02-16"},
+	"go1.9":  {"2017-08-24"},
+	"go1.10": {"2018-02-16"},
+	"go1.11": {"2018-08-24"},
+	"go1.12": {"2019-02-25"},
+	"go1.13": {"2019-09-03"},
+	"go1.14": {"2020-02-25"},
+	"go1.15": {"2020-08-11"},
+	"go1.16": {
+		"2021-02-16", "2021-03-10", "2021-03-11", "2021-04-01", "2021-05-06", "2021-06-03",
+		"2021-07-12", "2021-08-05", "2021-09-09", "2021-10-07", "2021-11-04", "2021-12-02",
+		"2021-12-09", "2022-01-06", "2022-02-10", "2022-03-03",
+	},
+	"go1.17": {
+		"2021-08-16", "2021-09-09", "2021-10-07", "2021-11-04", "2021-12-02", "2021-12-09",
+		"2022-01-06", "2022-02-10", "2022-03-03", "2022-04-12", "2022-05-10", "2022-06-01",
+		"2022-07-12", "2022-08-01",
+	},
+	"go1.18": {
+		"2022-03-15", "2022-04-12", "2022-05-10", "2022-06-01", "2022-07-12", "2022-08-01",
+		"2022-09-06", "2022-10-04", "2022-11-01", "2022-12-06", "2023-01-10",
+	},
+	"go1.19": {
+		"2022-08-02", "2022-09-06", "2022-10-04", "2022-11-01", "2022-12-06", "2023-01-10",
+		"2023-02-14", "2023-03-07", "2023-04-04", "2023-05-02", "2023-06-06", "2023-07-11",
+		"2023-08-01", "2023-09-06",
+	},
+	"go1.20": {
+		"2023-02-01", "2023-02-14", "2023-03-07", "2023-04-04", "2023-05-02", "2023-06-06",
+		"2023-07-11", "2023-08-01", "2023-09-06", "2023-10-05", "2023-10-10", "2023-11-07",
+		"2023-12-05", "2024-01-09", "2024-02-06",
+	},
+	"go1.21": {
+		"2023-08-08", "2023-09-06", "2023-10-05", "2023-10-10", "2023-11-07", "2023-12-05",
+		"2024-01-09", "2024-02-06", "2024-03-05", "2024-04-03", "2024-05-07", "2024-06-04",
+		"2024-07-02", "2024-08-06",
+	},
+	"go1.22": {
+		"2024-02-06", "2024-03-05", "2024-04-03", "2024-05-07", "2024-06-04", "2024-07-02",
+		"2024-08-06", "2024-09-05", "2024-10-01", "2024-11-06", "2024-12-03", "2025-01-16",
+		"2025-02-04",
+	},
+	"go1.23": {
+		"2024-08-13", "2024-09-05", "2024-10-01", "2024-11-06", "2024-12-03", "2025-01-16",
+		"2025-02-04", "2025-03-04", "2025-04-01", "2025-05-06", "2025-06-05", "2025-07-08",
+		"2025-08-06",
+	},
+	"go1.24": {
+		"2025-02-11", "2025-03-04", "2025-04-01", "2025-05-06", "2025-06-05", "2025-07-08",
+		"2025-08-06",
+	},
+	"go1.25": {"2025-08-12"},
+}
+
+// releaseDate returns the date the Go release ver was published, if it is
+// known. Pre-releases have no date.
+func releaseDate(ver string) (time.Time, bool) {
+	v, ok := parseGoVersion(ver)
+	if !ok || v.pre != 2 {
+		return time.Time{}, false
+	}
+	dates := releaseDates(v)
+	if v.patch >= len(dates) {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(time.DateOnly, dates[v.patch])
+	return t, err == nil
+}
+
+// releaseDates returns the release dates of the point releases of the Go
+// release of v, from the release data downloaded by "gover update-db" if
+// it lists them and from goReleaseDates otherwise.
+func releaseDates(v goVersion) []string {
+	rel := fmt.Sprintf("go%d.%d", v.major, v.minor)
+	if rd := updatedReleaseData(); rd != nil && len(rd.Releases[rel]) > 0 {
+		return rd.Releases[rel]
+	}
+	return goReleaseDates[rel]
+}
+
+// vulnerableFix returns the latest point release of the Go release of
+// ver if it is newer than ver, so that ver is a vulnerable toolchain
+// lacking the security fixes point releases are published for, or ""
+// if no newer one is known.
+func vulnerableFix(ver string) string {
+	v, ok := parseGoVersion(ver)
+	if !ok || v.pre != 2 {
+		return ""
+	}
+	n := len(releaseDates(v))
+	if v.patch+1 >= n {
+		return ""
+	}
+	return fmt.Sprintf("go%d.%d.%d", v.major, v.minor, n-1)
+}
+
+// monthsBetween returns the number of whole months from a to b.
+func monthsBetween(a, b time.Time) int {
+	n := (b.Year()-a.Year())*12 + int(b.Month()-a.Month())
+	if b.Day() < a.Day() {
+		n--
+	}
+	return n
+}
+
+// releaseAge describes when ver was released and how long ago, e.g.
+// "released 2023-04-04, 20 months old", or returns "" if it is unknown.
+func releaseAge(ver string, now time.Time) string {
+	t, ok := releaseDate(ver)
+	if !ok {
+		return ""
+	}
+	var age string
+	switch months := monthsBetween(t, now); {
+	case months >= 1:
+		age = plural(months, "month")
+	default:
+		age = plural(int(now.Sub(t).Hours()/24), "day")
+	}
+	return fmt.Sprintf("released %s, %s old", t.Format(time.DateOnly), age)
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// maxReleaseAge is the policy set with -max-age: releases published
+// longer ago violate it. The zero value disables the check.
+var maxReleaseAge ageLimit
+
+// ageLimit is a period of time in calendar units, parsed from flags like
+// -max-age 18m. It accepts a number of days (d), weeks (w), months (m) or
+// years (y).
+type ageLimit struct {
+	years, months, days int
+}
+
+func (a *ageLimit) String() string {
+	switch {
+	case a.years != 0:
+		return fmt.Sprintf("%dy", a.years)
+	case a.months != 0:
+		return fmt.Sprintf("%dm", a.months)
+	case a.days != 0 && a.days%7 == 0:
+		return fmt.Sprintf("%dw", a.days/7)
+	case a.days != 0:
+		return fmt.Sprintf("%dd", a.days)
+	}
+	return ""
+}
+
+func (a *ageLimit) Set(s string) error {
+	n, err := strconv.Atoi(s[:max(len(s)-1, 0)])
+	if err != nil || n <= 0 {
+		return fmt.Errorf("expected a positive number and a unit")
+	}
+	*a = ageLimit{}
+	switch s[len(s)-1] {
+	case 'd':
+		a.days = n
+	case 'w':
+		a.days = 7 * n
+	case 'm':
+		a.months = n
+	case 'y':
+		a.years = n
+	default:
+		return fmt.Errorf("unit must be d, w, m or y")
+	}
+	return nil
+}
+
+// isTooOld reports whether ver was released longer ago than the
+// -max-age policy allows. Releases of unknown date never are.
+func isTooOld(ver string) bool {
+	if maxReleaseAge == (ageLimit{}) {
+		return false
+	}
+	t, ok := releaseDate(ver)
+	if !ok {
+		return false
+	}
+	cutoff := time.Now().AddDate(-maxReleaseAge.years, -maxReleaseAge.months, -maxReleaseAge.days)
+	return t.Before(cutoff)
+}
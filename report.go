@@ -0,0 +1,540 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"debug/buildinfo"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// findArch returns the target architecture of the binary b, or "" if it
+// can't be determined or b is nil.
+func findArch(b *binaryFile) (arch string) {
+	defer func() {
+		if recover() != nil {
+			arch = ""
+		}
+	}()
+	if b == nil {
+		return ""
+	}
+	return b.Arch()
+}
+
+// fileDigest returns the digest of the file name as algo:hex, or "" if
+// it can't be read, as for results of remote files.
+func fileDigest(name, algo string) string {
+	var h hash.Hash
+	switch algo {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	}
+	sum, err := digestFile(name, h)
+	if err != nil {
+		return ""
+	}
+	return algo + ":" + sum
+}
+
+// goVersionModInfo formats the build info of the binary b like go version
+// -m does, without the Go version and the final newline. It returns "" if
+// b is nil or has no build info.
+func goVersionModInfo(b *binaryFile) string {
+	if b == nil {
+		return ""
+	}
+	bi, err := b.BuildInfo()
+	if err != nil {
+		return ""
+	}
+	mi := *bi
+	mi.GoVersion = ""
+	return strings.TrimSuffix(mi.String(), "\n")
+}
+
+// reporter prints scan results and remembers whether any of them failed.
+// If summary is non-nil it aggregates the results. If eol is set,
+// end-of-life releases are marked in the output. Policy violations are
+// posted to webhook, if set. Local files scanned through scanPaths are
+// recorded in db, if set; with changedOnly, files unchanged since they
+// were last recorded are skipped.
+//
+// If digest is set, results include the digest of the file computed with
+// that algorithm. With meta, they describe the format of the binary. With
+// linkVars, they include the variables set with -ldflags -X, with the
+// values the binary holds.
+//
+// If local is set, to the version of the installed go command, results
+// of binaries built with an older toolchain are marked outdated, and
+// counted in outdated.
+//
+// With age, results say when the Go release was published and how long
+// ago; results violating the -max-age policy always do, and make the scan
+// fail.
+//
+// If timings is non-nil, files scanned through scanPaths are timed: the
+// results say how long each took and how much was read, and timings sums
+// that up over all files.
+//
+// If compat is "go-version", results are printed exactly like go version
+// -m does, with none of the other additions. For the catalogFormats, they
+// are collected and written as one document of that format by flush.
+//
+// Results are printed with file names if names is set. With autoNames,
+// names are printed only if there is more than one result, like grep(1)
+// does for more than one file.
+//
+// With json or ndjson, results are written as a JSON array or as one JSON
+// object per line, and failed files produce results with the error as
+// well. If json is set or results are to be sorted or grouped, they are
+// collected and only written by flush. Results go to out, or to the
+// standard output if out is nil.
+type reporter struct {
+	out         io.Writer
+	color       bool
+	names       bool
+	autoNames   bool
+	count       int
+	pending     *scanResult
+	exit        int
+	summary     *summary
+	eol         bool
+	webhook     string
+	db          *resultDB
+	changedOnly bool
+	json        bool
+	ndjson      bool
+	skipErrors  bool // skip unreadable files found by walking
+	null        bool
+	progress    *progress
+	cache       *scanCache
+	timeout     time.Duration
+	digest      string // "", "sha256" or "sha512"
+	meta        bool
+	linkVars    bool
+	compat      string // "", "go-version" or one of catalogFormats
+	age         bool
+	local       string
+	outdated    int
+	stdin       string // the copy of the standard input, reported as stdinName
+	checkpoint  *scanCheckpoint
+	sortBy      string // "", "version" or "path"
+	groupBy     string // "", "version", "arch" or "dir"
+	timings     *timingTotals
+	results     []scanResult
+}
+
+func (r *reporter) stdout() io.Writer {
+	if r.out == nil {
+		return os.Stdout
+	}
+	return r.out
+}
+
+// structured reports whether results are written as JSON, in which case
+// failed files produce results too.
+func (r *reporter) structured() bool {
+	return r.json || r.ndjson
+}
+
+func (r *reporter) buffered() bool {
+	return r.json || r.sortBy != "" || r.groupBy != "" || r.catalog()
+}
+
+// catalog reports whether results are written as a document of one of
+// the catalogFormats.
+func (r *reporter) catalog() bool {
+	return catalogFormats[r.compat] != nil
+}
+
+func (r *reporter) report(name, ver string, err error) {
+	r.reportTimed(name, ver, err, nil, r.readFacts(name, nil, "", err))
+}
+
+// binaryFacts is what is reported about a binary besides its version,
+// read by readFacts.
+type binaryFacts struct {
+	arch       string
+	sharedLib  string
+	buildKind  string
+	appVersion string
+	digest     string
+	meta       *binaryMeta
+	buildInfo  *buildinfo.BuildInfo
+	modInfo    string
+	vars       map[string]interface{}
+	deps       []string
+}
+
+// readFacts reads what is reported about the binary file, whose scan
+// ended with err, besides the version, from b, the binary the scan
+// parsed, or if that is nil, from the file opened again; sum is its
+// SHA-256, if the scan computed it. It returns nil if the scan failed,
+// and only the facts that don't need parsing it for remote files, which
+// can't be opened. Scans through scanPaths read them in the worker, under
+// the -timeout, so that the reporter never has to open a file again. A
+// malformed binary that makes the readers panic has no facts.
+func (r *reporter) readFacts(file string, b *binaryFile, sum string, err error) (f *binaryFacts) {
+	if err != nil {
+		return nil
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			slog.Debug("reading binary failed", "file", file, "err", e)
+			f = nil
+		}
+	}()
+	f = &binaryFacts{}
+	if b == nil {
+		if b, _ = openBinary(file); b != nil {
+			defer b.Close()
+		}
+	}
+	if r.summary != nil || r.structured() || r.catalog() || r.groupBy == "arch" {
+		f.arch = findArch(b)
+	}
+	f.sharedLib = sharedLibOf(b, file)
+	f.buildKind = findBuildKind(b)
+	f.appVersion = findAppVersion(b)
+	switch {
+	case r.digest == "sha256" && sum != "":
+		f.digest = "sha256:" + sum
+	case r.digest != "":
+		f.digest = fileDigest(file, r.digest)
+	}
+	if r.meta {
+		f.meta = findBinaryMeta(b)
+	}
+	switch {
+	case r.catalog():
+		f.buildInfo = catalogBuildInfo(b)
+	case r.compat != "":
+		f.modInfo = goVersionModInfo(b)
+	}
+	if r.linkVars {
+		f.vars = linkVarValues(findLinkVars(b))
+	}
+	if len(extraVars) > 0 {
+		vars := readVars(b, extraVars)
+		if f.vars == nil {
+			f.vars = vars
+		}
+		for k, v := range vars {
+			f.vars[k] = v
+		}
+	}
+	if r.structured() || r.db != nil {
+		// The result store records them too.
+		f.deps = moduleDeps(b)
+	}
+	return f
+}
+
+// reportTimed is report for a scan that took t, if not nil, of a binary
+// with the facts f.
+func (r *reporter) reportTimed(name, ver string, err error, t *scanTiming, f *binaryFacts) {
+	if name == r.stdin {
+		name = stdinName
+	}
+	if f == nil {
+		f = &binaryFacts{}
+	}
+	if r.summary != nil {
+		r.summary.add(ver, f.arch, err)
+	}
+	if err != nil {
+		switch {
+		case isTimeout(err):
+			slog.Error("scan timed out", "file", name, "err", err)
+			r.exit = worseExit(r.exit, exitError)
+		case isNoVersion(err):
+			slog.Error("scan failed", "file", name, "err", err)
+			r.exit = worseExit(r.exit, exitNotGo)
+		default:
+			slog.Error("scan failed", "file", name, "err", err)
+			r.exit = worseExit(r.exit, exitError)
+		}
+		if r.structured() {
+			res := newScanResult(name, ver, err)
+			res.Timing = r.timing(t)
+			r.emit(res)
+		}
+		return
+	}
+	notifyPolicy(r.webhook, name, ver)
+	res := newScanResult(name, ver, nil)
+	res.Arch = f.arch
+	res.SharedLib = f.sharedLib
+	res.BuildKind = f.buildKind
+	res.AppVersion = f.appVersion
+	res.Digest = f.digest
+	res.Binary = f.meta
+	res.buildInfo = f.buildInfo
+	res.modInfo = f.modInfo
+	res.Vars = f.vars
+	if r.structured() {
+		res.Deps = f.deps
+	}
+	if r.local != "" {
+		v, ok := parseGoVersion(ver)
+		lv, lok := parseGoVersion(r.local)
+		if res.Outdated = ok && lok && v.less(lv); res.Outdated {
+			r.outdated++
+		}
+	}
+	res.Timing = r.timing(t)
+	if t != nil && inferredMethods[t.Method] {
+		res.Method, res.Estimate = t.Method, t.Estimate
+	}
+	if res.TooOld {
+		r.exit = worseExit(r.exit, exitViolation)
+	}
+	r.emit(res)
+}
+
+// timing returns t if scans are timed with -timing, and nil otherwise.
+func (r *reporter) timing(t *scanTiming) *scanTiming {
+	if r.timings == nil {
+		return nil
+	}
+	return t
+}
+
+// emit writes res, or collects it if results are buffered.
+func (r *reporter) emit(res scanResult) {
+	if r.buffered() {
+		r.results = append(r.results, res)
+		return
+	}
+	if r.ndjson {
+		r.writeNDJSON(res)
+		return
+	}
+	if r.autoNames {
+		// Hold back the first result until it is known whether
+		// there are more.
+		r.count++
+		switch r.count {
+		case 1:
+			r.pending = &res
+			return
+		case 2:
+			r.printResult(*r.pending, true)
+			r.pending = nil
+		}
+		r.printResult(res, true)
+		return
+	}
+	r.printResult(res, r.names)
+}
+
+// writeNDJSON writes res as a line of JSON.
+func (r *reporter) writeNDJSON(res scanResult) {
+	b, err := json.Marshal(res)
+	if err != nil {
+		slog.Error("encoding results failed", "err", err)
+		r.exit = worseExit(r.exit, exitError)
+		return
+	}
+	r.stdout().Write(append(b, '\n'))
+}
+
+func (r *reporter) printResult(res scanResult, name bool) {
+	ver := res.Version
+	if r.compat != "" {
+		fmt.Fprintf(r.stdout(), "%s: %s\n", res.File, ver)
+		if res.modInfo != "" {
+			fmt.Fprintf(r.stdout(), "\t%s\n", strings.ReplaceAll(res.modInfo, "\n", "\n\t"))
+		}
+		return
+	}
+	if r.null {
+		// Names and versions are always paired so that records can
+		// be split unambiguously.
+		fmt.Fprintf(r.stdout(), "%s\x00%s\x00", res.File, ver)
+		return
+	}
+	if r.eol && res.EndOfLife {
+		ver += " (end of life)"
+	}
+	if r.age || res.TooOld {
+		if age := releaseAge(res.Version, time.Now()); age != "" {
+			if res.TooOld {
+				age += ", exceeds max age"
+			}
+			ver += " (" + age + ")"
+		}
+	}
+	if res.Outdated {
+		ver += " (outdated)"
+	}
+	if r.color {
+		ver = colorize(ver, res.EndOfLife || res.TooOld)
+	}
+	switch {
+	case res.Estimate != nil:
+		ver += " (from " + res.Method + ": " + res.Estimate.String() + ")"
+	case res.Method != "":
+		ver += " (from " + res.Method + ")"
+	}
+	if res.AppVersion != "" {
+		ver += " (app " + res.AppVersion + ")"
+	}
+	if res.SharedLib != "" {
+		ver += " (shared-linked, " + res.SharedLib + ")"
+	}
+	if res.BuildKind != "" {
+		ver += " (" + res.BuildKind + " build)"
+	}
+	if res.Digest != "" {
+		ver += " " + res.Digest
+	}
+	if res.Vars != nil {
+		ver += " " + formatVars(res.Vars)
+	}
+	if res.Binary != nil {
+		ver += " (" + res.Binary.String() + ")"
+	}
+	if res.Timing != nil {
+		ver += " (" + res.Timing.String() + ")"
+	}
+	if name {
+		fmt.Fprintf(r.stdout(), "%s: %s\n", res.File, ver)
+	} else {
+		fmt.Fprintln(r.stdout(), ver)
+	}
+}
+
+// flush sorts, groups and writes the collected results.
+func (r *reporter) flush() {
+	if r.pending != nil {
+		r.printResult(*r.pending, false)
+		r.pending = nil
+	}
+	if !r.buffered() {
+		return
+	}
+	names := r.names || r.autoNames && len(r.results) > 1
+	switch r.sortBy {
+	case "version":
+		sort.SliceStable(r.results, func(i, j int) bool {
+			if c := compareGoVersions(r.results[i].Version, r.results[j].Version); c != 0 {
+				return c < 0
+			}
+			return r.results[i].File < r.results[j].File
+		})
+	case "path":
+		sort.SliceStable(r.results, func(i, j int) bool {
+			return r.results[i].File < r.results[j].File
+		})
+	}
+
+	var groups []string
+	members := make(map[string][]scanResult)
+	for _, res := range r.results {
+		g := r.groupOf(res)
+		if _, ok := members[g]; !ok {
+			groups = append(groups, g)
+		}
+		members[g] = append(members[g], res)
+	}
+	switch r.groupBy {
+	case "version":
+		sort.SliceStable(groups, func(i, j int) bool {
+			return compareGoVersions(groups[i], groups[j]) > 0
+		})
+	case "arch", "dir":
+		sort.Strings(groups)
+	}
+
+	if r.ndjson {
+		for _, res := range r.results {
+			r.writeNDJSON(res)
+		}
+		return
+	}
+	if r.catalog() {
+		r.writeCatalog()
+		return
+	}
+	if r.json {
+		var v interface{} = r.results
+		if r.groupBy != "" {
+			v = members
+		} else if r.results == nil {
+			v = []scanResult{}
+		}
+		b, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			slog.Error("encoding results failed", "err", err)
+			r.exit = worseExit(r.exit, exitError)
+			return
+		}
+		r.stdout().Write(append(b, '\n'))
+		return
+	}
+
+	if r.groupBy == "" {
+		for _, res := range r.results {
+			r.printResult(res, names)
+		}
+		return
+	}
+	for i, g := range groups {
+		if i > 0 {
+			fmt.Fprintln(r.stdout())
+		}
+		title := g
+		if r.groupBy == "version" {
+			if r.eol && isEOL(g) {
+				title += " (end of life)"
+			}
+			if r.color {
+				title = colorize(title, isEOL(g))
+			}
+		}
+		fmt.Fprintf(r.stdout(), "%s:\n", title)
+		for _, res := range members[g] {
+			fmt.Fprint(r.stdout(), "  ")
+			switch r.groupBy {
+			case "version":
+				fmt.Fprintln(r.stdout(), res.File)
+			case "dir":
+				res.File = filepath.Base(res.File)
+				r.printResult(res, true)
+			default:
+				r.printResult(res, true)
+			}
+		}
+	}
+}
+
+// groupOf returns the group res belongs to under r.groupBy.
+func (r *reporter) groupOf(res scanResult) string {
+	switch r.groupBy {
+	case "version":
+		if res.Error != "" {
+			return "error"
+		}
+		return res.Version
+	case "arch":
+		if res.Arch == "" {
+			return "unknown"
+		}
+		return res.Arch
+	case "dir":
+		return filepath.Dir(res.File)
+	}
+	return ""
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// cdxComponent is a component of a CycloneDX SBOM.
+type cdxComponent struct {
+	Type    string `json:"type"`
+	BOMRef  string `json:"bom-ref,omitempty"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	PURL    string `json:"purl,omitempty"`
+}
+
+// cdxBOM is the subset of a CycloneDX 1.5 JSON document written by
+// "gover sbom". It is read back by loadModules.
+type cdxBOM struct {
+	BOMFormat   string `json:"bomFormat"`
+	SpecVersion string `json:"specVersion"`
+	Version     int    `json:"version"`
+	Metadata    struct {
+		Timestamp string `json:"timestamp"`
+		Tools     struct {
+			Components []cdxComponent `json:"components"`
+		} `json:"tools"`
+		Component cdxComponent `json:"component"`
+	} `json:"metadata"`
+	Components []cdxComponent `json:"components"`
+}
+
+// golangPURL returns the package URL of a Go module version; the inverse
+// of parseGolangPURL.
+func golangPURL(path, version string) string {
+	elems := strings.Split(path, "/")
+	for i, e := range elems {
+		elems[i] = escapePURL(e)
+	}
+	return "pkg:golang/" + strings.Join(elems, "/") + "@" + escapePURL(version)
+}
+
+func escapePURL(s string) string {
+	return strings.NewReplacer("%", "%25", "@", "%40", "?", "%3F", "#", "%23", " ", "%20").Replace(s)
+}
+
+// sbomMain implements "gover sbom": it prints a CycloneDX SBOM of the
+// modules and standard library compiled into a binary.
+func sbomMain(args []string) int {
+	fs := flag.NewFlagSet("sbom", flag.ExitOnError)
+	fs.Usage = usage
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	files := parseArgs(fs, args)
+	setup()
+	if len(files) != 1 {
+		usage()
+	}
+
+	bi, err := readBuildInfo(files[0])
+	if err != nil {
+		slog.Error("reading build info failed", "file", files[0], "err", err)
+		return readExit(err)
+	}
+	var bom cdxBOM
+	bom.BOMFormat = "CycloneDX"
+	bom.SpecVersion = "1.5"
+	bom.Version = 1
+	bom.Metadata.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	bom.Metadata.Tools.Components = []cdxComponent{{Type: "application", Name: "gover"}}
+	app := cdxComponent{Type: "application", Name: filepath.Base(files[0])}
+	if bi.Main.Path != "" {
+		app.Name = bi.Main.Path
+		// Binaries built from a checkout have no module version.
+		if bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			app.Version = bi.Main.Version
+			app.PURL = golangPURL(bi.Main.Path, bi.Main.Version)
+			app.BOMRef = app.PURL
+		}
+	}
+	bom.Metadata.Component = app
+
+	// The standard library is listed like other tools do, with the
+	// version lacking its "go" prefix.
+	std := strings.TrimPrefix(bi.GoVersion, "go")
+	if i := strings.IndexAny(std, " "); i >= 0 {
+		std = std[:i]
+	}
+	bom.Components = []cdxComponent{{
+		Type:    "library",
+		BOMRef:  golangPURL("stdlib", std),
+		Name:    "stdlib",
+		Version: std,
+		PURL:    golangPURL("stdlib", std),
+	}}
+	for _, m := range bi.Deps {
+		path, ver := m.Path, depVersion(m)
+		if m.Replace != nil && m.Replace.Version != "" {
+			path = m.Replace.Path
+		}
+		purl := golangPURL(path, ver)
+		bom.Components = append(bom.Components, cdxComponent{
+			Type:    "library",
+			BOMRef:  purl,
+			Name:    path,
+			Version: ver,
+			PURL:    purl,
+		})
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(bom); err != nil {
+		slog.Error("writing SBOM failed", "err", err)
+		return exitError
+	}
+	return 0
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// loadModules returns the module versions contained in file, which can be
+// a Go binary, a CycloneDX or SPDX JSON SBOM, or a gover -json report.
+func loadModules(file string) (map[string]string, error) {
+	data, err := ioutil.ReadFile(longPath(file))
+	if err != nil {
+		return nil, err
+	}
+	mods := make(map[string]string)
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		bi, err := readBuildInfo(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range bi.Deps {
+			mods[m.Path] = depVersion(m)
+		}
+		return mods, nil
+	}
+
+	if trimmed[0] == '[' {
+		var results []scanResult
+		if err := json.Unmarshal(trimmed, &results); err != nil {
+			return nil, fmt.Errorf("%s: %s", file, err)
+		}
+		for _, r := range results {
+			for _, d := range r.Deps {
+				if i := strings.LastIndex(d, "@"); i > 0 {
+					mods[d[:i]] = d[i+1:]
+				}
+			}
+		}
+		return mods, nil
+	}
+
+	var doc struct {
+		BOMFormat  string `json:"bomFormat"`
+		Components []struct {
+			Name    string `json:"name"`
+			Version string `json:"version"`
+			PURL    string `json:"purl"`
+		} `json:"components"`
+		SPDXVersion string `json:"spdxVersion"`
+		Packages    []struct {
+			Name         string `json:"name"`
+			VersionInfo  string `json:"versionInfo"`
+			ExternalRefs []struct {
+				Type    string `json:"referenceType"`
+				Locator string `json:"referenceLocator"`
+			} `json:"externalRefs"`
+		} `json:"packages"`
+	}
+	if err := json.Unmarshal(trimmed, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	switch {
+	case doc.BOMFormat == "CycloneDX":
+		for _, c := range doc.Components {
+			if path, ver, ok := parseGolangPURL(c.PURL); ok {
+				mods[path] = ver
+			} else if c.PURL == "" && c.Name != "" {
+				mods[c.Name] = c.Version
+			}
+		}
+	case doc.SPDXVersion != "":
+		for _, p := range doc.Packages {
+			found := false
+			for _, ref := range p.ExternalRefs {
+				if path, ver, ok := parseGolangPURL(ref.Locator); ref.Type == "purl" && ok {
+					mods[path] = ver
+					found = true
+				}
+			}
+			if !found && len(p.ExternalRefs) == 0 && p.Name != "" {
+				mods[p.Name] = p.VersionInfo
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%s: unknown SBOM format", file)
+	}
+	return mods, nil
+}
+
+// parseGolangPURL splits a package URL like
+// pkg:golang/github.com/foo/bar@v1.2.3 into module path and version.
+func parseGolangPURL(purl string) (path, version string, ok bool) {
+	if !strings.HasPrefix(purl, "pkg:golang/") {
+		return "", "", false
+	}
+	s := purl[len("pkg:golang/"):]
+	if i := strings.IndexAny(s, "?#"); i >= 0 {
+		s = s[:i]
+	}
+	i := strings.LastIndex(s, "@")
+	if i < 0 {
+		return "", "", false
+	}
+	path, err := url.PathUnescape(s[:i])
+	if err != nil {
+		return "", "", false
+	}
+	version, err = url.PathUnescape(s[i+1:])
+	if err != nil {
+		return "", "", false
+	}
+	return path, version, true
+}
+
+// sbomDiffMain implements "gover sbom-diff", which lists the modules
+// added, removed, upgraded or downgraded between two binaries or SBOMs.
+// It exits 0 if there are no differences and 3 if there are, as for a
+// policy that nothing changes.
+func sbomDiffMain(args []string) int {
+	fs := flag.NewFlagSet("sbom-diff", flag.ExitOnError)
+	fs.Usage = usage
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	files := parseArgs(fs, args)
+	setup()
+	if len(files) != 2 {
+		usage()
+	}
+
+	a, err := loadModules(files[0])
+	if err != nil {
+		slog.Error("reading modules failed", "file", files[0], "err", err)
+		return exitError
+	}
+	b, err := loadModules(files[1])
+	if err != nil {
+		slog.Error("reading modules failed", "file", files[1], "err", err)
+		return exitError
+	}
+
+	mods := make([]string, 0, len(a)+len(b))
+	for m := range a {
+		mods = append(mods, m)
+	}
+	for m := range b {
+		if _, ok := a[m]; !ok {
+			mods = append(mods, m)
+		}
+	}
+	sort.Strings(mods)
+
+	exit := 0
+	for _, m := range mods {
+		va, oka := a[m]
+		vb, okb := b[m]
+		switch {
+		case !oka:
+			fmt.Printf("added      %s %s\n", m, vb)
+		case !okb:
+			fmt.Printf("removed    %s %s\n", m, va)
+		case va != vb:
+			kind := "changed   "
+			if c, ok := compareSemver(va, vb); ok && c < 0 {
+				kind = "upgraded  "
+			} else if ok && c > 0 {
+				kind = "downgraded"
+			}
+			fmt.Printf("%s %s %s -> %s\n", kind, m, va, vb)
+		default:
+			continue
+		}
+		exit = exitViolation
+	}
+	return exit
+}
@@ -0,0 +1,546 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// scanMain implements "gover scan", which is also run if no subcommand
+// is given.
+func scanMain(args []string) int {
+	fs := flag.NewFlagSet("scan", flag.ExitOnError)
+	fs.Usage = usage
+	recursive := fs.Bool("r", false, "scan directories recursively")
+	path := fs.Bool("path", false, "scan the executables on $PATH")
+	gobin := fs.Bool("gobin", false, "scan tools installed by go install")
+	apps := fs.Bool("apps", false, "scan the macOS application bundles in /Applications and ~/Applications, or in the directories given, by application")
+	presetList := fs.String("preset", "", "scan where package managers install binaries: comma-separated `names` of homebrew, flatpak, snap or nix")
+	watchDirs := fs.Bool("watch", false, "watch directories for new or modified binaries")
+	interval := fs.Duration("interval", 2*time.Second, "poll interval for -watch")
+	webhook := fs.String("webhook", "", "post policy violations found by -watch to this URL")
+	dbName := fs.String("db", "", "record results in the result store `file`")
+	checkpointName := fs.String("checkpoint", "", "record progress in `file` and resume from it if the scan was interrupted")
+	changedOnly := fs.Bool("changed-only", false, "skip files unchanged since the last scan recorded in -db")
+	jsonOut := fs.Bool("json", false, "print results as JSON")
+	ndjsonOut := fs.Bool("ndjson", false, "print results as JSON, one object per line as they are found")
+	summarize := fs.Bool("summary", false, "print aggregate counts after the results")
+	sortBy := fs.String("sort", "", "sort results by `version` or path")
+	groupBy := fs.String("group-by", "", "group results by `version`, arch or dir")
+	jobs := fs.Int("j", runtime.NumCPU(), "number of files to scan concurrently")
+	withNames := fs.Bool("H", false, "always print file names")
+	noNames := fs.Bool("h", false, "never print file names")
+	output := fs.String("o", "", "write results to `file`, replacing it atomically once complete")
+	fs.Uint64Var(&maxStringLen, "max-string", maxStringLen, "refuse to read strings longer than `n` bytes from a binary")
+	addLimitFlags(fs)
+	addPluginFlags(fs)
+	timeout := fs.Duration("timeout", 0, "give up on files that take longer than this to scan")
+	fs.BoolVar(&strictMode, "strict", false, "only accept versions read from the binary, failing for those inferred from the DWARF producer or a runtime fingerprint")
+	noCache := fs.Bool("no-cache", false, "scan every file even if identical contents were scanned before")
+	timing := fs.Bool("timing", false, "report the time each file and strategy took and the bytes read, and the totals at the end")
+	var progressFlag progressMode
+	fs.Var(&progressFlag, "progress", "report progress on stderr as a status line, or as JSON events with -progress=json")
+	null := fs.Bool("null", false, "terminate file names and versions with NUL instead of \": \" and newline")
+	noIgnore := fs.Bool("no-ignore", false, "don't skip the paths listed in "+ignoreFile+" files when scanning recursively")
+	fs.BoolVar(&oneFileSystem, "one-file-system", false, "don't descend into other file systems when scanning recursively, like find -xdev")
+	onError := fs.String("on-error", "fail", "`skip` files that can't be read when scanning recursively, or fail")
+	colorMode := addColorFlag(fs)
+	applyConfig := addConfigFlag(fs)
+	digest := fs.String("digest", "", "include the `sha256` or sha512 digest of each file in the results")
+	meta := fs.Bool("meta", false, "describe the format of each binary in the results")
+	againstLocal := fs.Bool("against-local", false, "mark binaries built with a toolchain older than the installed go command, to be reinstalled after upgrading Go")
+	age := fs.Bool("age", false, "annotate results with the release date and age of the Go version")
+	fs.Var(&maxReleaseAge, "max-age", "fail for Go releases published longer ago than `age`, given in days, weeks, months or years as in 18m")
+	compat := fs.String("compat", "", "print results in the format of another tool: `go-version` for go version -m, syft or trivy for their JSON output")
+	schema := fs.Bool("schema", false, "print the JSON Schema of the -json and -ndjson results and exit")
+	var vars varsFlag
+	fs.Var(&vars, "var", "also print the value of the global variable `name`; may be repeated")
+	appVersion := fs.Bool("app-version", false, "also print the version of the application, looked up in the variables it is conventionally stamped in")
+	linkVars := fs.Bool("link-vars", false, "also print the variables set with -ldflags -X and their values in the binary")
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	files := parseArgs(fs, args)
+	setup()
+	if *schema {
+		if err := writeResultSchema(os.Stdout); err != nil {
+			slog.Error("writing schema failed", "err", err)
+			return exitError
+		}
+		return exitOK
+	}
+	extraVars = vars
+	applyConfig()
+	if *appVersion && appVersionVars == nil {
+		appVersionVars = defaultAppVersionVars
+	}
+	useIgnoreFiles = !*noIgnore
+	if *presetList != "" {
+		dirs, err := applyPresets(*presetList)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "gover: invalid -preset: %v\n", err)
+			usage()
+		}
+		if len(dirs) == 0 {
+			slog.Error("no preset directories found", "preset", *presetList)
+			return exitError
+		}
+		files = append(files, dirs...)
+	}
+	if *watchDirs {
+		if len(files) < 1 {
+			usage()
+		}
+		slog.Info("watching", "dirs", files, "interval", *interval)
+		r := &reporter{names: true, eol: true, webhook: *webhook}
+		r.color = useColor(*colorMode, r.stdout())
+		watch(files, *recursive, *interval, r)
+	}
+	if *gobin {
+		gobinScanDirs = make(map[string]bool)
+		for _, dir := range gobinDirs() {
+			gobinScanDirs[dir] = true
+			files = append(files, dir)
+		}
+	}
+	if *apps {
+		dirs := files
+		if len(dirs) == 0 {
+			dirs = applicationDirs()
+		}
+		r := &reporter{names: true, eol: true}
+		r.color = !*jsonOut && useColor(*colorMode, r.stdout())
+		return reportApps(r, appBundles(dirs), *jsonOut, isBundleBinary)
+	}
+	// The output is created once everything else is set up, so that
+	// nothing fails with it half written.
+	var out *atomicFile
+	createOutput := func(r *reporter) error {
+		if *output == "" {
+			return nil
+		}
+		f, err := createAtomic(*output)
+		if err != nil {
+			return err
+		}
+		out = f
+		r.out = out
+		// Never leave a partial report behind when interrupted.
+		onInterrupt(out.Abort)
+		return nil
+	}
+	finish := func(r *reporter) int {
+		if out != nil {
+			if err := out.Commit(); err != nil {
+				slog.Error("writing output failed", "err", err)
+				out.Abort()
+				r.exit = worseExit(r.exit, exitError)
+			}
+		}
+		return r.exit
+	}
+
+	if *path {
+		r := &reporter{names: true, summary: newSummary()}
+		if err := createOutput(r); err != nil {
+			slog.Error("creating output failed", "err", err)
+			return exitError
+		}
+		r.color = useColor(*colorMode, r.stdout())
+		for _, f := range append(files, pathExecutables()...) {
+			ver, err := findVersion(f)
+			if isNoVersion(err) {
+				continue
+			}
+			r.report(f, ver, err)
+		}
+		fmt.Fprintln(r.stdout())
+		r.summary.print(r.stdout())
+		return finish(r)
+	}
+	if len(files) < 1 {
+		usage()
+	}
+
+	checkFlagValue("sort", *sortBy)
+	checkFlagValue("group-by", *groupBy)
+	checkFlagValue("digest", *digest)
+	checkFlagValue("on-error", *onError)
+	checkFlagValue("compat", *compat)
+	if *compat != "" && (*jsonOut || *ndjsonOut || *null || *groupBy != "") {
+		fmt.Fprintf(os.Stderr, "gover: -compat cannot be combined with -json, -ndjson, -null or -group-by\n")
+		usage()
+	}
+	if *null && (*jsonOut || *ndjsonOut || *groupBy != "") {
+		fmt.Fprintf(os.Stderr, "gover: -null cannot be combined with -json, -ndjson or -group-by\n")
+		usage()
+	}
+	if *ndjsonOut && (*jsonOut || *groupBy != "") {
+		fmt.Fprintf(os.Stderr, "gover: -ndjson cannot be combined with -json or -group-by\n")
+		usage()
+	}
+	if *changedOnly && *dbName == "" {
+		fmt.Fprintf(os.Stderr, "gover: -changed-only requires -db\n")
+		usage()
+	}
+	r := &reporter{
+		names:       *withNames,
+		autoNames:   !*withNames && !*noNames,
+		changedOnly: *changedOnly,
+		json:        *jsonOut,
+		ndjson:      *ndjsonOut,
+		null:        *null,
+		timeout:     *timeout,
+		sortBy:      *sortBy,
+		groupBy:     *groupBy,
+		digest:      *digest,
+		meta:        *meta,
+		linkVars:    *linkVars,
+		compat:      *compat,
+		age:         *age,
+		skipErrors:  *onError == "skip",
+	}
+	if *summarize {
+		r.summary = newSummary()
+	}
+	if *timing {
+		r.timings = newTimingTotals()
+	}
+	if *againstLocal || *gobin {
+		local, err := localGoVersion()
+		switch {
+		case err == nil:
+			r.local = local
+		case *againstLocal:
+			slog.Error("finding the installed toolchain failed", "err", err)
+			return exitError
+		default:
+			// The tools are still listed, just not compared.
+			slog.Warn("finding the installed toolchain failed", "err", err)
+		}
+	}
+	if *dbName != "" {
+		db, err := openResultDB(*dbName)
+		if err != nil {
+			slog.Error("opening result store failed", "err", err)
+			return exitError
+		}
+		r.db = db
+	}
+	if !*noCache {
+		name, err := defaultCachePath()
+		if err == nil {
+			r.cache, err = openScanCache(name)
+		}
+		if err != nil {
+			slog.Warn("opening scan cache failed", "err", err)
+		}
+	}
+	closeStores := func() {
+		if r.db != nil {
+			r.db.Close()
+		}
+		r.cache.Close()
+	}
+	if *checkpointName != "" {
+		c, err := openCheckpoint(*checkpointName)
+		if err != nil {
+			slog.Error("opening checkpoint failed", "err", err)
+			closeStores()
+			return exitError
+		}
+		r.checkpoint = c
+	}
+	files, stdin, removeStdin, err := spillStdin(files)
+	if err != nil {
+		slog.Error("reading standard input failed", "err", err)
+		closeStores()
+		return exitError
+	}
+	defer removeStdin()
+	r.stdin = stdin
+	if err := createOutput(r); err != nil {
+		slog.Error("creating output failed", "err", err)
+		closeStores()
+		return exitError
+	}
+	r.color = !r.structured() && !r.null && useColor(*colorMode, r.stdout())
+	if progressFlag != "" {
+		r.progress = startProgress(string(progressFlag))
+	}
+	r.scanPaths(files, *recursive, *jobs)
+	if r.progress != nil {
+		r.progress.finish()
+	}
+	r.flush()
+	r.checkpoint.finish()
+	if r.summary != nil {
+		// Keep JSON and NUL-separated output parseable.
+		w := r.stdout()
+		if r.structured() || r.null {
+			w = os.Stderr
+		} else {
+			fmt.Fprintln(w)
+		}
+		r.summary.print(w)
+	}
+	if r.timings != nil {
+		r.timings.print(os.Stderr)
+	}
+	if r.outdated > 0 {
+		binaries := "binaries"
+		if r.outdated == 1 {
+			binaries = "binary"
+		}
+		fmt.Fprintf(os.Stderr, "%d %s built with a toolchain older than %s, rebuild with go install\n", r.outdated, binaries, r.local)
+	}
+	closeStores()
+	return finish(r)
+}
+
+// specialFileModes are the file types gover never reads: devices, FIFOs
+// and sockets.
+const specialFileModes = os.ModeDevice | os.ModeCharDevice | os.ModeNamedPipe | os.ModeSocket
+
+var errSpecialFile = errors.New("not a regular file")
+
+// scanJob is a local file queued for scanning.
+type scanJob struct {
+	path string
+	// quiet suppresses the result if the file has no Go version.
+	quiet bool
+	done  chan struct{}
+
+	ver    string
+	err    error
+	skip   bool
+	timing *scanTiming
+	facts  *binaryFacts
+	// sha256 is the SHA-256 of the file, if the scan computed it.
+	sha256 string
+	// abandoned is closed when the scan that timed out ends.
+	abandoned <-chan struct{}
+}
+
+// scanPaths scans files, descending into directories if recursive is set
+// and into preset directories always, with up to jobs files scanned
+// concurrently. Results are reported in the order the files are given
+// and walked, which is lexical within a directory, regardless of which
+// scan finishes first. Consecutive runs over the same files therefore
+// produce identical output.
+func (r *reporter) scanPaths(files []string, recursive bool, jobs int) {
+	if jobs < 1 {
+		jobs = 1
+	}
+	queue := make(chan *scanJob, jobs)
+	order := make(chan *scanJob, 4*jobs)
+
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range queue {
+				r.scanJob(j)
+				close(j.done)
+				if j.abandoned != nil {
+					// The scan that timed out keeps the
+					// slot, so that at most jobs run.
+					<-j.abandoned
+				}
+			}
+		}()
+	}
+
+	go func() {
+		add := func(j *scanJob) {
+			j.done = make(chan struct{})
+			order <- j
+			if j.err != nil {
+				close(j.done)
+				return
+			}
+			queue <- j
+		}
+		for _, f := range files {
+			if gobinScanDirs[f] {
+				gobinTools(f, func(path string, err error) {
+					add(&scanJob{path: path, err: err, quiet: err == nil})
+				})
+				continue
+			}
+			if !recursive && !isPresetDir(f) {
+				add(&scanJob{path: f})
+				continue
+			}
+			walkFiles(f, func(path string, fi os.FileInfo, err error) {
+				switch {
+				case err != nil && r.skipErrors:
+					slog.Warn("skipped", "file", path, "err", err)
+				case err != nil:
+					add(&scanJob{path: path, err: err})
+				case fi.Mode().IsRegular():
+					add(&scanJob{path: path, quiet: true})
+				case fi.Mode()&specialFileModes != 0:
+					slog.Debug("skipped special file", "file", path, "mode", fi.Mode())
+				}
+			})
+		}
+		close(queue)
+		close(order)
+	}()
+
+	for j := range order {
+		<-j.done
+		report := func() {
+			if !j.skip {
+				r.reportTimed(j.path, j.ver, j.err, j.timing, j.facts)
+			}
+		}
+		if r.progress == nil {
+			report()
+			continue
+		}
+		r.progress.update(!j.skip && j.err == nil, !j.skip && j.err != nil, report)
+	}
+	wg.Wait()
+}
+
+// scanJob scans j.path and records the result. It is called concurrently.
+func (r *reporter) scanJob(j *scanJob) {
+	if r.db != nil && r.changedOnly && j.path != r.stdin {
+		unchanged, err := r.db.unchanged(j.path)
+		if err != nil {
+			slog.Warn("reading result store failed", "err", err)
+		}
+		if unchanged {
+			slog.Debug("unchanged", "file", j.path)
+			j.skip = true
+			return
+		}
+	}
+	fi, err := os.Stat(longPath(j.path))
+	if err == nil && fi.Mode()&specialFileModes != 0 {
+		// Reading a FIFO or device could block forever.
+		j.err = errSpecialFile
+		return
+	}
+	if err == nil && fi.Size() > maxFileSize {
+		if j.quiet {
+			slog.Warn("skipped large file", "file", j.path, "size", fi.Size())
+			j.skip = true
+		} else {
+			j.err = errTooLarge
+		}
+		return
+	}
+	// Every scan is timed, to know the method of inferred versions,
+	// but reported with -timing only.
+	t := &scanTiming{}
+	var size int64
+	if err == nil {
+		size = fi.Size()
+	}
+	find := func() (string, *binaryFile, string, error) { return r.cache.openVersion(j.path, t) }
+	e, checkpointed := r.checkpoint.lookup(j.path, fi)
+	if checkpointed {
+		find = func() (string, *binaryFile, string, error) {
+			ver, err := e.result(t)
+			return ver, nil, "", err
+		}
+	}
+	var facts *binaryFacts
+	var sum string
+	j.ver, j.abandoned, j.err = withTimeout(r.timeout, func() (string, error) {
+		// Taken inside, so that a scan that times out keeps its part
+		// of the budget until it actually ends.
+		defer scanBudget().acquire(size)()
+		ver, b, s, err := find()
+		if b != nil {
+			defer b.Close()
+		}
+		facts, sum = r.readFacts(j.path, b, s, err), s
+		return ver, err
+	})
+	if !checkpointed && !isTimeout(j.err) && j.path != r.stdin {
+		r.checkpoint.record(j.path, fi, j.ver, j.err, t)
+	}
+	if !isTimeout(j.err) {
+		// An abandoned scan may still be writing to t, facts and
+		// sum.
+		if r.timings != nil {
+			r.timings.add(t)
+		}
+		j.timing = t
+		j.facts = facts
+		j.sha256 = sum
+	}
+	if r.db != nil && j.path != r.stdin {
+		// The standard input is gone with the next run.
+		var deps []string
+		if j.facts != nil {
+			deps = j.facts.deps
+		}
+		r.db.record(j.path, j.ver, j.err, j.sha256, deps)
+	}
+	if j.quiet && isNoVersion(j.err) {
+		slog.Debug("skipped", "file", j.path, "err", j.err)
+		j.skip = true
+	}
+	var pe *fs.PathError
+	if j.quiet && r.skipErrors && errors.As(j.err, &pe) {
+		// Files that vanished or can't be opened.
+		slog.Warn("skipped", "file", j.path, "err", j.err)
+		j.skip = true
+	}
+}
+
+// timeoutError reports a file whose scan took longer than the -timeout.
+type timeoutError struct {
+	d time.Duration
+}
+
+func (e timeoutError) Error() string {
+	return fmt.Sprintf("scan timed out after %v", e.d)
+}
+
+func isTimeout(err error) bool {
+	_, ok := err.(timeoutError)
+	return ok
+}
+
+// withTimeout returns the result of find, or a timeoutError if it takes
+// longer than d. The scanning code can't be interrupted, so a scan that
+// times out is abandoned and keeps running in the background until it
+// completes, which closes abandoned; callers wait for it before starting
+// another, so that abandoned scans don't pile up. A d of 0 means no
+// timeout.
+func withTimeout(d time.Duration, find func() (string, error)) (ver string, abandoned <-chan struct{}, err error) {
+	if d <= 0 {
+		ver, err = find()
+		return ver, nil, err
+	}
+	type result struct {
+		ver string
+		err error
+	}
+	c := make(chan result, 1)
+	ended := make(chan struct{})
+	go func() {
+		defer close(ended)
+		ver, err := find()
+		c <- result{ver, err}
+	}()
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case res := <-c:
+		return res.ver, nil, res.err
+	case <-t.C:
+		return "", ended, timeoutError{d}
+	}
+}
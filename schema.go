@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// resultSchemaVersion is the version of the format of JSON scan results,
+// reported in their schemaVersion field. It is incremented for changes
+// that break consumers, like removed or retyped fields; fields are added
+// without one.
+const resultSchemaVersion = 1
+
+// jsonSchemaOf returns the JSON Schema of the values of type t as
+// encoding/json writes them. Fields are required unless they are
+// omitted when empty.
+func jsonSchemaOf(t reflect.Type) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return jsonSchemaOf(t.Elem())
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchemaOf(t.Elem())}
+	case reflect.Struct:
+		props := make(map[string]interface{})
+		required := []string{}
+		addStructFields(t, props, &required)
+		return map[string]interface{}{"type": "object", "properties": props, "required": required}
+	}
+	// Interfaces hold any value.
+	return map[string]interface{}{}
+}
+
+// addStructFields adds the schemas of the fields of the struct type t to
+// props, and the names of those that are always written to required.
+// The fields of embedded structs are promoted, as encoding/json does.
+func addStructFields(t reflect.Type, props map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			addStructFields(f.Type, props, required)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = jsonSchemaOf(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// writeResultSchema writes the JSON Schema of the scan results written by
+// -json, as the elements of its array, and -ndjson, one per line.
+func writeResultSchema(w io.Writer) error {
+	schema := jsonSchemaOf(reflect.TypeOf(scanResult{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "gover scan result"
+	schema["description"] = "A result of gover scan -json or -ndjson."
+	schema["properties"].(map[string]interface{})["schemaVersion"] = map[string]interface{}{"const": resultSchemaVersion}
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+)
+
+// unmappedError reports a read from addresses a binary maps no file data
+// to, either because no section covers them or because the section that
+// does has no data for them in the file, like .bss or a section cut off
+// by a truncated file.
+type unmappedError struct {
+	Addr   uint64
+	Len    int
+	Reason string
+}
+
+func (e *unmappedError) Error() string {
+	return fmt.Sprintf("addr range %#x+%d not mapped: %s", e.Addr, e.Len, e.Reason)
+}
+
+// addrRange is the part of the address space a section or segment is
+// loaded to: the addresses from start to end, the first fileSize bytes of
+// which are read from the file at off.
+type addrRange struct {
+	name       string
+	start, end uint64
+	off        int64
+	fileSize   uint64
+}
+
+// addrMap maps virtual addresses of a binary to the file it is read from,
+// r, as ReadAtVaddr does. The ranges are validated when the map is built,
+// so corrupt or crafted section headers can't make reads go outside of
+// the sections' data: ranges that wrap around the address space are
+// dropped, and data past the end of the file is cut off.
+//
+// Sections can overlap, in corrupt binaries and in some produced by
+// tools that rewrite them. A read is served by the first section, in the
+// order of the section headers, that it lies in entirely, so the same
+// binary always reads the same way.
+//
+// Binaries whose section headers were stripped, as packers do, are mapped
+// by the segments the sections would be loaded in instead: ELF program
+// headers and Mach-O segment load commands. Binaries with sections are
+// not, as the segments also map headers and padding, where pointers left
+// to be relocated, which read as 0, would find data that is none.
+type addrMap struct {
+	r      io.ReaderAt
+	ranges []addrRange
+}
+
+// newAddrMap validates ranges, in the order they serve reads in, for the
+// file r.
+func newAddrMap(r io.ReaderAt, ranges []addrRange) *addrMap {
+	size := readerSize(r)
+	m := &addrMap{r: r}
+	for _, rg := range ranges {
+		if rg.end < rg.start {
+			slog.Debug("dropping section with invalid address range", "section", rg.name, "start", rg.start, "end", rg.end)
+			continue
+		}
+		if rg.fileSize > rg.end-rg.start {
+			rg.fileSize = rg.end - rg.start
+		}
+		if size >= 0 && rg.fileSize > 0 {
+			switch {
+			case rg.off < 0 || rg.off >= size:
+				slog.Debug("section data is outside of the file", "section", rg.name, "offset", rg.off, "size", size)
+				rg.fileSize = 0
+			case uint64(size-rg.off) < rg.fileSize:
+				slog.Debug("section data extends past the end of the file", "section", rg.name, "offset", rg.off, "size", size)
+				rg.fileSize = uint64(size - rg.off)
+			}
+		}
+		m.ranges = append(m.ranges, rg)
+	}
+	sorted := append([]addrRange(nil), m.ranges...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].start < sorted[j].start })
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].start < sorted[i-1].end {
+			slog.Debug("sections overlap", "section", sorted[i].name, "other", sorted[i-1].name)
+		}
+	}
+	return m
+}
+
+// readAt reads len(b) bytes at the virtual address vaddr.
+func (m *addrMap) readAt(b []byte, vaddr uint64) (int, error) {
+	reason := "not in any section"
+	n := uint64(len(b))
+	for _, rg := range m.ranges {
+		if vaddr < rg.start || vaddr >= rg.end {
+			continue
+		}
+		if n > rg.end-vaddr {
+			reason = "crosses the end of " + rg.name
+			continue
+		}
+		if vaddr-rg.start+n > rg.fileSize {
+			reason = "no data in the file for " + rg.name
+			continue
+		}
+		return m.r.ReadAt(b, rg.off+int64(vaddr-rg.start))
+	}
+	return 0, &unmappedError{Addr: vaddr, Len: len(b), Reason: reason}
+}
+
+// readerSize returns the size of what r reads, or -1 if it can't be told.
+func readerSize(r io.ReaderAt) int64 {
+	switch r := r.(type) {
+	case interface{ Size() int64 }:
+		return r.Size()
+	case *os.File:
+		if fi, err := r.Stat(); err == nil && fi.Mode().IsRegular() {
+			return fi.Size()
+		}
+	}
+	return -1
+}
+
+// hasLoadedSections reports whether f has sections that are loaded into
+// memory. Binaries whose section headers were stripped or are only left
+// for what is not loaded have to be read by their segments.
+func hasLoadedSections(f *elf.File) bool {
+	for _, s := range f.Sections {
+		if s.Flags&elf.SHF_ALLOC != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// elfAddrMap maps the loaded sections of f, read from r, or its loadable
+// segments if it has none.
+func elfAddrMap(f *elf.File, r io.ReaderAt) *addrMap {
+	var ranges []addrRange
+	for _, s := range f.Sections {
+		// Sections that are not loaded, like the DWARF info, have
+		// address 0 and would shadow the start of the address space.
+		if s.Flags&elf.SHF_ALLOC == 0 {
+			continue
+		}
+		rg := addrRange{name: s.Name, start: s.Addr, end: s.Addr + s.Size, off: int64(s.Offset), fileSize: s.Size}
+		if s.Type == elf.SHT_NOBITS || s.Flags&elf.SHF_COMPRESSED != 0 {
+			rg.fileSize = 0
+		}
+		ranges = append(ranges, rg)
+	}
+	if len(ranges) > 0 {
+		return newAddrMap(r, ranges)
+	}
+	for i, p := range f.Progs {
+		if p.Type == elf.PT_LOAD {
+			name := fmt.Sprintf("segment %d", i)
+			ranges = append(ranges, addrRange{name: name, start: p.Vaddr, end: p.Vaddr + p.Memsz, off: int64(p.Off), fileSize: p.Filesz})
+		}
+	}
+	return newAddrMap(r, ranges)
+}
+
+// peAddrMap maps the sections of f, read from r, loaded at its image
+// base.
+func peAddrMap(f *pe.File, base uint64, r io.ReaderAt) *addrMap {
+	var ranges []addrRange
+	for _, s := range f.Sections {
+		start := base + uint64(s.VirtualAddress)
+		size := uint64(s.VirtualSize)
+		if size == 0 {
+			size = uint64(s.Size)
+		}
+		ranges = append(ranges, addrRange{name: s.Name, start: start, end: start + size, off: int64(s.Offset), fileSize: uint64(s.Size)})
+	}
+	return newAddrMap(r, ranges)
+}
+
+// Section types of Mach-O sections that are zero-filled when loaded and
+// have no data in the file.
+const (
+	machoZerofill        = 0x1
+	machoGBZerofill      = 0xc
+	machoThreadZerofill  = 0x12
+	machoSectionTypeMask = 0xff
+)
+
+// machoAddrMap maps the sections of f, read from r, or its segments if it
+// has none.
+func machoAddrMap(f *macho.File, r io.ReaderAt) *addrMap {
+	var ranges []addrRange
+	for _, s := range f.Sections {
+		rg := addrRange{name: s.Name, start: s.Addr, end: s.Addr + s.Size, off: int64(s.Offset), fileSize: s.Size}
+		switch s.Flags & machoSectionTypeMask {
+		case machoZerofill, machoGBZerofill, machoThreadZerofill:
+			rg.fileSize = 0
+		}
+		ranges = append(ranges, rg)
+	}
+	if len(ranges) > 0 {
+		return newAddrMap(r, ranges)
+	}
+	for _, l := range f.Loads {
+		if seg, ok := l.(*macho.Segment); ok {
+			ranges = append(ranges, addrRange{name: seg.Name, start: seg.Addr, end: seg.Addr + seg.Memsz, off: int64(seg.Offset), fileSize: seg.Filesz})
+		}
+	}
+	return newAddrMap(r, ranges)
+}
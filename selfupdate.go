@@ -0,0 +1,352 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"debug/buildinfo"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// selfRepo is the GitHub repository "gover self-update" fetches releases
+// from.
+const selfRepo = "ebfe/gover"
+
+// selfVersion returns the module version of the running gover, or
+// "(devel)" if it was not built from a tagged module version.
+func selfVersion() string {
+	if bi, ok := debug.ReadBuildInfo(); ok && bi.Main.Version != "" {
+		return bi.Main.Version
+	}
+	return "(devel)"
+}
+
+// versionMain implements "gover version": it reports the version of gover
+// itself, found by scanning its own executable like any other binary.
+// With -m it also prints its module and build information the way go
+// version -m does.
+func versionMain(args []string) int {
+	fs := flag.NewFlagSet("version", flag.ExitOnError)
+	fs.Usage = usage
+	mods := fs.Bool("m", false, "also print the module and build information")
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	if len(parseArgs(fs, args)) > 0 {
+		usage()
+	}
+	setup()
+
+	goVer := runtime.Version()
+	exe, err := os.Executable()
+	if err == nil {
+		if ver, err := findVersion(exe); err == nil {
+			goVer = ver
+		} else {
+			slog.Debug("scanning own executable failed", "file", exe, "err", err)
+		}
+	}
+	fmt.Printf("gover %s %s %s/%s\n", selfVersion(), goVer, runtime.GOOS, runtime.GOARCH)
+	if *mods && exe != "" {
+		if b, err := openBinary(exe); err == nil {
+			if mi := goVersionModInfo(b); mi != "" {
+				fmt.Printf("\t%s\n", strings.ReplaceAll(mi, "\n", "\n\t"))
+			}
+			b.Close()
+		}
+	}
+	return 0
+}
+
+// assetTokens splits a release asset name into the words separated by
+// punctuation, such as gover, linux and amd64 for gover_linux_amd64.tar.gz.
+func assetTokens(name string) map[string]bool {
+	name = strings.ReplaceAll(strings.ToLower(name), "x86_64", "amd64")
+	tokens := make(map[string]bool)
+	for _, t := range strings.FieldsFunc(name, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || '0' <= r && r <= '9')
+	}) {
+		tokens[t] = true
+	}
+	return tokens
+}
+
+// selfAsset picks the assets of rel with the gover binary for the running
+// platform, the checksum file and its signature, if there are any.
+func selfAsset(rel *githubRelease) (bin, sums, sig *githubAsset) {
+	goos := []string{runtime.GOOS}
+	if runtime.GOOS == "darwin" {
+		goos = append(goos, "macos")
+	}
+	isSums := func(name string) bool {
+		return name == "checksums.txt" || strings.HasSuffix(name, "_checksums.txt") || name == "sha256sums"
+	}
+	sigs := make(map[string]*githubAsset)
+	for i := range rel.Assets {
+		a := &rel.Assets[i]
+		lower := strings.ToLower(a.Name)
+		if isSums(lower) {
+			sums = a
+			continue
+		}
+		if isSums(strings.TrimSuffix(lower, ".sig")) {
+			sigs[strings.TrimSuffix(a.Name, ".sig")] = a
+			continue
+		}
+		if skipAsset(a.Name) {
+			continue
+		}
+		tokens := assetTokens(a.Name)
+		if !tokens[runtime.GOARCH] {
+			continue
+		}
+		for _, name := range goos {
+			if tokens[name] && bin == nil {
+				bin = a
+			}
+		}
+	}
+	if sums != nil {
+		sig = sigs[sums.Name]
+	}
+	return bin, sums, sig
+}
+
+// checksumOf returns the SHA-256 checksum listed for name in a checksum
+// file in the format of sha256sum, or "".
+func checksumOf(sums []byte, name string) string {
+	s := bufio.NewScanner(bytes.NewReader(sums))
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) == 2 && strings.TrimPrefix(f[1], "*") == name {
+			return strings.ToLower(f[0])
+		}
+	}
+	return ""
+}
+
+// extractSelf returns the gover executable from the asset data, which is
+// either the executable itself or a .tar.gz or .zip archive holding it.
+func extractSelf(name string, data []byte) ([]byte, error) {
+	exe := "gover"
+	if runtime.GOOS == "windows" {
+		exe = "gover.exe"
+	}
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz"):
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		tr := tar.NewReader(zr)
+		for {
+			h, err := tr.Next()
+			if err != nil {
+				if err == io.EOF {
+					err = fmt.Errorf("%s: no %s in archive", name, exe)
+				}
+				return nil, err
+			}
+			if h.Typeflag == tar.TypeReg && path.Base(h.Name) == exe {
+				return io.ReadAll(io.LimitReader(tr, maxDownload))
+			}
+		}
+	case strings.HasSuffix(lower, ".zip"):
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if path.Base(f.Name) == exe && !f.FileInfo().IsDir() {
+				rc, err := f.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer rc.Close()
+				return io.ReadAll(io.LimitReader(rc, maxDownload))
+			}
+		}
+		return nil, fmt.Errorf("%s: no %s in archive", name, exe)
+	}
+	return data, nil
+}
+
+// checkSelf verifies that the executable data is a build of gover for the
+// running platform, reading its build info like gover reads any other.
+func checkSelf(data []byte) error {
+	bi, err := buildinfo.Read(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("downloaded file is not a Go binary: %v", err)
+	}
+	if own, ok := debug.ReadBuildInfo(); ok && own.Path != "" && bi.Path != own.Path {
+		return fmt.Errorf("downloaded binary is %s, not %s", bi.Path, own.Path)
+	}
+	settings := make(map[string]string)
+	for _, s := range bi.Settings {
+		settings[s.Key] = s.Value
+	}
+	if goos, goarch := settings["GOOS"], settings["GOARCH"]; goos != runtime.GOOS || goarch != runtime.GOARCH {
+		return fmt.Errorf("downloaded binary is for %s/%s", goos, goarch)
+	}
+	return nil
+}
+
+// replaceSelf replaces the executable exe with data. The running
+// executable can't be overwritten on Windows, but it can be renamed, so
+// it is moved out of the way first.
+func replaceSelf(exe string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(exe), "."+filepath.Base(exe)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(0755)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		old := exe + ".old"
+		os.Remove(old)
+		if err := os.Rename(exe, old); err != nil {
+			os.Remove(f.Name())
+			return err
+		}
+	}
+	if err := os.Rename(f.Name(), exe); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
+// selfUpdateMain implements "gover self-update": it replaces the running
+// gover with the latest release for its platform. Downloads are verified
+// against the release's checksum file, which must be signed with
+// releaseKey, and must be gover builds for the same platform; releases
+// older than the running gover are never installed. With -check it only
+// reports whether there is a newer release.
+func selfUpdateMain(args []string) int {
+	fs := flag.NewFlagSet("self-update", flag.ExitOnError)
+	fs.Usage = usage
+	check := fs.Bool("check", false, "only report whether a newer release is available")
+	force := fs.Bool("f", false, "install the latest release even if it is the running version or that has no version")
+	addDownloadFlags(fs)
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	if len(parseArgs(fs, args)) > 0 {
+		usage()
+	}
+	setup()
+
+	fail := func(msg string, err error) int {
+		slog.Error(msg, "err", err)
+		return exitError
+	}
+	body, err := githubGet(githubAPI+"/repos/"+selfRepo+"/releases/latest", "application/vnd.github+json")
+	if err != nil {
+		return fail("fetching latest release failed", err)
+	}
+	var rel githubRelease
+	err = json.NewDecoder(body).Decode(&rel)
+	body.Close()
+	if err != nil {
+		return fail("fetching latest release failed", err)
+	}
+	cur := selfVersion()
+	cmp, ok := compareSemver(rel.TagName, cur)
+	switch {
+	case ok && cmp < 0:
+		fmt.Printf("gover %s is newer than the latest release %s\n", cur, rel.TagName)
+		return 0
+	case (rel.TagName == cur || ok && cmp == 0) && !*force:
+		fmt.Printf("gover %s is up to date\n", cur)
+		return 0
+	}
+	if *check {
+		fmt.Printf("gover %s is available, running %s\n", rel.TagName, cur)
+		return 0
+	}
+	if !ok && !*force {
+		return fail("not updating", fmt.Errorf("can't compare running version %s with release %s, use -f to install it anyway", cur, rel.TagName))
+	}
+	if releaseKey == "" {
+		return fail("can't verify release", errNoReleaseKey)
+	}
+
+	bin, sums, sig := selfAsset(&rel)
+	if bin == nil {
+		return fail("no release asset found", fmt.Errorf("%s has none for %s/%s", rel.TagName, runtime.GOOS, runtime.GOARCH))
+	}
+	if sums == nil || sig == nil {
+		return fail("can't verify release", fmt.Errorf("%s has no signed checksum file", rel.TagName))
+	}
+	get := func(a *githubAsset) ([]byte, error) {
+		rc, err := openDownload(a.URL, githubHeader("application/octet-stream"), maxDownload)
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	data, err := get(bin)
+	if err != nil {
+		return fail("downloading release failed", err)
+	}
+	list, err := get(sums)
+	if err != nil {
+		return fail("downloading checksums failed", err)
+	}
+	sigData, err := get(sig)
+	if err != nil {
+		return fail("downloading checksums failed", err)
+	}
+	if err := verifySignature(list, sigData); err != nil {
+		return fail("verifying checksums failed", err)
+	}
+	want := checksumOf(list, bin.Name)
+	sum := sha256.Sum256(data)
+	if want == "" {
+		return fail("verifying download failed", fmt.Errorf("no checksum for %s", bin.Name))
+	}
+	if hex.EncodeToString(sum[:]) != want {
+		return fail("verifying download failed", errors.New("checksum mismatch"))
+	}
+	if data, err = extractSelf(bin.Name, data); err != nil {
+		return fail("extracting release failed", err)
+	}
+	if err := checkSelf(data); err != nil {
+		return fail("verifying download failed", err)
+	}
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err == nil {
+		err = replaceSelf(exe, data)
+	}
+	if err != nil {
+		return fail("installing release failed", err)
+	}
+	fmt.Printf("updated gover %s to %s\n", cur, rel.TagName)
+	return 0
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// compareSemver compares two module versions of the form
+// vMAJOR.MINOR.PATCH[-pre][+build] as used by Go modules, including
+// pseudo-versions. It returns -1, 0 or +1, and ok=false if either version
+// is not valid semver.
+func compareSemver(a, b string) (cmp int, ok bool) {
+	pa, oka := parseSemver(a)
+	pb, okb := parseSemver(b)
+	if !oka || !okb {
+		return 0, false
+	}
+	for i := 0; i < 3; i++ {
+		if c := compareNum(pa.num[i], pb.num[i]); c != 0 {
+			return c, true
+		}
+	}
+	return comparePrerelease(pa.pre, pb.pre), true
+}
+
+type semver struct {
+	num [3]string
+	pre string
+}
+
+func parseSemver(v string) (semver, bool) {
+	var s semver
+	if !strings.HasPrefix(v, "v") {
+		return s, false
+	}
+	v = v[1:]
+	if i := strings.Index(v, "+"); i >= 0 {
+		v = v[:i]
+	}
+	if i := strings.Index(v, "-"); i >= 0 {
+		v, s.pre = v[:i], v[i+1:]
+		if s.pre == "" {
+			return s, false
+		}
+	}
+	parts := strings.Split(v, ".")
+	if len(parts) != 3 {
+		return s, false
+	}
+	for i, p := range parts {
+		if !isNum(p) {
+			return s, false
+		}
+		s.num[i] = p
+	}
+	return s, true
+}
+
+func isNum(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// compareNum compares decimal strings of arbitrary length.
+func compareNum(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	switch {
+	case len(a) != len(b):
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// comparePrerelease implements semver precedence for pre-release
+// identifiers, where a release sorts after all of its pre-releases.
+func comparePrerelease(a, b string) int {
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+	fa, fb := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(fa) && i < len(fb); i++ {
+		x, y := fa[i], fb[i]
+		if x == y {
+			continue
+		}
+		nx, ny := isNum(x), isNum(y)
+		switch {
+		case nx && ny:
+			return compareNum(x, y)
+		case nx:
+			return -1
+		case ny:
+			return 1
+		case x < y:
+			return -1
+		}
+		return 1
+	}
+	return compareNum(strconv.Itoa(len(fa)), strconv.Itoa(len(fb)))
+}
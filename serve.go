@@ -0,0 +1,306 @@
+package main
+
+import (
+	"debug/buildinfo"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"runtime"
+	"time"
+)
+
+// scanResult is the JSON representation of a scanned file.
+type scanResult struct {
+	// SchemaVersion is the resultSchemaVersion.
+	SchemaVersion int `json:"schemaVersion"`
+
+	File      string   `json:"file"`
+	Version   string   `json:"version,omitempty"`
+	Arch      string   `json:"arch,omitempty"`
+	EndOfLife bool     `json:"endOfLife,omitempty"`
+	Released  string   `json:"released,omitempty"`
+	TooOld    bool     `json:"tooOld,omitempty"`
+	Deps      []string `json:"deps,omitempty"`
+	Digest    string   `json:"digest,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	ErrorCode string   `json:"errorCode,omitempty"`
+	// Diagnosis lists what each strategy concluded if none found the
+	// version.
+	Diagnosis []diagnosisStep `json:"diagnosis,omitempty"`
+	// Method is the strategy that inferred the version, if it was not
+	// read from what the runtime reports: "producer" for the compiler
+	// version given by the DWARF info, "fingerprint" for an estimate.
+	Method string `json:"method,omitempty"`
+	// Estimate is the range of releases the version is the oldest of,
+	// if it was estimated from the runtime functions the binary has.
+	Estimate *versionEstimate `json:"estimate,omitempty"`
+	// SharedLib is the Go shared library the version was read from, if
+	// the binary was linked with -linkshared.
+	SharedLib string `json:"sharedLib,omitempty"`
+	// Outdated is set with -against-local if the binary was built with
+	// a toolchain older than the installed one.
+	Outdated bool `json:"outdated,omitempty"`
+	// AppVersion is the version of the application itself, with
+	// -app-version.
+	AppVersion string `json:"appVersion,omitempty"`
+	// BuildKind says what the binary was built as if it is not a regular
+	// build: a test binary or an instrumented one, as in "test+cover".
+	BuildKind string `json:"buildKind,omitempty"`
+	// Timing is where the time of the scan went, with -timing.
+	Timing *scanTiming `json:"timing,omitempty"`
+
+	Vars   map[string]interface{} `json:"vars,omitempty"`
+	Binary *binaryMeta            `json:"binary,omitempty"`
+
+	// modInfo is the module information go version -m prints, for
+	// -compat go-version.
+	modInfo string
+	// buildInfo is the build info of the binary, for the catalogFormats
+	// of -compat.
+	buildInfo *buildinfo.BuildInfo
+}
+
+func newScanResult(name, ver string, err error) scanResult {
+	if err != nil {
+		return scanResult{SchemaVersion: resultSchemaVersion, File: name, Error: err.Error(), ErrorCode: errorCode(err), Diagnosis: scanDiagnosis(err)}
+	}
+	res := scanResult{SchemaVersion: resultSchemaVersion, File: name, Version: ver, EndOfLife: isEOL(ver), TooOld: isTooOld(ver)}
+	if t, ok := releaseDate(ver); ok {
+		res.Released = t.Format(time.DateOnly)
+	}
+	return res
+}
+
+// errorCode classifies a scan error for JSON results, so that consumers
+// can handle failures without matching on messages:
+//
+//	empty_file      the file is empty, or zeros like sparse placeholders
+//	truncated       the binary is cut off, as by an interrupted download
+//	not_go_binary   the file has no detectable Go version
+//	timeout         the scan took longer than -timeout
+//	too_large       the file is larger than the size limit
+//	extract_limit   an archive extracts to more than -max-extracted-bytes
+//	entry_limit     an archive has more than -max-entries entries
+//	expansion_ratio data decompresses to more than -max-expansion-ratio times
+//	                its compressed size
+//	special_file    the file is a device, FIFO or socket
+//	read_failed     the file couldn't be opened or read
+//	scan_failed     any other error, such as a malformed binary
+func errorCode(err error) string {
+	var pe *fs.PathError
+	switch {
+	case isEmptyFile(err):
+		return "empty_file"
+	case isTruncated(err):
+		return "truncated"
+	case isNoVersion(err):
+		return "not_go_binary"
+	case isTimeout(err):
+		return "timeout"
+	case errors.Is(err, errTooLarge):
+		return "too_large"
+	case errors.Is(err, errExtractLimit):
+		return "extract_limit"
+	case errors.Is(err, errEntryLimit):
+		return "entry_limit"
+	case errors.Is(err, errExpansionRatio):
+		return "expansion_ratio"
+	case errors.Is(err, errSpecialFile):
+		return "special_file"
+	case errors.As(err, &pe):
+		return "read_failed"
+	}
+	return "scan_failed"
+}
+
+// fileFailure is the entry for a file that failed in the JSON output of
+// the subcommands reading build info or the pclntab, in place of their
+// usual result, with the code matching the exit code.
+type fileFailure struct {
+	File      string `json:"file"`
+	Error     string `json:"error"`
+	ErrorCode string `json:"errorCode"`
+}
+
+func newFileFailure(file string, err error) fileFailure {
+	code := errorCode(err)
+	if code == "scan_failed" && readExit(err) == exitNotGo {
+		code = "not_go_binary"
+	}
+	return fileFailure{File: file, Error: err.Error(), ErrorCode: code}
+}
+
+type server struct {
+	maxSize int64
+	sem     chan struct{}
+	webhook string
+	fetch   *fetchPolicy
+}
+
+// serveMain implements "gover serve", an HTTP service scanning binaries
+// posted to it or fetched from a URL.
+func serveMain(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Usage = usage
+	addr := fs.String("addr", "localhost:8080", "listen address")
+	maxSize := fs.Int64("max-size", 512<<20, "maximum size of a scanned file in bytes")
+	jobs := fs.Int("j", runtime.NumCPU(), "maximum number of concurrent scans")
+	webhook := fs.String("webhook", "", "post policy violations to this URL")
+	fetchHosts := fs.String("fetch-hosts", "", "scan https URLs on these comma separated `hosts`, or * for any public host; URLs are refused without it")
+	debugAddr := addDebugFlag(fs)
+	addLimitFlags(fs)
+	addPluginFlags(fs)
+	addRetryFlags(fs)
+	setup := addGlobalFlags(fs, slog.LevelInfo)
+	parseArgs(fs, args)
+	setup()
+	if *jobs < 1 {
+		*jobs = 1
+	}
+
+	s := &server{maxSize: *maxSize, sem: make(chan struct{}, *jobs), webhook: *webhook, fetch: newFetchPolicy(*fetchHosts)}
+	if s.fetch != nil {
+		// The service only downloads what it is asked to.
+		httpClient = s.fetch.client()
+	}
+	// Not the default mux, which net/http/pprof and expvar register
+	// themselves on.
+	mux := http.NewServeMux()
+	mux.HandleFunc("/scan", s.handleScan)
+	startDebugServer(*debugAddr)
+	slog.Info("listening", "addr", *addr)
+	slog.Error("serve failed", "err", http.ListenAndServe(*addr, mux))
+	return exitError
+}
+
+// handleScan scans the request body, or the file referred to by the url
+// query parameter. The name parameter selects archive handling by file
+// extension. The response is a JSON array of results, one per binary.
+//
+//	curl --data-binary @prog 'http://localhost:8080/scan?name=prog'
+//	curl -X POST 'http://localhost:8080/scan?url=https://example.com/prog.tar.gz'
+//
+// URLs are only fetched as -fetch-hosts allows.
+func (s *server) handleScan(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := req.URL.Query().Get("name")
+	remote := req.URL.Query().Get("url")
+	if remote != "" {
+		u, err := url.Parse(remote)
+		if err == nil {
+			err = s.fetch.check(u)
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if name == "" {
+			name = path.Base(u.Path)
+		}
+	}
+	if name == "" {
+		name = "-"
+	}
+
+	requestsTotal.Add(1)
+	defer func(start time.Time) {
+		requestSeconds.Add(time.Since(start).Seconds())
+	}(time.Now())
+	queueDepth.Add(1)
+	select {
+	case s.sem <- struct{}{}:
+		queueDepth.Add(-1)
+		inFlight.Add(1)
+		defer func() {
+			inFlight.Add(-1)
+			<-s.sem
+		}()
+	case <-req.Context().Done():
+		queueDepth.Add(-1)
+		return
+	}
+
+	lr := &limitedReader{r: req.Body, n: s.maxSize}
+	results := []scanResult{}
+	collect := func(n, ver string, err error) {
+		if n != name && isNoVersion(err) {
+			// Only report archive members that are Go binaries.
+			return
+		}
+		slog.Debug("scanned", "remote", req.RemoteAddr, "file", n, "version", ver, "err", err)
+		if err == nil {
+			notifyPolicy(s.webhook, n, ver)
+		}
+		results = append(results, newScanResult(n, ver, err))
+	}
+	var err error
+	tooLarge := false
+	status := http.StatusBadRequest
+	if remote != "" {
+		err = scanRemote(name, remote, nil, s.maxSize, collect)
+		tooLarge = errors.Is(err, errTooLarge)
+		status = http.StatusBadGateway
+	} else {
+		err = scanArchive(name, lr, collect)
+		tooLarge = lr.n < 0
+	}
+	if tooLarge {
+		http.Error(w, "file too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil && remote != "" {
+		// The details of failed connections tell about the network
+		// the service is in; they are only logged.
+		slog.Warn("fetching failed", "remote", req.RemoteAddr, "url", remote, "err", err)
+		http.Error(w, "fetching url failed", status)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+var errTooLarge = errors.New("file too large")
+
+// limitedReader is like io.LimitedReader but fails with err, or
+// errTooLarge if it is nil, instead of silently truncating once more than
+// n bytes are read. n is negative afterwards.
+type limitedReader struct {
+	r   io.Reader
+	n   int64
+	err error
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, l.tooLarge()
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, l.tooLarge()
+	}
+	return n, err
+}
+
+func (l *limitedReader) tooLarge() error {
+	if l.err != nil {
+		return l.err
+	}
+	return errTooLarge
+}
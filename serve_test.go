@@ -0,0 +1,78 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// TestHandleScanConcurrent posts archives to the service from several
+// clients at once, so that go test -race checks the state the scans
+// share: the member slots, pooled buffers and readers, the scan budget
+// and the settings read from flags.
+func TestHandleScanConcurrent(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Skip(err)
+	}
+	bin, err := os.ReadFile(exe)
+	if err != nil {
+		t.Skip(err)
+	}
+	var tb bytes.Buffer
+	gw := gzip.NewWriter(&tb)
+	tw := tar.NewWriter(gw)
+	for _, m := range []struct {
+		name string
+		data []byte
+	}{{"bin/a", bin}, {"notes.txt", []byte("not a binary")}} {
+		tw.WriteHeader(&tar.Header{Name: m.name, Mode: 0755, Size: int64(len(m.data)), Typeflag: tar.TypeReg})
+		tw.Write(m.data)
+	}
+	tw.Close()
+	gw.Close()
+
+	s := &server{maxSize: 1 << 30, sem: make(chan struct{}, 4)}
+	ts := httptest.NewServer(http.HandlerFunc(s.handleScan))
+	defer ts.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Post(ts.URL+"/scan?name=bins.tar.gz", "application/octet-stream", bytes.NewReader(tb.Bytes()))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("status %s", resp.Status)
+				return
+			}
+			var results []scanResult
+			if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+				t.Error(err)
+				return
+			}
+			if len(results) != 1 {
+				t.Errorf("got %d results, want 1: %+v", len(results), results)
+				return
+			}
+			for _, res := range results {
+				if res.Version != runtime.Version() {
+					t.Errorf("%s: version %q, error %q, want %s", res.File, res.Version, res.Error, runtime.Version())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
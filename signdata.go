@@ -0,0 +1,69 @@
+//go:build ignore
+
+// Signdata signs the release data gover update-db downloads and the
+// checksum files of gover releases, with the project's release signing
+// key. It is run by the maintainers when publishing them:
+//
+//	go run signdata.go -genkey release.key
+//	go run signdata.go -key release.key releases.json checksums.txt
+//
+// -genkey writes a new private key to the file given and prints the
+// public key, which release builds are stamped with:
+//
+//	go build -ldflags "-X main.releaseKey=... -X main.releaseDataURL=https://..."
+//
+// Signing writes the base64 encoded signature of each file next to it,
+// with ".sig" appended. Release data must have a "generated" time later
+// than that of the data published before, or gover refuses it.
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+func main() {
+	genkey := flag.String("genkey", "", "write a new private key to `file` and print the public key")
+	keyFile := flag.String("key", "", "sign with the private key in `file`")
+	flag.Parse()
+	log.SetFlags(0)
+
+	if *genkey != "" {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(*genkey, []byte(base64.StdEncoding.EncodeToString(priv)+"\n"), 0600); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println(base64.StdEncoding.EncodeToString(pub))
+		return
+	}
+	if *keyFile == "" || flag.NArg() == 0 {
+		log.Fatal("usage: go run signdata.go -key file files...")
+	}
+	b, err := os.ReadFile(*keyFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	priv, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(b)))
+	if err != nil || len(priv) != ed25519.PrivateKeySize {
+		log.Fatalf("%s: malformed private key", *keyFile)
+	}
+	for _, name := range flag.Args() {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		sig := ed25519.Sign(ed25519.PrivateKey(priv), data)
+		if err := os.WriteFile(name+".sig", []byte(base64.StdEncoding.EncodeToString(sig)+"\n"), 0644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
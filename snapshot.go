@@ -0,0 +1,181 @@
+package main
+
+import (
+	"debug/buildinfo"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ghSnapshot is a snapshot for the GitHub dependency submission API,
+// which adds the dependencies of artifacts built outside of GitHub's own
+// workflows to the dependency graph of a repository.
+type ghSnapshot struct {
+	Version int    `json:"version"`
+	SHA     string `json:"sha"`
+	Ref     string `json:"ref"`
+	Job     struct {
+		Correlator string `json:"correlator"`
+		ID         string `json:"id"`
+	} `json:"job"`
+	Detector struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+		URL     string `json:"url"`
+	} `json:"detector"`
+	Scanned   string                `json:"scanned"`
+	Manifests map[string]ghManifest `json:"manifests"`
+}
+
+// ghManifest lists the modules compiled into one binary.
+type ghManifest struct {
+	Name string `json:"name"`
+	File struct {
+		SourceLocation string `json:"source_location"`
+	} `json:"file"`
+	Resolved map[string]ghDependency `json:"resolved"`
+}
+
+// ghDependency is a module in a ghManifest. Binaries don't tell direct
+// dependencies from indirect ones, so the relationship is left out.
+type ghDependency struct {
+	PackageURL string `json:"package_url"`
+	Scope      string `json:"scope"`
+}
+
+// ghManifestOf returns the manifest of the binary file with the build
+// info bi, named after its main module. Modules replaced by directories
+// are listed as the module they replace, as they have no package URL of
+// their own.
+func ghManifestOf(file string, bi *buildinfo.BuildInfo) ghManifest {
+	m := ghManifest{Name: file, Resolved: make(map[string]ghDependency)}
+	if bi.Main.Path != "" {
+		m.Name = bi.Main.Path
+	}
+	m.File.SourceLocation = file
+	for _, d := range bi.Deps {
+		path, version := d.Path, d.Version
+		if r := d.Replace; r != nil && !isDirectoryPath(r.Path) {
+			path, version = r.Path, r.Version
+		}
+		purl := "pkg:golang/" + path
+		if version != "" {
+			purl = golangPURL(path, version)
+		}
+		m.Resolved[path] = ghDependency{PackageURL: purl, Scope: "runtime"}
+	}
+	return m
+}
+
+// isDirectoryPath reports whether the replacement path of a module is a
+// directory, as go.mod replace directives allow, rather than a module.
+func isDirectoryPath(path string) bool {
+	return strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") || strings.HasPrefix(path, ".\\") || strings.HasPrefix(path, "..\\") || filepath.IsAbs(path) || filepath.VolumeName(path) != ""
+}
+
+// isCommitSHA reports whether s is a full commit hash: 40 hex digits for
+// SHA-1 repositories or 64 for SHA-256 ones.
+func isCommitSHA(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// buildRevision returns the VCS revision recorded in bi, or "".
+func buildRevision(bi *buildinfo.BuildInfo) string {
+	for _, s := range bi.Settings {
+		if s.Key == "vcs.revision" {
+			return s.Value
+		}
+	}
+	return ""
+}
+
+// envOr returns the environment variable key, or def if it is empty.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// snapshotMain implements "gover dep-snapshot": it prints a GitHub
+// dependency submission snapshot of the modules compiled into binaries.
+// The commit and ref the snapshot is for default to those of the GitHub
+// Actions run, if there is one, and the commit to the VCS revision the
+// binaries were built from otherwise.
+func snapshotMain(args []string) int {
+	fs := flag.NewFlagSet("dep-snapshot", flag.ExitOnError)
+	fs.Usage = usage
+	sha := fs.String("sha", os.Getenv("GITHUB_SHA"), "the `commit` the snapshot is for")
+	ref := fs.String("ref", os.Getenv("GITHUB_REF"), "the Git `ref` the snapshot is for, as in refs/heads/main")
+	correlator := fs.String("correlator", envOr("GITHUB_WORKFLOW", "gover")+"_"+envOr("GITHUB_JOB", "dep-snapshot"), "the `key` that tells snapshots of different jobs apart")
+	jobID := fs.String("job-id", os.Getenv("GITHUB_RUN_ID"), "the `id` of the job that produced the snapshot")
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	files := parseArgs(fs, args)
+	setup()
+	if len(files) < 1 {
+		usage()
+	}
+	if *ref == "" {
+		fmt.Fprintf(os.Stderr, "gover: dep-snapshot requires -ref outside of GitHub Actions\n")
+		usage()
+	}
+
+	now := time.Now().UTC()
+	snap := &ghSnapshot{SHA: *sha, Ref: *ref, Scanned: now.Format(time.RFC3339), Manifests: make(map[string]ghManifest)}
+	snap.Job.Correlator = *correlator
+	snap.Job.ID = *jobID
+	if snap.Job.ID == "" {
+		snap.Job.ID = strconv.FormatInt(now.Unix(), 10)
+	}
+	snap.Detector.Name = "gover"
+	snap.Detector.Version = selfVersion()
+	snap.Detector.URL = "https://github.com/ebfe/gover"
+
+	exit := 0
+	// revision is the first VCS revision recorded, in revFile.
+	var revision, revFile string
+	for _, file := range files {
+		bi, err := readBuildInfo(file)
+		if err != nil {
+			slog.Error("reading build info failed", "file", file, "err", err)
+			exit = worseExit(exit, readExit(err))
+			continue
+		}
+		if rev := buildRevision(bi); rev != "" {
+			if revision == "" {
+				revision, revFile = rev, file
+			} else if rev != revision {
+				slog.Warn("binaries were built from different revisions", "file", file, "revision", rev, "first", revFile, "first_revision", revision)
+			}
+		}
+		snap.Manifests[file] = ghManifestOf(file, bi)
+	}
+	if len(snap.Manifests) == 0 {
+		return exit
+	}
+	if snap.SHA == "" {
+		snap.SHA = revision
+	}
+	if !isCommitSHA(snap.SHA) {
+		slog.Error("no commit to submit the snapshot for, set -sha", "sha", snap.SHA)
+		return worseExit(exit, exitUsage)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(snap)
+	return exit
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// sshMain implements "gover ssh". Remote files are streamed through the
+// local ssh(1) client, so only a POSIX shell, cat and tar are needed on
+// the remote side.
+func sshMain(args []string) int {
+	fs := flag.NewFlagSet("ssh", flag.ExitOnError)
+	fs.Usage = usage
+	recursive := fs.Bool("r", false, "scan remote directories recursively")
+	colorMode := addColorFlag(fs)
+	addLimitFlags(fs)
+	addArchiveJobsFlag(fs)
+	addPluginFlags(fs)
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	targets := parseArgs(fs, args)
+	setup()
+	if len(targets) < 1 {
+		usage()
+	}
+
+	r := &reporter{names: len(targets) > 1 || *recursive}
+	r.color = useColor(*colorMode, r.stdout())
+	for _, t := range targets {
+		i := strings.Index(t, ":")
+		if i <= 0 || i == len(t)-1 {
+			r.report(t, "", fmt.Errorf("expected host:path"))
+			continue
+		}
+		host, file := t[:i], t[i+1:]
+		var err error
+		if *recursive {
+			err = sshScanTree(host, file, r)
+		} else {
+			var ver string
+			ver, err = sshScanFile(host, file)
+			r.report(t, ver, err)
+			continue
+		}
+		if err != nil {
+			r.report(t, "", err)
+		}
+	}
+	return r.exit
+}
+
+func sshCommand(host, cmd string) *exec.Cmd {
+	c := exec.Command("ssh", "--", host, cmd)
+	c.Stderr = os.Stderr
+	return c
+}
+
+func sshScanFile(host, file string) (string, error) {
+	c := sshCommand(host, "cat -- "+shellQuote(file))
+	out, err := c.StdoutPipe()
+	if err != nil {
+		return "", err
+	}
+	if err := c.Start(); err != nil {
+		return "", err
+	}
+	ver, err := scanReader(out)
+	if err != nil && !isNoVersion(err) {
+		// The file may not have been read to the end.
+		return "", stopSSH(c, err)
+	}
+	if werr := c.Wait(); werr != nil {
+		return "", fmt.Errorf("ssh: %s", werr)
+	}
+	return ver, err
+}
+
+// stopSSH ends the command c, whose output was not read to the end
+// because reading it failed with err, and returns err. Wait would block
+// on a remote side still writing into the full pipe, so it is killed.
+func stopSSH(c *exec.Cmd, err error) error {
+	c.Process.Kill()
+	c.Wait()
+	return err
+}
+
+// sshScanTree streams dir as a tar archive over a single connection and
+// reports every Go binary found in it.
+func sshScanTree(host, dir string, r *reporter) error {
+	c := sshCommand(host, "cd "+shellQuote(dir)+" && tar -cf - .")
+	out, err := c.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := c.Start(); err != nil {
+		return err
+	}
+	err = scanTar(out, func(name, ver string, err error) {
+		if isNoVersion(err) {
+			return
+		}
+		r.report(host+":"+path.Join(dir, name), ver, err)
+	})
+	if err != nil {
+		return stopSSH(c, err)
+	}
+	if werr := c.Wait(); werr != nil {
+		return fmt.Errorf("ssh: %s", werr)
+	}
+	return nil
+}
+
+// shellQuote quotes s for the remote POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
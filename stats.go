@@ -0,0 +1,218 @@
+package main
+
+import (
+	"debug/elf"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+)
+
+// sectionKinds maps the names of the sections the Go linker writes to the
+// names stats reports their sizes as. DWARF sections are summed up as
+// dwarf. Recent releases keep type data in a section of its own, types,
+// and no longer write typelinks.
+var sectionKinds = map[string]string{
+	// ELF; position independent binaries keep some tables in
+	// .data.rel.ro.
+	".text":                  "text",
+	".rodata":                "rodata",
+	".gopclntab":             "pclntab",
+	".data.rel.ro.gopclntab": "pclntab",
+	".typelink":              "typelinks",
+	".data.rel.ro.typelink":  "typelinks",
+	".go.type":               "types",
+	".data.rel.ro.go.type":   "types",
+	".itablink":              "itablinks",
+	".data.rel.ro.itablink":  "itablinks",
+	".noptrdata":             "noptrdata",
+	".data":                  "data",
+	".bss":                   "bss",
+	".noptrbss":              "noptrbss",
+
+	// PE, which has fewer sections.
+	".rdata": "rodata",
+
+	// Mach-O
+	"__text":      "text",
+	"__rodata":    "rodata",
+	"__gopclntab": "pclntab",
+	"__typelink":  "typelinks",
+	"__go_type":   "types",
+	"__itablink":  "itablinks",
+	"__noptrdata": "noptrdata",
+	"__data":      "data",
+	"__bss":       "bss",
+	"__noptrbss":  "noptrbss",
+}
+
+// packageStats is the code size of a package: the number of its
+// functions and the bytes of text they take. Functions that don't belong
+// to a Go package, like those the compiler generates and C functions, are
+// counted as package "".
+type packageStats struct {
+	Path  string `json:"path"`
+	Funcs int    `json:"funcs"`
+	Size  uint64 `json:"size"`
+}
+
+// binaryStats is what "gover stats" reports about a binary.
+type binaryStats struct {
+	File     string            `json:"file"`
+	Size     int64             `json:"size"`
+	Funcs    int               `json:"funcs"`
+	Sections map[string]uint64 `json:"sections"`
+	Packages []packageStats    `json:"packages"`
+}
+
+// sectionSizes returns the sizes of the sections of the binary bf by the
+// names in sectionKinds.
+func sectionSizes(bf *binaryFile) map[string]uint64 {
+	sizes := make(map[string]uint64)
+	add := func(name string, size uint64) {
+		base := strings.TrimLeft(name, "._")
+		if strings.HasPrefix(base, "debug_") || strings.HasPrefix(base, "zdebug_") {
+			sizes["dwarf"] += size
+		} else if kind, ok := sectionKinds[name]; ok {
+			sizes[kind] += size
+		}
+	}
+	switch b := bf.Binary.(type) {
+	case *elfBinary:
+		for _, s := range b.Sections {
+			size := s.Size
+			if s.Flags&elf.SHF_COMPRESSED != 0 {
+				// Size is that of the decompressed data.
+				size = s.FileSize
+			}
+			add(s.Name, size)
+		}
+	case *peBinary:
+		for _, s := range b.Sections {
+			add(s.Name, uint64(s.VirtualSize))
+		}
+	case *machoBinary:
+		for _, s := range b.Sections {
+			add(s.Name, s.Size)
+		}
+	}
+	return sizes
+}
+
+// newBinaryStats collects the size statistics of the binary file.
+func newBinaryStats(file string) (*binaryStats, error) {
+	b, err := openBinary(file)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+	tab, err := b.Pclntab()
+	if err != nil {
+		return nil, err
+	}
+	st := &binaryStats{File: file, Funcs: len(tab.Funcs), Sections: sectionSizes(b)}
+	if fi, err := os.Stat(longPath(file)); err == nil {
+		st.Size = fi.Size()
+	}
+	pkgs := make(map[string]*packageStats)
+	for i := range tab.Funcs {
+		f := &tab.Funcs[i]
+		pkg := funcPackage(f)
+		ps := pkgs[pkg]
+		if ps == nil {
+			ps = &packageStats{Path: pkg}
+			pkgs[pkg] = ps
+		}
+		ps.Funcs++
+		ps.Size += f.End - f.Entry
+	}
+	st.Packages = []packageStats{}
+	for _, ps := range pkgs {
+		st.Packages = append(st.Packages, *ps)
+	}
+	sort.Slice(st.Packages, func(i, j int) bool {
+		a, b := st.Packages[i], st.Packages[j]
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		return a.Path < b.Path
+	})
+	return st, nil
+}
+
+// print writes st as indented "key: value" lines, with the packages sorted
+// by size.
+func (st *binaryStats) print(w io.Writer) {
+	fmt.Fprintf(w, "%s:\n", st.File)
+	fmt.Fprintf(w, "  size: %d\n", st.Size)
+	fmt.Fprintf(w, "  functions: %d\n", st.Funcs)
+	npkgs := 0
+	for _, ps := range st.Packages {
+		if ps.Path != "" {
+			npkgs++
+		}
+	}
+	fmt.Fprintf(w, "  packages: %d\n", npkgs)
+	var kinds []string
+	for kind := range st.Sections {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Fprintf(w, "  section %s: %d\n", kind, st.Sections[kind])
+	}
+	for _, ps := range st.Packages {
+		path := ps.Path
+		if path == "" {
+			path = "(other)"
+		}
+		fmt.Fprintf(w, "  package %s: %d (%d functions)\n", path, ps.Size, ps.Funcs)
+	}
+}
+
+// statsMain implements "gover stats": it reports the number of functions
+// and packages in binaries, the code size of each package and the sizes
+// of the sections the Go linker writes.
+func statsMain(args []string) int {
+	fs := flag.NewFlagSet("stats", flag.ExitOnError)
+	fs.Usage = usage
+	jsonOut := fs.Bool("json", false, "print the statistics as JSON")
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	files := parseArgs(fs, args)
+	setup()
+	if len(files) < 1 {
+		usage()
+	}
+
+	exit := 0
+	results := []interface{}{}
+	for i, file := range files {
+		st, err := newBinaryStats(file)
+		if err != nil {
+			slog.Error("reading binary failed", "file", file, "err", err)
+			exit = worseExit(exit, readExit(err))
+			if *jsonOut {
+				results = append(results, newFileFailure(file, err))
+			}
+			continue
+		}
+		if *jsonOut {
+			results = append(results, st)
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		st.print(os.Stdout)
+	}
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(results)
+	}
+	return exit
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// stdinName is the file argument that stands for the standard input.
+const stdinName = "-"
+
+var errStdinTerminal = errors.New("standard input is a terminal")
+
+// spillStdin copies the standard input into a temporary file if one of
+// files is stdinName, so that it can be scanned like any other file, and
+// returns files with the name of the temporary file in its place. The
+// binary formats need random access, which pipes don't allow. remove
+// deletes the file again; it is a no-op if there is none. The file is
+// deleted as well if gover is interrupted.
+func spillStdin(files []string) (spilled []string, path string, remove func(), err error) {
+	remove = func() {}
+	if !hasStdin(files) {
+		return files, "", remove, nil
+	}
+	if fi, err := os.Stdin.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		return nil, "", remove, errStdinTerminal
+	}
+	f, err := os.CreateTemp("", "gover-stdin-*")
+	if err != nil {
+		return nil, "", remove, err
+	}
+	onInterrupt(func() { os.Remove(f.Name()) })
+	_, err = io.Copy(f, &limitedReader{r: os.Stdin, n: maxFileSize})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return nil, "", remove, err
+	}
+	spilled = append([]string(nil), files...)
+	for i, file := range spilled {
+		// The standard input can only be read once; all of its
+		// arguments stand for the same data.
+		if file == stdinName {
+			spilled[i] = f.Name()
+		}
+	}
+	unmark := markSpilled(f.Name())
+	return spilled, f.Name(), func() {
+		unmark()
+		os.Remove(f.Name())
+	}, nil
+}
+
+func hasStdin(files []string) bool {
+	for _, file := range files {
+		if file == stdinName {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// versionStrategy is one way of finding the Go version of a binary. find
+// returns a noVersionError if the binary lacks what the strategy reads,
+// so that the next one is tried, and other errors if that is there but
+// can't be read. Strategies that estimate the version record the
+// estimate in t, if not nil.
+type versionStrategy struct {
+	name string
+	find func(b *binaryFile, t *scanTiming) (string, error)
+}
+
+// versionStrategies are tried in order until one finds the version. The
+// DWARF info describes runtime.buildVersion exactly, the symbol table
+// still locates it in binaries linked with -w, and the build info is
+// kept even by -s. If none of them finds it but there is DWARF info, the
+// version of the compiler it names as the producer of the runtime is
+// taken instead, and failing that, the version is estimated from the
+// runtime functions in the pclntab; results report those as the method.
+var versionStrategies = []versionStrategy{
+	{"dwarf", dwarfVersion},
+	{"symtab", symtabVersion},
+	{"buildinfo", buildInfoVersion},
+	{"producer", producerVersion},
+	{"fingerprint", fingerprintVersion},
+}
+
+// inferredMethods are the strategies that don't read the version the
+// runtime reports, but infer it.
+var inferredMethods = map[string]bool{"producer": true, "fingerprint": true}
+
+// strictMode is set by -strict: versions found by the inferredMethods are
+// rejected, for compliance workflows where a guessed version is worse
+// than none.
+var strictMode bool
+
+// diagnosisStep is what one strategy concluded about a binary it found no
+// version in.
+type diagnosisStep struct {
+	Strategy string `json:"strategy"`
+	Result   string `json:"result"`
+}
+
+// diagnosisError reports that no strategy found the Go version of a
+// binary, with the conclusion of each, so that users can tell whether a
+// binary is not Go at all, stripped of everything gover reads, or damaged.
+type diagnosisError struct {
+	steps []diagnosisStep
+	// failed is set if a strategy couldn't read what it looks for, as
+	// opposed to not finding it.
+	failed bool
+}
+
+func (e *diagnosisError) Error() string {
+	var b strings.Builder
+	for i, s := range e.steps {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(s.Strategy + ": " + s.Result)
+	}
+	return b.String()
+}
+
+// scanDiagnosis returns the steps of the diagnosis err carries, if any.
+func scanDiagnosis(err error) []diagnosisStep {
+	if nv, ok := err.(noVersionError); ok {
+		err = nv.err
+	}
+	var d *diagnosisError
+	if errors.As(err, &d) {
+		return d.steps
+	}
+	return nil
+}
+
+// addDiagnosis appends the conclusion of the strategy name to the
+// diagnosis err carries. It returns err unchanged if it has none.
+func addDiagnosis(err error, name, result string) error {
+	nv, ok := err.(noVersionError)
+	if !ok {
+		return err
+	}
+	d, ok := nv.err.(*diagnosisError)
+	if !ok {
+		return err
+	}
+	d.steps = append(d.steps, diagnosisStep{name, result})
+	return err
+}
+
+// runStrategies returns the Go version of the binary b, found by the
+// first of the versionStrategies that succeeds. If none does, the
+// error is a diagnosisError, wrapped in a noVersionError unless a strategy
+// failed to read the binary. The time each strategy takes is recorded in
+// t, unless t is nil.
+func runStrategies(b *binaryFile, t *scanTiming) (string, error) {
+	d := &diagnosisError{}
+	for _, s := range versionStrategies {
+		start := time.Now()
+		ver, err := s.find(b, t)
+		t.add(s.name, time.Since(start))
+		if err == nil && strictMode && inferredMethods[s.name] {
+			slog.Debug("rejecting inferred version", "strategy", s.name, "version", ver)
+			if t != nil {
+				t.Estimate = nil
+			}
+			err = noVersionError{fmt.Errorf("inferred %s, rejected with -strict", ver)}
+		}
+		if err == nil {
+			slog.Debug("found version", "strategy", s.name, "version", ver)
+			if t != nil {
+				t.Method = s.name
+			}
+			return ver, nil
+		}
+		if !isNoVersion(err) {
+			d.failed = true
+		}
+		d.steps = append(d.steps, diagnosisStep{s.name, err.Error()})
+	}
+	if d.failed {
+		return "", d
+	}
+	return "", noVersionError{d}
+}
+
+// dwarfVersion reads runtime.buildVersion as described by the DWARF info.
+func dwarfVersion(b *binaryFile, t *scanTiming) (string, error) {
+	if maxMemory > 0 && dwarfSize(b.Binary) > uint64(maxMemory) {
+		// The other strategies read far less.
+		return "", noVersionError{errors.New("DWARF info larger than -max-memory")}
+	}
+	if !hasDWARFSection(b.Binary, "info") {
+		return "", noVersionError{errors.New("no DWARF info")}
+	}
+	d, err := b.DWARF()
+	if err != nil {
+		return "", noVersionError{err}
+	}
+	v, err := findVariable(b.Binary, d, "runtime.buildVersion")
+	if err != nil {
+		return "", err
+	}
+	if v == nil {
+		return "", noVersionError{errors.New("no runtime.buildVersion variable")}
+	}
+	return readString(b.Binary, v)
+}
+
+// producerVersion returns the version of the Go compiler that produced
+// the runtime according to the DWARF info, as in the DW_AT_producer
+// "Go cmd/compile go1.22.3; regabi", or if no unit is named runtime, that
+// of most units.
+func producerVersion(b *binaryFile, t *scanTiming) (string, error) {
+	if maxMemory > 0 && dwarfSize(b.Binary) > uint64(maxMemory) {
+		return "", noVersionError{errors.New("DWARF info larger than -max-memory")}
+	}
+	if !hasDWARFSection(b.Binary, "info") {
+		return "", noVersionError{errors.New("no DWARF info")}
+	}
+	d, err := b.DWARF()
+	if err != nil {
+		return "", noVersionError{err}
+	}
+	units := make(map[string]int)
+	most := ""
+	dr := d.Reader()
+	for {
+		e, err := dr.Next()
+		if err != nil || e == nil {
+			break
+		}
+		dr.SkipChildren()
+		if e.Tag != dwarf.TagCompileUnit {
+			continue
+		}
+		if lang, _ := e.Val(dwarf.AttrLanguage).(int64); lang != dwLangGo {
+			continue
+		}
+		producer, _ := e.Val(dwarf.AttrProducer).(string)
+		ver := producerGoVersion(producer)
+		if ver == "" {
+			continue
+		}
+		if name, _ := e.Val(dwarf.AttrName).(string); name == "runtime" {
+			return ver, nil
+		}
+		units[ver]++
+		if units[ver] > units[most] || (units[ver] == units[most] && ver < most) {
+			most = ver
+		}
+	}
+	if most == "" {
+		return "", noVersionError{errors.New("no Go producer in DWARF info")}
+	}
+	return most, nil
+}
+
+// producerGoVersion returns the Go version in the DW_AT_producer string
+// of a Go compilation unit, or "" if it has none.
+func producerGoVersion(producer string) string {
+	rest, ok := strings.CutPrefix(producer, "Go cmd/compile ")
+	if !ok {
+		return ""
+	}
+	ver, _, _ := strings.Cut(rest, ";")
+	ver = strings.TrimSpace(ver)
+	if !strings.HasPrefix(ver, "go") && !strings.HasPrefix(ver, "devel ") {
+		return ""
+	}
+	return ver
+}
+
+// symtabVersion reads runtime.buildVersion at the address the symbol
+// table gives for it.
+func symtabVersion(b *binaryFile, t *scanTiming) (string, error) {
+	addr, ok, err := symbolAddr(b.Binary, "runtime.buildVersion")
+	if err != nil {
+		return "", noVersionError{err}
+	}
+	if !ok {
+		return "", noVersionError{errors.New("no runtime.buildVersion symbol")}
+	}
+	return readStringAt(b.Binary, addr)
+}
+
+// buildInfoVersion reads the Go version recorded with the build info.
+func buildInfoVersion(b *binaryFile, t *scanTiming) (string, error) {
+	bi, err := b.BuildInfo()
+	if e, ok := b.Binary.(*elfBinary); ok && err != nil && len(e.Sections) == 0 {
+		if ver, ok := segmentBuildInfoVersion(e); ok {
+			return ver, nil
+		}
+	}
+	if err != nil {
+		return "", noVersionError{errors.New(strings.TrimPrefix(err.Error(), "could not read Go build info: "))}
+	}
+	if bi.GoVersion == "" {
+		return "", noVersionError{errors.New("no Go version in build info")}
+	}
+	return bi.GoVersion, nil
+}
+
+// buildInfoMagic starts the build info header.
+var buildInfoMagic = []byte("\xff Go buildinf:")
+
+// segmentBuildInfoVersion reads the Go version from the build info of the
+// ELF binary e without section headers, which debug/buildinfo needs to
+// find it. The linker puts it at the start of the writable segment.
+func segmentBuildInfoVersion(e *elfBinary) (string, bool) {
+	for _, p := range e.Progs {
+		if p.Type != elf.PT_LOAD || p.Flags&(elf.PF_X|elf.PF_W) != elf.PF_W {
+			continue
+		}
+		data := make([]byte, min(p.Filesz, 64<<10))
+		n, _ := p.ReadAt(data, 0)
+		data = data[:n]
+		for off := 0; off+32 <= len(data); off += 16 {
+			if bytes.HasPrefix(data[off:], buildInfoMagic) {
+				return parseBuildInfoVersion(e, data[off:])
+			}
+		}
+		return "", false
+	}
+	return "", false
+}
+
+// parseBuildInfoVersion returns the Go version of the build info header
+// hdr of b: inline since Go 1.18, and a pointer to a string before.
+func parseBuildInfoVersion(b Binary, hdr []byte) (string, bool) {
+	ptrSize, flags := hdr[14], hdr[15]
+	if flags&2 != 0 {
+		n, l := binary.Uvarint(hdr[32:])
+		if l <= 0 || n > maxStringLen || uint64(len(hdr)-32-l) < n {
+			return "", false
+		}
+		return string(hdr[32+l : 32+l+int(n)]), true
+	}
+	if flags&1 != 0 || uint(ptrSize) != b.PtrSize() {
+		// readStringAt reads little-endian pointers only.
+		return "", false
+	}
+	var ptr uint64
+	if ptrSize == 4 {
+		ptr = uint64(binary.LittleEndian.Uint32(hdr[16:]))
+	} else {
+		ptr = binary.LittleEndian.Uint64(hdr[16:])
+	}
+	ver, err := readStringAt(b, ptr)
+	return ver, err == nil && ver != ""
+}
+
+// symbolAddr returns the address of the symbol name in the symbol table
+// of b. The error describes why there is no symbol table.
+func symbolAddr(b Binary, name string) (uint64, bool, error) {
+	switch f := b.(type) {
+	case *elfBinary:
+		syms, err := f.Symbols()
+		if err != nil {
+			return 0, false, errors.New("no symbol table")
+		}
+		for _, s := range syms {
+			if s.Name == name {
+				return s.Value, true, nil
+			}
+		}
+	case *peBinary:
+		if len(f.Symbols) == 0 {
+			return 0, false, errors.New("no symbol table")
+		}
+		base := f.imageBase()
+		for _, s := range f.Symbols {
+			if s.Name == name && s.SectionNumber > 0 && int(s.SectionNumber) <= len(f.Sections) {
+				return base + uint64(f.Sections[s.SectionNumber-1].VirtualAddress) + uint64(s.Value), true, nil
+			}
+		}
+	case *machoBinary:
+		if f.Symtab == nil || len(f.Symtab.Syms) == 0 {
+			return 0, false, errors.New("no symbol table")
+		}
+		for _, s := range f.Symtab.Syms {
+			if s.Name == name {
+				return s.Value, true, nil
+			}
+		}
+	default:
+		return 0, false, fmt.Errorf("no symbol table in %T", b)
+	}
+	return 0, false, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// summary aggregates scan results per Go release, architecture and
+// policy status.
+type summary struct {
+	total    int
+	releases map[string]int
+	arches   map[string]int
+	policy   map[string]int
+}
+
+func newSummary() *summary {
+	return &summary{
+		releases: make(map[string]int),
+		arches:   make(map[string]int),
+		policy:   make(map[string]int),
+	}
+}
+
+// add counts one result. Failed scans count as unknown.
+func (s *summary) add(ver, arch string, err error) {
+	s.total++
+	if err != nil {
+		s.releases["unknown"]++
+		if isTimeout(err) {
+			s.policy["timed out"]++
+		} else {
+			s.policy["unknown"]++
+		}
+		return
+	}
+	s.releases[releaseOf(ver)]++
+	if arch == "" {
+		arch = "unknown"
+	}
+	s.arches[arch]++
+	if isEOL(ver) {
+		s.policy["end of life"]++
+	} else if isTooOld(ver) {
+		s.policy["too old"]++
+	} else {
+		s.policy["ok"]++
+	}
+}
+
+// releaseOf groups a version by major release, e.g. go1.22.3 as go1.22.x.
+func releaseOf(ver string) string {
+	v, ok := parseGoVersion(ver)
+	if !ok {
+		return ver
+	}
+	return fmt.Sprintf("go%d.%d.x", v.major, v.minor)
+}
+
+// print writes the summary, e.g.
+//
+//	412 binaries: go1.22.x 380, go1.19.x 30 (EOL), unknown 2
+//	architectures: amd64 400, arm64 10, unknown 2
+//	policy: ok 380, end of life 30, unknown 2
+func (s *summary) print(w io.Writer) {
+	fmt.Fprintf(w, "%d binaries:", s.total)
+	for i, rel := range sortedCounts(s.releases, true) {
+		sep := ","
+		if i == 0 {
+			sep = ""
+		}
+		eol := ""
+		if isEOL(strings.TrimSuffix(rel, ".x")) {
+			eol = " (EOL)"
+		}
+		fmt.Fprintf(w, "%s %s %d%s", sep, rel, s.releases[rel], eol)
+	}
+	fmt.Fprintln(w)
+	printCounts(w, "architectures", s.arches)
+	printCounts(w, "policy", s.policy)
+}
+
+func printCounts(w io.Writer, title string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s:", title)
+	for i, k := range sortedCounts(counts, false) {
+		sep := ","
+		if i == 0 {
+			sep = ""
+		}
+		fmt.Fprintf(w, "%s %s %d", sep, k, counts[k])
+	}
+	fmt.Fprintln(w)
+}
+
+// sortedCounts returns the keys of counts, most frequent first. Ties are
+// broken by name, or by newest release first if releases is set.
+func sortedCounts(counts map[string]int, releases bool) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if counts[a] != counts[b] {
+			return counts[a] > counts[b]
+		}
+		if releases {
+			va, oka := parseGoVersion(strings.TrimSuffix(a, ".x"))
+			vb, okb := parseGoVersion(strings.TrimSuffix(b, ".x"))
+			if oka && okb {
+				return vb.less(va)
+			}
+		}
+		return a < b
+	})
+	return keys
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"debug/dwarf"
+	"debug/gosym"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// symbol is the function and source line an address maps to, as printed
+// by symbolize -json. Func is "" if the address is not in any function
+// known to the binary.
+type symbol struct {
+	Addr uint64 `json:"addr"`
+	Func string `json:"func,omitempty"`
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+}
+
+func (s symbol) String() string {
+	if s.Func == "" {
+		return fmt.Sprintf("%#x ??", s.Addr)
+	}
+	if s.File == "" {
+		return fmt.Sprintf("%#x %s", s.Addr, s.Func)
+	}
+	return fmt.Sprintf("%#x %s %s:%d", s.Addr, s.Func, s.File, s.Line)
+}
+
+// symbolizer maps addresses in a binary to functions and source lines.
+// Go code is looked up in the pclntab, which works for stripped binaries
+// too, and other code, like C code linked with cgo, in the DWARF info if
+// there is any. Addresses are virtual addresses as linked, so addresses
+// from a running position independent binary first need the load offset
+// subtracted.
+type symbolizer struct {
+	tab   *gosym.Table
+	dwarf *dwarf.Data
+}
+
+// newSymbolizer reads the tables needed to symbolize addresses in the
+// binary file, which doesn't have to stay open afterwards.
+func newSymbolizer(file string) (*symbolizer, error) {
+	b, err := openBinary(file)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+	tab, err := b.Pclntab()
+	if err != nil {
+		return nil, err
+	}
+	// The DWARF sections are read into memory.
+	d, _ := b.DWARF()
+	return &symbolizer{tab: tab, dwarf: d}, nil
+}
+
+// lookup returns the function and source line containing the address pc.
+func (s *symbolizer) lookup(pc uint64) symbol {
+	sym := symbol{Addr: pc}
+	if file, line, fn := s.tab.PCToLine(pc); fn != nil {
+		sym.Func, sym.File, sym.Line = fn.Name, file, line
+		return sym
+	}
+	if s.dwarf != nil {
+		s.lookupDWARF(&sym)
+	}
+	return sym
+}
+
+// lookupDWARF fills in sym from the DWARF info, searching the subprograms
+// of the compilation unit covering the address and its line table.
+func (s *symbolizer) lookupDWARF(sym *symbol) {
+	r := s.dwarf.Reader()
+	cu, err := r.SeekPC(sym.Addr)
+	if err != nil {
+		return
+	}
+	for {
+		e, err := r.Next()
+		if err != nil || e == nil || e.Tag == 0 {
+			break
+		}
+		if e.Tag != dwarf.TagSubprogram {
+			if e.Children {
+				r.SkipChildren()
+			}
+			continue
+		}
+		ranges, err := s.dwarf.Ranges(e)
+		if err != nil {
+			continue
+		}
+		for _, rg := range ranges {
+			if sym.Addr >= rg[0] && sym.Addr < rg[1] {
+				sym.Func, _ = e.Val(dwarf.AttrName).(string)
+			}
+		}
+		if sym.Func != "" {
+			break
+		}
+		if e.Children {
+			r.SkipChildren()
+		}
+	}
+	if sym.Func == "" {
+		return
+	}
+	lr, err := s.dwarf.LineReader(cu)
+	if err != nil || lr == nil {
+		return
+	}
+	var entry dwarf.LineEntry
+	if lr.SeekPC(sym.Addr, &entry) == nil && entry.File != nil {
+		sym.File, sym.Line = entry.File.Name, entry.Line
+	}
+}
+
+// symbolizeMain implements "gover symbolize": it maps the addresses given
+// as arguments, or read one per line from the standard input, to the
+// functions and source lines of a binary, as for the program counters in
+// crash reports.
+func symbolizeMain(args []string) int {
+	fs := flag.NewFlagSet("symbolize", flag.ExitOnError)
+	fs.Usage = usage
+	jsonOut := fs.Bool("json", false, "print the symbols as JSON")
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	args = parseArgs(fs, args)
+	setup()
+	if len(args) < 1 {
+		usage()
+	}
+
+	s, err := newSymbolizer(args[0])
+	if err != nil {
+		slog.Error("reading pclntab failed", "file", args[0], "err", err)
+		return readExit(err)
+	}
+	addrs := args[1:]
+	if len(addrs) == 0 {
+		sc := bufio.NewScanner(os.Stdin)
+		for sc.Scan() {
+			if line := strings.TrimSpace(sc.Text()); line != "" {
+				addrs = append(addrs, line)
+			}
+		}
+		if err := sc.Err(); err != nil {
+			slog.Error("reading addresses failed", "err", err)
+			return exitError
+		}
+	}
+
+	exit := 0
+	syms := []symbol{}
+	for _, a := range addrs {
+		pc, err := strconv.ParseUint(a, 0, 64)
+		if err != nil {
+			slog.Error("invalid address", "addr", a)
+			exit = exitUsage
+			continue
+		}
+		sym := s.lookup(pc)
+		if *jsonOut {
+			syms = append(syms, sym)
+			continue
+		}
+		fmt.Println(sym)
+	}
+	if *jsonOut {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(syms)
+	}
+	return exit
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// systemdUnitDirs are the system unit search paths in order of precedence.
+var systemdUnitDirs = []string{
+	"/etc/systemd/system",
+	"/run/systemd/system",
+	"/usr/local/lib/systemd/system",
+	"/usr/lib/systemd/system",
+	"/lib/systemd/system",
+}
+
+// systemdBinDirs is where systemd looks up executables given without a path.
+var systemdBinDirs = []string{"/usr/local/sbin", "/usr/local/bin", "/usr/sbin", "/usr/bin", "/sbin", "/bin"}
+
+// systemdExecKeys are the unit settings that name commands, in the order
+// systemd runs them.
+var systemdExecKeys = []string{"ExecCondition", "ExecStartPre", "ExecStart", "ExecStartPost", "ExecReload", "ExecStop", "ExecStopPost"}
+
+func isSystemdExecKey(key string) bool {
+	for _, k := range systemdExecKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// systemdMain implements "gover systemd": it reports the Go version of the
+// binaries run by systemd services.
+func systemdMain(args []string) int {
+	fs := flag.NewFlagSet("systemd", flag.ExitOnError)
+	fs.Usage = usage
+	colorMode := addColorFlag(fs)
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	dirs := parseArgs(fs, args)
+	setup()
+	if len(dirs) == 0 {
+		dirs = systemdUnitDirs
+	}
+
+	r := &reporter{names: true, eol: true}
+	r.color = useColor(*colorMode, r.stdout())
+	units := systemdUnits(dirs)
+	names := make([]string, 0, len(units))
+	for n := range units {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		bins, err := systemdExecs(units[n], dirs)
+		if err != nil {
+			r.report(n, "", err)
+			continue
+		}
+		for _, bin := range bins {
+			reportService(r, n, bin)
+		}
+	}
+	return r.exit
+}
+
+// reportService reports the version of bin, run by service. Binaries
+// without a Go version are skipped.
+func reportService(r *reporter, service, bin string) {
+	ver, err := findVersion(bin)
+	if isNoVersion(err) || os.IsNotExist(err) {
+		return
+	}
+	r.report(service+": "+bin, ver, err)
+}
+
+// systemdUnits maps the name of every service unit found in dirs to its
+// unit file. Units in earlier directories override later ones.
+func systemdUnits(dirs []string) map[string]string {
+	units := make(map[string]string)
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		fis, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, fi := range fis {
+			name := fi.Name()
+			if !strings.HasSuffix(name, ".service") || seen[name] {
+				continue
+			}
+			seen[name] = true
+			file := filepath.Join(dir, name)
+			// Masked units are symlinks to /dev/null.
+			if fi, err := os.Stat(file); err == nil && fi.Mode().IsRegular() {
+				units[name] = file
+			}
+		}
+	}
+	return units
+}
+
+// dropinDirs returns the names of the drop-in directories of the unit
+// name, from the most specific to the least: its own, its template's for
+// instances like foo@bar.service, those of the prefixes ending in a dash
+// like foo-.service for foo-bar.service, and the one for all units of its
+// type.
+func dropinDirs(name string) []string {
+	dirs := []string{name + ".d"}
+	dot := strings.LastIndex(name, ".")
+	if dot < 0 {
+		return dirs
+	}
+	stem, typ := name[:dot], name[dot:]
+	if at := strings.Index(stem, "@"); at >= 0 {
+		stem = stem[:at]
+		if at < dot-1 {
+			dirs = append(dirs, stem+"@"+typ+".d")
+		}
+	}
+	for i := strings.LastIndex(stem, "-"); i > 0; i = strings.LastIndex(stem[:i], "-") {
+		if i < len(stem)-1 {
+			dirs = append(dirs, stem[:i+1]+typ+".d")
+		}
+	}
+	return append(dirs, typ[1:]+".d")
+}
+
+// systemdExecs returns the executables referenced by the unit file and
+// by its drop-in snippets in dirs. As in systemd, the snippets of all of
+// its dropinDirs in all of dirs are applied in the order of their names;
+// a snippet masks those of the same name in later dirs and less specific
+// drop-in directories, and masks them for good if it is a symlink to
+// /dev/null.
+func systemdExecs(file string, dirs []string) ([]string, error) {
+	cmds := make(map[string][]string)
+	if err := parseUnitFile(file, cmds); err != nil {
+		return nil, err
+	}
+	snippets := make(map[string]string)
+	for _, dir := range dirs {
+		for _, d := range dropinDirs(filepath.Base(file)) {
+			drop, _ := filepath.Glob(filepath.Join(dir, d, "*.conf"))
+			for _, f := range drop {
+				if _, ok := snippets[filepath.Base(f)]; !ok {
+					snippets[filepath.Base(f)] = f
+				}
+			}
+		}
+	}
+	names := make([]string, 0, len(snippets))
+	for n := range snippets {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		f := snippets[n]
+		if fi, err := os.Stat(f); err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		if err := parseUnitFile(f, cmds); err != nil {
+			return nil, err
+		}
+	}
+
+	var bins []string
+	seen := make(map[string]bool)
+	for _, key := range systemdExecKeys {
+		for _, cmd := range cmds[key] {
+			bin := systemdExecutable(cmd)
+			if bin != "" && !seen[bin] {
+				seen[bin] = true
+				bins = append(bins, bin)
+			}
+		}
+	}
+	return bins, nil
+}
+
+// parseUnitFile adds the Exec* command lines of the [Service] section
+// in file to cmds. An empty assignment resets the list, as in systemd.
+func parseUnitFile(file string, cmds map[string][]string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	section := ""
+	s := bufio.NewScanner(f)
+	line := ""
+	for s.Scan() {
+		line += strings.TrimSpace(s.Text())
+		if strings.HasSuffix(line, "\\") {
+			line = line[:len(line)-1] + " "
+			continue
+		}
+		l := line
+		line = ""
+		if l == "" || l[0] == '#' || l[0] == ';' {
+			continue
+		}
+		if l[0] == '[' {
+			section = strings.Trim(l, "[]")
+			continue
+		}
+		if section != "Service" {
+			continue
+		}
+		i := strings.Index(l, "=")
+		if i < 0 {
+			continue
+		}
+		key, val := strings.TrimSpace(l[:i]), strings.TrimSpace(l[i+1:])
+		if !isSystemdExecKey(key) {
+			continue
+		}
+		if val == "" {
+			delete(cmds, key)
+			continue
+		}
+		cmds[key] = append(cmds[key], val)
+	}
+	return s.Err()
+}
+
+// systemdExecutable extracts the executable from an Exec* command line,
+// stripping systemd's special prefixes and resolving bare names.
+func systemdExecutable(cmd string) string {
+	cmd = strings.TrimLeft(cmd, "@-:+!")
+	cmd = strings.TrimSpace(cmd)
+	var bin string
+	if cmd != "" && (cmd[0] == '"' || cmd[0] == '\'') {
+		end := strings.IndexByte(cmd[1:], cmd[0])
+		if end < 0 {
+			return ""
+		}
+		bin = cmd[1 : end+1]
+	} else if f := strings.Fields(cmd); len(f) > 0 {
+		bin = f[0]
+	}
+	if bin == "" || filepath.IsAbs(bin) {
+		return bin
+	}
+	for _, dir := range systemdBinDirs {
+		p := filepath.Join(dir, bin)
+		if fi, err := os.Stat(p); err == nil && isExecutable(fi) {
+			return p
+		}
+	}
+	return ""
+}
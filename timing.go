@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// scanTiming is where the time of scanning one file went, as reported by
+// -timing: the wall time of the whole scan and of every strategy tried,
+// and the bytes read from the file. Cached is set if the result came from
+// the scan cache, in which case reading the file was hashing it.
+//
+// Method is the strategy that found the version, and Estimate the range
+// it estimated, if it did. They are not part of the timing reported, but
+// recorded along with it, as the scan of every file reported keeps one.
+type scanTiming struct {
+	Seconds    float64          `json:"seconds"`
+	BytesRead  int64            `json:"bytesRead"`
+	Cached     bool             `json:"cached,omitempty"`
+	Strategies []strategyTiming `json:"strategies,omitempty"`
+	Method     string           `json:"-"`
+	Estimate   *versionEstimate `json:"-"`
+}
+
+// strategyTiming is the wall time of one strategy.
+type strategyTiming struct {
+	Strategy string  `json:"strategy"`
+	Seconds  float64 `json:"seconds"`
+}
+
+// add records that the strategy name took d. t may be nil.
+func (t *scanTiming) add(name string, d time.Duration) {
+	if t != nil {
+		t.Strategies = append(t.Strategies, strategyTiming{name, d.Seconds()})
+	}
+}
+
+// String summarizes t in one line, like "1.2ms, 40.1 KB read; dwarf
+// 0.9ms, symtab 0.1ms".
+func (t *scanTiming) String() string {
+	var b strings.Builder
+	b.WriteString(seconds(t.Seconds).String() + ", " + formatBytes(t.BytesRead) + " read")
+	if t.Cached {
+		b.WriteString(", cached")
+	}
+	for i, s := range t.Strategies {
+		sep := ", "
+		if i == 0 {
+			sep = "; "
+		}
+		b.WriteString(sep + s.Strategy + " " + seconds(s.Seconds).String())
+	}
+	return b.String()
+}
+
+// seconds converts a duration in seconds back for printing.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second)).Round(time.Microsecond)
+}
+
+// formatBytes formats n with a decimal unit.
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
+// countingReaderAt counts the bytes read through it.
+type countingReaderAt struct {
+	r io.ReaderAt
+	n int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := c.r.ReadAt(p, off)
+	atomic.AddInt64(&c.n, int64(n))
+	return n, err
+}
+
+// Size returns the size of what c reads, for readerSize.
+func (c *countingReaderAt) Size() int64 {
+	return readerSize(c.r)
+}
+
+// timingTotals sums up the timings of all files of a scan, including
+// those not reported because they are not Go binaries, for the totals
+// -timing prints at the end. It is safe for concurrent use.
+type timingTotals struct {
+	mu         sync.Mutex
+	files      int
+	seconds    float64
+	bytes      int64
+	strategies map[string]float64
+	tries      map[string]int
+}
+
+func newTimingTotals() *timingTotals {
+	return &timingTotals{strategies: make(map[string]float64), tries: make(map[string]int)}
+}
+
+func (tt *timingTotals) add(t *scanTiming) {
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	tt.files++
+	tt.seconds += t.Seconds
+	tt.bytes += t.BytesRead
+	for _, s := range t.Strategies {
+		tt.strategies[s.Strategy] += s.Seconds
+		tt.tries[s.Strategy]++
+	}
+}
+
+// print writes the totals to w, with the strategies that took longest
+// first, like "timing: 1200 files in 12.3s, 4.5 GB read; dwarf 8.1s (1200
+// files), buildinfo 2.2s (800 files)". The times are summed over
+// concurrent scans, so they can add up to more than the elapsed time.
+func (tt *timingTotals) print(w io.Writer) {
+	names := make([]string, 0, len(tt.strategies))
+	for name := range tt.strategies {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return tt.strategies[names[i]] > tt.strategies[names[j]]
+	})
+	fmt.Fprintf(w, "timing: %d files in %v, %s read", tt.files, seconds(tt.seconds), formatBytes(tt.bytes))
+	for i, name := range names {
+		sep := ", "
+		if i == 0 {
+			sep = "; "
+		}
+		fmt.Fprintf(w, "%s%s %v (%d files)", sep, name, seconds(tt.strategies[name]), tt.tries[name])
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// emptyFileError reports a file without data: one of Size zero bytes, or
+// a sparse placeholder whose first Zeros bytes are all zero, as left by
+// preallocated or not yet downloaded files. It is no Go binary, but is
+// told apart from files of other formats.
+type emptyFileError struct {
+	Size  int64
+	Zeros int
+}
+
+func (e *emptyFileError) Error() string {
+	if e.Size == 0 {
+		return "empty file"
+	}
+	return fmt.Sprintf("empty file: the first %d of %d bytes are zeros", e.Zeros, e.Size)
+}
+
+// truncatedError reports a binary cut off after Size bytes, as by an
+// interrupted download, whose headers describe Want bytes, or 0 if the
+// headers or the tables they point to are cut off themselves.
+type truncatedError struct {
+	Size, Want int64
+}
+
+func (e *truncatedError) Error() string {
+	if e.Want == 0 {
+		return fmt.Sprintf("truncated: only %d bytes available", e.Size)
+	}
+	return fmt.Sprintf("truncated: %d of %d bytes available", e.Size, e.Want)
+}
+
+func isEmptyFile(err error) bool {
+	var e *emptyFileError
+	return errors.As(err, &e)
+}
+
+func isTruncated(err error) bool {
+	var e *truncatedError
+	return errors.As(err, &e)
+}
+
+// zeroProbe is how much of a file starting with zeros is checked for data
+// before it is taken for a placeholder.
+const zeroProbe = 64 << 10
+
+// checkEmpty returns an emptyFileError if r, of size bytes or -1 if that
+// is not known, has no data. It reads at most zeroProbe bytes, and only
+// if magic, the start of r, is zeros.
+func checkEmpty(r io.ReaderAt, size int64, magic []byte) error {
+	if size == 0 {
+		return &emptyFileError{}
+	}
+	if size < 0 || len(magic) == 0 || !isZeros(magic) {
+		return nil
+	}
+	buf := make([]byte, min(size, zeroProbe))
+	n, err := r.ReadAt(buf, 0)
+	if err != nil && err != io.EOF || !isZeros(buf[:n]) {
+		return nil
+	}
+	return &emptyFileError{Size: size, Zeros: n}
+}
+
+func isZeros(b []byte) bool {
+	return len(bytes.Trim(b, "\x00")) == 0
+}
+
+// headerTruncated returns a truncatedError for the error parsing the
+// headers of a binary of size bytes failed with if the headers ran past
+// the end of the file, and err otherwise. The debug packages don't all
+// wrap the errors of the reads that failed.
+func headerTruncated(err error, size int64) error {
+	if size >= 0 && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || strings.HasSuffix(err.Error(), io.EOF.Error())) {
+		return &truncatedError{Size: size}
+	}
+	return err
+}
+
+// fileExtent returns how many bytes of the file b the data its headers
+// describe extends to: that of its sections and segments.
+func fileExtent(b Binary) int64 {
+	var end uint64
+	switch f := b.(type) {
+	case *elfBinary:
+		for _, s := range f.Sections {
+			if s.Type != elf.SHT_NOBITS && s.Type != elf.SHT_NULL {
+				end = max(end, s.Offset+s.FileSize)
+			}
+		}
+		for _, p := range f.Progs {
+			end = max(end, p.Off+p.Filesz)
+		}
+	case *peBinary:
+		for _, s := range f.Sections {
+			end = max(end, uint64(s.Offset)+uint64(s.Size))
+		}
+	case *machoBinary:
+		if f.fat != nil {
+			for _, a := range f.fat.Arches {
+				end = max(end, uint64(a.Offset)+uint64(a.Size))
+			}
+			break
+		}
+		for _, l := range f.Loads {
+			if seg, ok := l.(*macho.Segment); ok {
+				end = max(end, seg.Offset+seg.Filesz)
+			}
+		}
+	}
+	return int64(end)
+}
+
+// checkTruncated returns a truncatedError if r, of size bytes or -1 if
+// that is not known, is shorter than what the headers of b describe.
+func checkTruncated(b Binary, size int64) error {
+	if size < 0 {
+		return nil
+	}
+	if want := fileExtent(b); size < want {
+		return &truncatedError{Size: size, Want: want}
+	}
+	return nil
+}
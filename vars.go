@@ -0,0 +1,287 @@
+package main
+
+import (
+	"debug/dwarf"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+)
+
+// maxValueDepth limits how deeply readValue descends into nested
+// structs, arrays and slices.
+const maxValueDepth = 8
+
+// valueKind returns the kind of value of type t as used in variable
+// rules: string, int, uint, bool, float, slice, array or struct, or ""
+// if readValue doesn't support t.
+func valueKind(t dwarf.Type) string {
+	switch t := t.(type) {
+	case *dwarf.TypedefType:
+		return valueKind(t.Type)
+	case *dwarf.StructType:
+		switch {
+		case t.String() == "struct string":
+			return "string"
+		case strings.HasPrefix(t.StructName, "[]"):
+			return "slice"
+		case t.Kind == "struct":
+			return "struct"
+		}
+	case *dwarf.IntType:
+		return "int"
+	case *dwarf.UintType:
+		return "uint"
+	case *dwarf.BoolType:
+		return "bool"
+	case *dwarf.FloatType:
+		return "float"
+	case *dwarf.ArrayType:
+		return "array"
+	}
+	return ""
+}
+
+// valueReader reads values of global variables. budget is the number of
+// bytes that may still be read, shared by all elements of a value, as
+// slice lengths are as untrusted as string lengths.
+type valueReader struct {
+	b      Binary
+	budget uint64
+}
+
+// readValue reads the value of the global variable v, which has to be of
+// kind typ if that is not "". Strings, numbers and booleans are returned
+// as such, structs as maps of their field names to values, and arrays and
+// slices as lists, except for byte slices and arrays, which are returned
+// as strings. Fields of unsupported types such as pointers are left out.
+func readValue(b Binary, v *variable, typ string) (interface{}, error) {
+	kind := valueKind(v.Type)
+	if kind == "" || typ != "" && kind != typ {
+		return nil, fmt.Errorf("wrong type %q", v.Type.String())
+	}
+	r := &valueReader{b: b, budget: maxStringLen}
+	return r.read(v.Addr, v.Type, 0)
+}
+
+func (r *valueReader) read(addr uint64, t dwarf.Type, depth int) (interface{}, error) {
+	if depth > maxValueDepth {
+		return nil, fmt.Errorf("value nested too deeply")
+	}
+	if td, ok := t.(*dwarf.TypedefType); ok {
+		t = td.Type
+	}
+	switch kind := valueKind(t); kind {
+	case "string":
+		// Like readString, but charging the header and the bytes to
+		// the budget, so that a slice or struct of strings can't read
+		// more than one string may.
+		ps := int64(r.b.PtrSize())
+		if ps != 4 && ps != 8 || t.Size() != 2*ps {
+			return nil, fmt.Errorf("unknown string layout of %s", t.String())
+		}
+		hdr, err := r.bytes(addr, 2*ps)
+		if err != nil {
+			return nil, err
+		}
+		ptr, n := leUint(hdr[:ps]), leUint(hdr[ps:])
+		if n > r.budget {
+			return nil, &badStringError{Addr: ptr, Len: n, Reason: "exceeds the read limit"}
+		}
+		val, err := r.bytes(ptr, int64(n))
+		if err != nil {
+			return nil, &badStringError{Addr: ptr, Len: n, Reason: err.Error()}
+		}
+		return string(val), nil
+	case "int", "uint", "bool", "float":
+		val, err := r.bytes(addr, t.Size())
+		if err != nil {
+			return nil, err
+		}
+		return decodeScalar(kind, val)
+	case "struct":
+		fields := make(map[string]interface{})
+		for _, f := range t.(*dwarf.StructType).Field {
+			if valueKind(f.Type) == "" {
+				continue
+			}
+			v, err := r.read(addr+uint64(f.ByteOffset), f.Type, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", f.Name, err)
+			}
+			fields[f.Name] = v
+		}
+		return fields, nil
+	case "array":
+		at := t.(*dwarf.ArrayType)
+		if at.Count < 0 {
+			return nil, fmt.Errorf("array of unknown length")
+		}
+		return r.list(addr, at.Type, uint64(at.Count), depth)
+	case "slice":
+		st := t.(*dwarf.StructType)
+		var ptr *dwarf.PtrType
+		var lenOff int64 = -1
+		for _, f := range st.Field {
+			switch f.Name {
+			case "array":
+				ptr, _ = f.Type.(*dwarf.PtrType)
+			case "len":
+				lenOff = f.ByteOffset
+			}
+		}
+		ps := int64(r.b.PtrSize())
+		if ptr == nil || lenOff < 0 || ps != 4 && ps != 8 {
+			return nil, fmt.Errorf("unknown slice layout of %s", t.String())
+		}
+		hdr, err := r.bytes(addr, lenOff+ps)
+		if err != nil {
+			return nil, err
+		}
+		return r.list(leUint(hdr[:ps]), ptr.Type, leUint(hdr[lenOff:]), depth)
+	}
+	return nil, fmt.Errorf("unsupported type %q", t.String())
+}
+
+// list reads the n elements of type elem stored at addr.
+func (r *valueReader) list(addr uint64, elem dwarf.Type, n uint64, depth int) (interface{}, error) {
+	size := elem.Size()
+	if size <= 0 {
+		return nil, fmt.Errorf("unknown size of %s", elem.String())
+	}
+	if n > r.budget/uint64(size) {
+		return nil, fmt.Errorf("%d elements of %s exceed the read limit", n, elem.String())
+	}
+	if u, ok := elem.(*dwarf.UintType); ok && u.Size() == 1 {
+		b, err := r.bytes(addr, int64(n))
+		return string(b), err
+	}
+	list := make([]interface{}, 0, n)
+	for i := uint64(0); i < n; i++ {
+		v, err := r.read(addr+i*uint64(size), elem, depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("[%d]: %v", i, err)
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
+// bytes reads n bytes at addr, charging them to the budget.
+func (r *valueReader) bytes(addr uint64, n int64) ([]byte, error) {
+	if n < 0 || uint64(n) > r.budget {
+		return nil, fmt.Errorf("value exceeds the read limit")
+	}
+	r.budget -= uint64(n)
+	val := make([]byte, n)
+	if _, err := r.b.ReadAtVaddr(val, addr); err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// decodeScalar decodes the little-endian number or boolean val of the
+// given kind.
+func decodeScalar(kind string, val []byte) (interface{}, error) {
+	size := len(val)
+	if size != 1 && size != 2 && size != 4 && size != 8 {
+		return nil, fmt.Errorf("unsupported size %d", size)
+	}
+	u := leUint(val)
+	switch kind {
+	case "bool":
+		return u != 0, nil
+	case "int":
+		// Sign-extend from the variable's size.
+		shift := 64 - 8*uint(size)
+		return int64(u<<shift) >> shift, nil
+	case "float":
+		switch size {
+		case 4:
+			return math.Float32frombits(uint32(u)), nil
+		case 8:
+			return math.Float64frombits(u), nil
+		}
+		return nil, fmt.Errorf("unsupported float size %d", size)
+	}
+	return u, nil
+}
+
+// readVars reads the variables declared by rules from the binary b, which
+// may be nil. Variables that are missing or can't be read are left out;
+// the latter, and missing variables that are required, are logged.
+func readVars(b *binaryFile, rules []varRule) map[string]interface{} {
+	if b == nil {
+		return nil
+	}
+	d, err := b.DWARF()
+	if err != nil {
+		return nil
+	}
+	vars := make(map[string]interface{})
+	for _, rule := range rules {
+		val, err := func() (val interface{}, err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					err = fmt.Errorf("malformed binary: %v", p)
+				}
+			}()
+			v, err := findVariable(b, d, rule.Name)
+			if v == nil && err == nil && rule.required {
+				err = fmt.Errorf("not found")
+			}
+			if v == nil || err != nil {
+				return nil, err
+			}
+			return readValue(b, v, rule.Type)
+		}()
+		if err != nil {
+			slog.Warn("reading variable failed", "file", b.name, "var", rule.Name, "err", err)
+			continue
+		}
+		if val != nil {
+			vars[rule.Field] = val
+		}
+	}
+	if len(vars) == 0 {
+		return nil
+	}
+	return vars
+}
+
+// formatVars formats vars as space-separated field=value pairs, sorted by
+// field. Strings are quoted if they would be ambiguous otherwise, and
+// structs, arrays and slices are written as JSON.
+func formatVars(vars map[string]interface{}) string {
+	fields := make([]string, 0, len(vars))
+	for f := range vars {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+	for i, f := range fields {
+		switch v := vars[f].(type) {
+		case string:
+			if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+				fields[i] = fmt.Sprintf("%s=%q", f, v)
+			} else {
+				fields[i] = f + "=" + v
+			}
+		case map[string]interface{}, []interface{}:
+			b, _ := json.Marshal(v)
+			fields[i] = f + "=" + string(b)
+		default:
+			fields[i] = fmt.Sprintf("%s=%v", f, v)
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// leUint decodes the little-endian unsigned integer b of up to 8 bytes.
+func leUint(b []byte) uint64 {
+	var buf [8]byte
+	copy(buf[:], b)
+	return binary.LittleEndian.Uint64(buf[:])
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+const osvAPI = "https://api.osv.dev"
+
+// osvQuery is one query of an OSV querybatch request.
+type osvQuery struct {
+	Package struct {
+		Name      string `json:"name"`
+		Ecosystem string `json:"ecosystem"`
+	} `json:"package"`
+	Version string `json:"version"`
+}
+
+// vulnMain implements "gover vuln": it looks up the known vulnerabilities
+// of the modules and standard library compiled into binaries in the OSV
+// database. The exit code is 3 if any were found, as for a policy
+// violation.
+func vulnMain(args []string) int {
+	fs := flag.NewFlagSet("vuln", flag.ExitOnError)
+	fs.Usage = usage
+	api := fs.String("api", osvAPI, "base `url` of the OSV API")
+	addRetryFlags(fs)
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	files := parseArgs(fs, args)
+	setup()
+	if len(files) < 1 {
+		usage()
+	}
+
+	exit := 0
+	for _, file := range files {
+		bi, err := readBuildInfo(file)
+		if err != nil {
+			slog.Error("reading build info failed", "file", file, "err", err)
+			exit = worseExit(exit, readExit(err))
+			continue
+		}
+		mods := []string{"stdlib@" + osvGoVersion(bi.GoVersion)}
+		for _, m := range bi.Deps {
+			path := m.Path
+			if m.Replace != nil && m.Replace.Version != "" {
+				path = m.Replace.Path
+			}
+			mods = append(mods, path+"@"+depVersion(m))
+		}
+		ids, err := osvLookup(*api, mods)
+		if err != nil {
+			slog.Error("querying OSV failed", "file", file, "err", err)
+			exit = worseExit(exit, exitError)
+			continue
+		}
+		for i, mod := range mods {
+			if len(ids[i]) == 0 {
+				continue
+			}
+			fmt.Printf("%s: %s: %s\n", file, mod, strings.Join(ids[i], ", "))
+			exit = worseExit(exit, exitViolation)
+		}
+	}
+	return exit
+}
+
+// osvGoVersion converts a Go release as recorded in binaries to the
+// version of the stdlib package in the OSV database.
+func osvGoVersion(ver string) string {
+	ver = strings.TrimPrefix(ver, "go")
+	if i := strings.IndexAny(ver, " "); i >= 0 {
+		ver = ver[:i]
+	}
+	return ver
+}
+
+// osvLookup returns the IDs of the vulnerabilities affecting each of the
+// module versions mods, given as path@version.
+func osvLookup(api string, mods []string) ([][]string, error) {
+	var req struct {
+		Queries []osvQuery `json:"queries"`
+	}
+	for _, mod := range mods {
+		i := strings.LastIndex(mod, "@")
+		var q osvQuery
+		q.Package.Name = mod[:i]
+		q.Package.Ecosystem = "Go"
+		// OSV records Go module versions without the "v".
+		q.Version = strings.TrimPrefix(mod[i+1:], "v")
+		req.Queries = append(req.Queries, q)
+	}
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	hdr := http.Header{}
+	hdr.Set("Content-Type", "application/json")
+	resp, err := httpDo("POST", strings.TrimSuffix(api, "/")+"/v1/querybatch", hdr, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var res struct {
+		Results []struct {
+			Vulns []struct {
+				ID string `json:"id"`
+			} `json:"vulns"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+	if len(res.Results) != len(mods) {
+		return nil, fmt.Errorf("got %d results for %d queries", len(res.Results), len(mods))
+	}
+	ids := make([][]string, len(mods))
+	for i, r := range res.Results {
+		for _, v := range r.Vulns {
+			ids[i] = append(ids[i], v.ID)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type watchState struct {
+	size    int64
+	mtime   time.Time
+	pending bool
+}
+
+// watch polls dirs and reports every binary that appears or changes in
+// them. A file is only scanned once its size and modification time have
+// been stable for one interval, so binaries still being copied in are not
+// reported half-written. watch never returns.
+func watch(dirs []string, recursive bool, interval time.Duration, r *reporter) {
+	files := make(map[string]*watchState)
+	poll := func(initial bool) {
+		seen := make(map[string]bool)
+		for _, dir := range dirs {
+			watchDir(dir, recursive || isPresetDir(dir), func(path string, fi os.FileInfo) {
+				seen[path] = true
+				s := files[path]
+				if s == nil {
+					files[path] = &watchState{size: fi.Size(), mtime: fi.ModTime(), pending: !initial}
+					return
+				}
+				if s.size != fi.Size() || !s.mtime.Equal(fi.ModTime()) {
+					s.size, s.mtime, s.pending = fi.Size(), fi.ModTime(), true
+					return
+				}
+				if s.pending {
+					s.pending = false
+					ver, err := findVersion(path)
+					if isNoVersion(err) || os.IsNotExist(err) {
+						return
+					}
+					r.report(path, ver, err)
+				}
+			})
+		}
+		for path := range files {
+			if !seen[path] {
+				delete(files, path)
+			}
+		}
+	}
+
+	poll(true)
+	for {
+		time.Sleep(interval)
+		poll(false)
+	}
+}
+
+// watchDir calls fn for every regular file in dir, descending into
+// subdirectories if recursive is set.
+func watchDir(dir string, recursive bool, fn func(string, os.FileInfo)) {
+	if recursive {
+		walkFiles(dir, func(path string, fi os.FileInfo, err error) {
+			if err == nil && fi.Mode().IsRegular() {
+				fn(path, fi)
+			}
+		})
+		return
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, fi := range fis {
+		if fi.Mode().IsRegular() {
+			fn(filepath.Join(dir, fi.Name()), fi)
+		}
+	}
+}
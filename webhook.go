@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+)
+
+// webhookEvent is the JSON document posted to webhooks.
+type webhookEvent struct {
+	Event   string    `json:"event"`
+	Host    string    `json:"host,omitempty"`
+	File    string    `json:"file"`
+	Version string    `json:"version"`
+	Reason  string    `json:"reason"`
+	Time    time.Time `json:"time"`
+}
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// policyViolation returns why ver violates policy, or "" if it doesn't:
+// it is an end-of-life release, or a vulnerable toolchain that a newer
+// point release fixes.
+func policyViolation(ver string) string {
+	if isEOL(ver) {
+		return "end of life"
+	}
+	if fix := vulnerableFix(ver); fix != "" {
+		return "vulnerable toolchain, fixed in " + fix
+	}
+	return ""
+}
+
+// notifyPolicy posts a policy violation event for file to url, if ver
+// violates policy. The request is sent in the background; failures are
+// only logged.
+func notifyPolicy(url, file, ver string) {
+	if url == "" {
+		return
+	}
+	reason := policyViolation(ver)
+	if reason == "" {
+		return
+	}
+	host, _ := os.Hostname()
+	ev := webhookEvent{
+		Event:   "policy_violation",
+		Host:    host,
+		File:    file,
+		Version: ver,
+		Reason:  reason,
+		Time:    time.Now().UTC(),
+	}
+	go func() {
+		if err := postWebhook(url, ev); err != nil {
+			slog.Warn("webhook failed", "url", url, "file", file, "err", err)
+		}
+	}()
+}
+
+func postWebhook(url string, ev webhookEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
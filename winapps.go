@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// uninstallKeys are where installers register applications, for all users
+// and 64-bit programs, 32-bit programs, and the current user.
+var uninstallKeys = []string{
+	`HKLM\SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall`,
+	`HKLM\SOFTWARE\WOW6432Node\Microsoft\Windows\CurrentVersion\Uninstall`,
+	`HKCU\SOFTWARE\Microsoft\Windows\CurrentVersion\Uninstall`,
+}
+
+// programFilesVars name the Program Files directories.
+var programFilesVars = []string{"ProgramFiles", "ProgramFiles(x86)", "ProgramW6432"}
+
+// parseRegQuery parses the output of reg query /s into the values of
+// every key, by key and value name.
+func parseRegQuery(out []byte) map[string]map[string]string {
+	keys := make(map[string]map[string]string)
+	var vals map[string]string
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		line := strings.TrimRight(s.Text(), "\r")
+		if strings.HasPrefix(line, "HKEY_") {
+			vals = make(map[string]string)
+			keys[line] = vals
+			continue
+		}
+		// Values are listed as name, type and data separated by four
+		// spaces; names can contain spaces themselves.
+		i := strings.Index(line, "    REG_")
+		if vals == nil || i < 0 {
+			continue
+		}
+		name := strings.TrimSpace(line[:i])
+		_, data, _ := strings.Cut(line[i+4:], "    ")
+		vals[name] = data
+	}
+	return keys
+}
+
+// appLocation returns the directory an uninstall entry installed to: its
+// InstallLocation, or the directory of its DisplayIcon if that is not
+// set, as is common.
+func appLocation(vals map[string]string) string {
+	loc := strings.Trim(expandWinEnv(vals["InstallLocation"]), `" `)
+	if loc == "" {
+		icon := strings.Trim(expandWinEnv(vals["DisplayIcon"]), `" `)
+		// Icons are given as file,index.
+		if i := strings.LastIndex(icon, ","); i > strings.LastIndex(icon, `\`) {
+			icon = strings.Trim(icon[:i], `" `)
+		}
+		if strings.EqualFold(filepath.Ext(icon), ".exe") {
+			loc = filepath.Dir(icon)
+		}
+	}
+	if loc == "" {
+		return ""
+	}
+	if fi, err := os.Stat(longPath(loc)); err != nil || !fi.IsDir() {
+		return ""
+	}
+	return filepath.Clean(loc)
+}
+
+// installedApps returns the applications registered for uninstall that
+// have an install location, and with programFiles, the other folders in
+// the Program Files directories, named after the folder.
+func installedApps(programFiles bool) ([]*appReport, error) {
+	var apps []*appReport
+	seen := make(map[string]bool)
+	failed := 0
+	for _, key := range uninstallKeys {
+		out, err := exec.Command("reg", "query", key, "/s").Output()
+		var ee *exec.ExitError
+		if errors.As(err, &ee) {
+			// The key doesn't exist, like WOW6432Node on 32-bit
+			// Windows.
+			slog.Debug("querying registry failed", "key", key, "err", err)
+			continue
+		}
+		if err != nil {
+			failed++
+			if failed == len(uninstallKeys) {
+				return nil, err
+			}
+			continue
+		}
+		for _, vals := range parseRegQuery(out) {
+			name := vals["DisplayName"]
+			loc := appLocation(vals)
+			if name == "" || loc == "" || seen[strings.ToLower(loc)] {
+				continue
+			}
+			seen[strings.ToLower(loc)] = true
+			apps = append(apps, &appReport{Name: name, Version: vals["DisplayVersion"], Publisher: vals["Publisher"], Location: loc})
+		}
+	}
+	if programFiles {
+		for _, v := range programFilesVars {
+			dir := os.Getenv(v)
+			if dir == "" {
+				continue
+			}
+			fis, err := ioutil.ReadDir(longPath(dir))
+			if err != nil {
+				continue
+			}
+			for _, fi := range fis {
+				loc := filepath.Join(dir, fi.Name())
+				if !fi.IsDir() || seen[strings.ToLower(loc)] {
+					continue
+				}
+				seen[strings.ToLower(loc)] = true
+				apps = append(apps, &appReport{Name: fi.Name(), Location: loc})
+			}
+		}
+	}
+	sort.SliceStable(apps, func(i, j int) bool {
+		return strings.ToLower(apps[i].Name) < strings.ToLower(apps[j].Name)
+	})
+	return apps, nil
+}
+
+// within reports whether path is the directory dir or below it, ignoring
+// case like Windows does.
+func within(path, dir string) bool {
+	path, dir = strings.ToLower(path), strings.ToLower(dir)
+	return path == dir || strings.HasPrefix(path, strings.TrimRight(dir, `\`)+`\`)
+}
+
+// winappsMain implements "gover winapps": it reports the Go binaries of
+// the applications installed on Windows, by application. Applications are
+// those registered for uninstall and the folders in Program Files; their
+// executables and DLLs are scanned, but not those of other applications
+// installed below them.
+func winappsMain(args []string) int {
+	fs := flag.NewFlagSet("winapps", flag.ExitOnError)
+	fs.Usage = usage
+	jsonOut := fs.Bool("json", false, "print results as JSON")
+	noProgramFiles := fs.Bool("no-program-files", false, "only scan applications registered for uninstall, not the other folders in Program Files")
+	colorMode := addColorFlag(fs)
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	if len(parseArgs(fs, args)) > 0 {
+		usage()
+	}
+	setup()
+
+	r := &reporter{names: true, eol: true}
+	r.color = !*jsonOut && useColor(*colorMode, r.stdout())
+	apps, err := installedApps(!*noProgramFiles)
+	if err != nil {
+		slog.Error("listing installed applications failed", "err", err)
+		return exitError
+	}
+	return reportApps(r, apps, *jsonOut, func(app *appReport, path string, fi os.FileInfo) bool {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".exe", ".dll":
+		default:
+			return false
+		}
+		for _, other := range apps {
+			if other != app && within(other.Location, app.Location) && within(path, other.Location) {
+				return false
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const servicesKey = `HKLM\SYSTEM\CurrentControlSet\Services`
+
+// winsvcMain implements "gover winsvc": it reports the Go version of the
+// binaries registered with the Windows service control manager.
+func winsvcMain(args []string) int {
+	fs := flag.NewFlagSet("winsvc", flag.ExitOnError)
+	fs.Usage = usage
+	colorMode := addColorFlag(fs)
+	setup := addGlobalFlags(fs, slog.LevelWarn)
+	parseArgs(fs, args)
+	setup()
+
+	r := &reporter{names: true, eol: true}
+	r.color = useColor(*colorMode, r.stdout())
+	out, err := exec.Command("reg", "query", servicesKey, "/s", "/v", "ImagePath").Output()
+	if err != nil {
+		r.report(servicesKey, "", err)
+		return r.exit
+	}
+
+	service := ""
+	s := bufio.NewScanner(bytes.NewReader(out))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "HKEY_") {
+			service = line[strings.LastIndex(line, `\`)+1:]
+			continue
+		}
+		f := strings.Fields(line)
+		if len(f) < 3 || f[0] != "ImagePath" {
+			continue
+		}
+		val := strings.TrimSpace(line[strings.Index(line, f[1])+len(f[1]):])
+		if bin := serviceImage(val); bin != "" {
+			reportService(r, service, bin)
+		}
+	}
+	return r.exit
+}
+
+// serviceImage extracts the executable from a service ImagePath, which
+// may be quoted, unquoted with spaces, or in NT path syntax.
+func serviceImage(path string) string {
+	path = expandWinEnv(path)
+	if strings.HasPrefix(path, `"`) {
+		if end := strings.Index(path[1:], `"`); end >= 0 {
+			return path[1 : end+1]
+		}
+		return ""
+	}
+	path = strings.TrimPrefix(path, `\??\`)
+	if strings.HasPrefix(strings.ToLower(path), `\systemroot\`) {
+		path = filepath.Join(os.Getenv("SystemRoot"), path[len(`\systemroot\`):])
+	} else if strings.HasPrefix(strings.ToLower(path), `system32\`) {
+		path = filepath.Join(os.Getenv("SystemRoot"), path)
+	}
+
+	// Like the SCM, try successively longer space separated prefixes of
+	// an unquoted path until one names an existing file.
+	f := strings.Split(path, " ")
+	for i := range f {
+		p := strings.Join(f[:i+1], " ")
+		if fi, err := os.Stat(p); err == nil && fi.Mode().IsRegular() {
+			return p
+		}
+		if fi, err := os.Stat(p + ".exe"); err == nil && fi.Mode().IsRegular() {
+			return p + ".exe"
+		}
+	}
+	return ""
+}
+
+// expandWinEnv expands %VAR% references.
+func expandWinEnv(s string) string {
+	var b bytes.Buffer
+	for {
+		i := strings.Index(s, "%")
+		if i < 0 {
+			break
+		}
+		j := strings.Index(s[i+1:], "%")
+		if j < 0 {
+			break
+		}
+		name := s[i+1 : i+1+j]
+		b.WriteString(s[:i])
+		if v, ok := os.LookupEnv(name); ok {
+			b.WriteString(v)
+		} else {
+			b.WriteString(s[i : i+j+2])
+		}
+		s = s[i+j+2:]
+	}
+	b.WriteString(s)
+	return b.String()
+}